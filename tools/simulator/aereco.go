@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// AerecoDXR simulates a demand-controlled DXR unit. Each zone runs at its base airflow and
+// switches to its presence airflow while the zone's presence input is active.
+type AerecoDXR struct {
+	zones []aerecoZone
+	boost bool
+}
+
+type aerecoZone struct {
+	presence        bool
+	baseAirflow     int
+	presenceAirflow int
+	maxAirflow      int
+}
+
+func NewAerecoDXR(zones int) *AerecoDXR {
+	a := &AerecoDXR{}
+	for i := 0; i < zones; i++ {
+		a.zones = append(a.zones, aerecoZone{
+			baseAirflow:     30,
+			presenceAirflow: 60,
+			maxAirflow:      90,
+		})
+	}
+	return a
+}
+
+// airflow returns the current airflow of a zone in m3/h.
+func (a *AerecoDXR) airflow(zone *aerecoZone) int {
+	if a.boost {
+		return zone.maxAirflow
+	}
+	if zone.presence {
+		return zone.presenceAirflow
+	}
+	return zone.baseAirflow
+}
+
+func (a *AerecoDXR) totalAirflow() int {
+	total := 0
+	for i := range a.zones {
+		total += a.airflow(&a.zones[i])
+	}
+	return total
+}
+
+// State implements HRULogic, zone n is named zone<n> like its presence input.
+func (a *AerecoDXR) State() DeviceState {
+	state := DeviceState{"boost": &a.boost}
+	for i := range a.zones {
+		prefix := fmt.Sprintf("zone%d.", i+1)
+		state[prefix+"presence"] = &a.zones[i].presence
+		state[prefix+"baseAirflow"] = &a.zones[i].baseAirflow
+		state[prefix+"presenceAirflow"] = &a.zones[i].presenceAirflow
+	}
+	return state
+}
+
+func (a *AerecoDXR) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Aereco", ProductCode: "DXR", Revision: "2.4.0"})
+	zoneCount := uint16(len(a.zones))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(a.totalAirflow())}, &Success
+		}
+		if register >= 1 && register <= zoneCount {
+			return []uint16{uint16(a.airflow(&a.zones[register-1]))}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			if a.boost {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		if register >= 100 && register < 100+zoneCount {
+			return []uint16{uint16(a.zones[register-100].baseAirflow)}, &Success
+		}
+		if register >= 200 && register < 200+zoneCount {
+			return []uint16{uint16(a.zones[register-200].presenceAirflow)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 0 {
+			a.boost = value != 0
+			log.Printf(">>> CHANGE: boost=%v, totalAirflow=%d\n", a.boost, a.totalAirflow())
+			return &Success
+		}
+		if register >= 100 && register < 100+zoneCount {
+			zone := &a.zones[register-100]
+			if int(value) > zone.maxAirflow {
+				return &IllegalDataValue
+			}
+			zone.baseAirflow = int(value)
+			log.Printf(">>> CHANGE: zone=%d, baseAirflow=%d\n", register-100+1, zone.baseAirflow)
+			return &Success
+		}
+		if register >= 200 && register < 200+zoneCount {
+			zone := &a.zones[register-200]
+			if int(value) > zone.maxAirflow {
+				return &IllegalDataValue
+			}
+			zone.presenceAirflow = int(value)
+			log.Printf(">>> CHANGE: zone=%d, presenceAirflow=%d\n", register-200+1, zone.presenceAirflow)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnReadDiscreteInputs(serv, func(address uint16, numInputs int) ([]bool, *Exception) {
+		if address >= 1 && address <= zoneCount && numInputs == 1 {
+			return []bool{a.zones[address-1].presence}, &Success
+		}
+		return []bool{}, &IllegalDataAddress
+	})
+	// The presence inputs are wired to the unit, the coils let a test simulate a person entering a zone.
+	OnReadCoils(serv, func(address uint16, numCoils int) ([]bool, *Exception) {
+		if address >= 1 && address <= zoneCount && numCoils == 1 {
+			return []bool{a.zones[address-1].presence}, &Success
+		}
+		return []bool{}, &IllegalDataAddress
+	})
+	OnWriteCoil(serv, func(address uint16, value bool) *Exception {
+		if address >= 1 && address <= zoneCount {
+			a.zones[address-1].presence = value
+			log.Printf(">>> CHANGE: zone=%d, presence=%v\n", address, value)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Alarmed is implemented by devices publishing vendor error codes, so alarms can be raised and cleared
+// by their code through the API and scenarios instead of computing the register values.
+type Alarmed interface {
+	// alarmRegisters returns the registers holding the active codes, the fields they point to are
+	// state fields of the device.
+	alarmRegisters() []alarmRegister
+}
+
+// alarmRegister is a register reporting alarms: a bit field where code <prefix><n> is bit n-1, e.g.
+// E3 is bit 2, or a register holding the number of the single active code, 0 without an alarm.
+type alarmRegister struct {
+	prefix string
+	bits   *int
+	code   *int
+}
+
+// parseAlarm returns the register of the code and the bit or number the code stands for.
+func parseAlarm(device Alarmed, code string) (alarmRegister, int, error) {
+	for _, register := range device.alarmRegisters() {
+		digits, ok := strings.CutPrefix(strings.ToUpper(code), register.prefix)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			continue
+		}
+		if register.bits != nil && n >= 1 && n <= 16 {
+			return register, n, nil
+		}
+		if register.code != nil && n >= 1 && n <= 0xFFFF {
+			return register, n, nil
+		}
+	}
+	return alarmRegister{}, 0, fmt.Errorf("invalid alarm code %q, expected %s", code, alarmCodeFormats(device))
+}
+
+func alarmCodeFormats(device Alarmed) string {
+	var formats []string
+	for _, register := range device.alarmRegisters() {
+		if register.bits != nil {
+			formats = append(formats, register.prefix+"1-"+register.prefix+"16")
+		} else {
+			formats = append(formats, register.prefix+"1-"+register.prefix+"65535")
+		}
+	}
+	return strings.Join(formats, " or ")
+}
+
+// setAlarm raises or clears the code. A register holding a single code reports the last raised one.
+// The caller holds the simulation lock.
+func setAlarm(device Alarmed, code string, active bool) error {
+	register, n, err := parseAlarm(device, code)
+	if err != nil {
+		return err
+	}
+	if register.bits != nil {
+		if active {
+			*register.bits |= 1 << (n - 1)
+		} else if *register.bits&(1<<(n-1)) == 0 {
+			return fmt.Errorf("alarm %s%d is not active", register.prefix, n)
+		} else {
+			*register.bits &^= 1 << (n - 1)
+		}
+		return nil
+	}
+	if active {
+		*register.code = n
+	} else if *register.code != n {
+		return fmt.Errorf("alarm %s%d is not active", register.prefix, n)
+	} else {
+		*register.code = 0
+	}
+	return nil
+}
+
+// activeAlarms returns the active codes. The caller holds the simulation lock.
+func activeAlarms(device Alarmed) []string {
+	codes := []string{}
+	for _, register := range device.alarmRegisters() {
+		if register.bits != nil {
+			for n := 1; n <= 16; n++ {
+				if *register.bits&(1<<(n-1)) != 0 {
+					codes = append(codes, fmt.Sprintf("%s%d", register.prefix, n))
+				}
+			}
+		} else if *register.code != 0 {
+			codes = append(codes, fmt.Sprintf("%s%d", register.prefix, *register.code))
+		}
+	}
+	return codes
+}
+
+// alarmed returns the device as Alarmed, failing for devices without error codes.
+func alarmed(device apiDevice) (Alarmed, error) {
+	alarms, ok := device.logic.(Alarmed)
+	if !ok {
+		return nil, fmt.Errorf("%s has no alarm codes", device.deviceType)
+	}
+	return alarms, nil
+}
+
+// SetAlarm raises or clears the alarm code of the device of the unit ID, publishes the changed state
+// and returns the active codes.
+func (a *API) SetAlarm(unitID uint8, code string, active bool) ([]string, error) {
+	device, ok := a.lookup(unitID)
+	if !ok {
+		return nil, fmt.Errorf("no device with unit ID %d", unitID)
+	}
+	alarms, err := alarmed(device)
+	if err != nil {
+		return nil, err
+	}
+	simulation.Lock()
+	defer simulation.Unlock()
+	if err := setAlarm(alarms, code, active); err != nil {
+		return nil, err
+	}
+	if active {
+		log.Printf("!!! FAULT: unit %d alarm %s raised\n", unitID, strings.ToUpper(code))
+	} else {
+		log.Printf(">>> CHANGE: unit %d alarm %s cleared\n", unitID, strings.ToUpper(code))
+	}
+	a.publishChanges(unitID)
+	return activeAlarms(alarms), nil
+}
+
+type apiAlarms struct {
+	Active []string `json:"active"`
+}
+
+func (a *API) getAlarms(w http.ResponseWriter, r *http.Request) {
+	device, ok := a.device(w, r)
+	if !ok {
+		return
+	}
+	alarms, err := alarmed(device)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	simulation.Lock()
+	active := activeAlarms(alarms)
+	simulation.Unlock()
+	writeJSON(w, http.StatusOK, apiAlarms{Active: active})
+}
+
+func (a *API) raiseAlarm(w http.ResponseWriter, r *http.Request) {
+	device, ok := a.device(w, r)
+	if !ok {
+		return
+	}
+	var alarm struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&alarm); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid alarm: %w", err))
+		return
+	}
+	a.writeAlarms(w, device, alarm.Code, true)
+}
+
+func (a *API) clearAlarm(w http.ResponseWriter, r *http.Request) {
+	device, ok := a.device(w, r)
+	if !ok {
+		return
+	}
+	a.writeAlarms(w, device, r.PathValue("code"), false)
+}
+
+// writeAlarms raises or clears the code and answers with the active codes.
+func (a *API) writeAlarms(w http.ResponseWriter, device apiDevice, code string, raise bool) {
+	if _, err := alarmed(device); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	active, err := a.SetAlarm(device.unitID, code, raise)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, apiAlarms{Active: active})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const (
+	aldesModeDaily    = 0
+	aldesModeBoost    = 1
+	aldesModeGuest    = 2
+	aldesModeHoliday  = 3
+	aldesModeProgram  = 4
+	aldesModeFreeCool = 5
+)
+
+// aldesAirflow holds the nominal airflow in m3/h for every mode of an InspirAIR Home.
+var aldesAirflow = [...]int{aldesModeDaily: 120, aldesModeBoost: 240, aldesModeGuest: 180, aldesModeHoliday: 60, aldesModeProgram: 120, aldesModeFreeCool: 240}
+
+type Aldes struct {
+	mode                int
+	outdoorTemperature  float64
+	supplyTemperature   float64
+	extractTemperature  float64
+	filterDaysRemaining int
+	filterAlarm         bool
+	// counted is the simulated time the filter days are counted down to.
+	counted time.Time
+}
+
+func NewAldes() *Aldes {
+	return &Aldes{
+		mode:                aldesModeDaily,
+		outdoorTemperature:  10.5,
+		supplyTemperature:   18.9,
+		extractTemperature:  21.0,
+		filterDaysRemaining: 120,
+		filterAlarm:         false,
+		counted:             clock.Now(),
+	}
+}
+
+// Advance counts the filter days down and raises the filter alarm when they run out.
+func (a *Aldes) Advance(now time.Time) {
+	days := int(now.Sub(a.counted) / (24 * time.Hour))
+	if days <= 0 {
+		return
+	}
+	a.counted = a.counted.Add(time.Duration(days) * 24 * time.Hour)
+	a.filterDaysRemaining = max(a.filterDaysRemaining-days, 0)
+	if a.filterDaysRemaining == 0 && !a.filterAlarm {
+		a.filterAlarm = true
+		log.Printf(">>> CHANGE: filterAlarm=%v\n", a.filterAlarm)
+	}
+}
+
+// State implements HRULogic.
+func (a *Aldes) State() DeviceState {
+	return DeviceState{
+		"mode":                &a.mode,
+		"outdoorTemperature":  &a.outdoorTemperature,
+		"supplyTemperature":   &a.supplyTemperature,
+		"extractTemperature":  &a.extractTemperature,
+		"filterDaysRemaining": &a.filterDaysRemaining,
+		"filterAlarm":         &a.filterAlarm,
+	}
+}
+
+func (a *Aldes) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Aldes", ProductCode: "InspirAIR Home", Revision: "3.1.2"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0x0100 {
+			return []uint16{uint16(a.mode)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0x0200 {
+			return []uint16{uint16(aldesAirflow[a.mode])}, &Success
+		}
+		if register == 0x0201 {
+			return []uint16{uint16(int16(math.Round(a.outdoorTemperature * 10)))}, &Success
+		}
+		if register == 0x0202 {
+			return []uint16{uint16(int16(math.Round(a.supplyTemperature * 10)))}, &Success
+		}
+		if register == 0x0203 {
+			return []uint16{uint16(int16(math.Round(a.extractTemperature * 10)))}, &Success
+		}
+		if register == 0x0204 {
+			return []uint16{uint16(a.filterDaysRemaining)}, &Success
+		}
+		if register == 0x0205 {
+			if a.filterAlarm {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 0x0100 {
+			if value > aldesModeFreeCool {
+				return &IllegalDataValue
+			}
+			a.mode = int(value)
+			log.Printf(">>> CHANGE: mode=%d, airflow=%d\n", a.mode, aldesAirflow[a.mode])
+			return &Success
+		}
+		if register == 0x0101 && value == 1 {
+			a.filterDaysRemaining = 365
+			a.filterAlarm = false
+			log.Printf(">>> CHANGE: filterDaysRemaining=%d\n", a.filterDaysRemaining)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnReadExceptionStatus(serv, func() uint8 {
+		var status uint8
+		if a.filterAlarm {
+			status |= exceptionStatusService
+		}
+		return status
+	})
+}
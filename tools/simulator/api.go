@@ -0,0 +1,489 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// API serves the internal state of the simulated devices over HTTP, so tests can change a device
+// from the outside and check what a client wrote to it:
+//
+//	GET   /devices         lists the devices by unit ID
+//	GET   /devices/{unit}  returns the state of a device
+//	PATCH /devices/{unit}  sets the state fields of the JSON object in the body
+//	POST  /devices/{unit}/reboot  power cycles the device, e.g. {"downtime": "30s"}
+//	GET   /devices/{unit}/alarms  returns the active alarm codes of the device
+//	POST  /devices/{unit}/alarms  raises the alarm code in the body, e.g. {"code": "E3"}
+//	DELETE /devices/{unit}/alarms/{code}  clears the alarm code
+//	GET   /events          streams a StateEvent for every changed state field over a WebSocket
+//	GET   /transactions    returns the most recent requests with their responses
+//	GET   /snapshot        returns the state of all devices as a Snapshot
+//	PUT   /snapshot        restores the Snapshot in the body
+//	GET   /clock           returns the simulated time, whether it is paused and its time scale
+//	PATCH /clock           pauses or resumes the simulation or sets the time scale, e.g. {"paused": true}
+//	GET   /                serves a dashboard built on the endpoints above
+//
+// The state of a device are the scalar variables (numbers, booleans and strings) its State method
+// returns, under their Go names, e.g. powerRelative or filterAlarm, or prefixed for nested ones,
+// e.g. node2.state. Snapshots hold the others, like week programs, as well.
+type API struct {
+	// bus serves the devices, for rebooting them.
+	bus *Bus
+	// devices is guarded by devicesLock, as devices can be replaced while the simulator runs.
+	devices     map[uint8]apiDevice
+	devicesLock sync.RWMutex
+	// states holds the last published state of each device, subscribers the channels of the
+	// event streams. Both are guarded by the simulation lock.
+	states      map[uint8]map[string]any
+	subscribers map[chan StateEvent]struct{}
+	// transactions holds the most recent requests, guarded by the simulation lock as well.
+	transactions []Transaction
+	// changed is signaled when a state field changed, nil unless the state is persisted.
+	changed chan struct{}
+}
+
+type apiDevice struct {
+	unitID     uint8
+	deviceType string
+	config     DeviceConfig
+	logic      HRULogic
+}
+
+// StateEvent is a change of a state field, caused by a Modbus request or the API.
+type StateEvent struct {
+	Unit  uint8     `json:"unit"`
+	Type  string    `json:"type"`
+	Field string    `json:"field"`
+	Value any       `json:"value"`
+	Time  time.Time `json:"time"`
+}
+
+// dashboard is a single page showing the devices, their state and the recent transactions, with
+// controls to change the state, for manual testing without curl.
+//
+//go:embed dashboard.html
+var dashboard []byte
+
+// maxTransactions is the number of requests kept for GET /transactions.
+const maxTransactions = 100
+
+// Transaction is a request answered by a device, with the response data or the exception.
+type Transaction struct {
+	Unit      uint8     `json:"unit"`
+	Function  uint8     `json:"function"`
+	Request   string    `json:"request"`
+	Response  string    `json:"response,omitempty"`
+	Exception string    `json:"exception,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+func NewAPI(bus *Bus) *API {
+	return &API{
+		bus:         bus,
+		devices:     map[uint8]apiDevice{},
+		states:      map[uint8]map[string]any{},
+		subscribers: map[chan StateEvent]struct{}{},
+	}
+}
+
+// Add makes the device built from the config available, unit ID 0 is the device answering any unit
+// ID. It replaces the device the unit ID had before.
+func (a *API) Add(config DeviceConfig, logic HRULogic) {
+	unitID := config.Unit
+	a.devicesLock.Lock()
+	a.devices[unitID] = apiDevice{unitID: unitID, deviceType: config.Type, config: config, logic: logic}
+	a.devicesLock.Unlock()
+	simulation.Lock()
+	a.states[unitID] = deviceState(logic)
+	simulation.Unlock()
+}
+
+// Remove drops the device of the unit ID.
+func (a *API) Remove(unitID uint8) {
+	a.devicesLock.Lock()
+	delete(a.devices, unitID)
+	a.devicesLock.Unlock()
+	simulation.Lock()
+	delete(a.states, unitID)
+	simulation.Unlock()
+}
+
+// lookup returns the device of the unit ID.
+func (a *API) lookup(unitID uint8) (apiDevice, bool) {
+	a.devicesLock.RLock()
+	defer a.devicesLock.RUnlock()
+	device, ok := a.devices[unitID]
+	return device, ok
+}
+
+// logic returns the simulated device of the unit ID, for devices reading each other.
+func (a *API) logic(unitID uint8) (HRULogic, bool) {
+	device, ok := a.lookup(unitID)
+	return device.logic, ok
+}
+
+// units returns the unit IDs of the devices in ascending order.
+func (a *API) units() []uint8 {
+	a.devicesLock.RLock()
+	defer a.devicesLock.RUnlock()
+	units := make([]uint8, 0, len(a.devices))
+	for unit := range a.devices {
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i] < units[j] })
+	return units
+}
+
+// Watch returns the middleware recording the requests to the device of the unit ID and publishing
+// the state changes they cause.
+func (a *API) Watch(unitID uint8) Middleware {
+	return func(next functionHandler) functionHandler {
+		return func(s *Server, request Framer) ([]byte, *Exception) {
+			data, exception := next(s, request)
+			transaction := Transaction{
+				Unit:     unitID,
+				Function: request.GetFunction(),
+				Request:  fmt.Sprintf("% x", request.GetData()),
+				Time:     time.Now(),
+			}
+			if exception == &Success {
+				transaction.Response = fmt.Sprintf("% x", data)
+			} else {
+				transaction.Exception = exception.String()
+			}
+			a.transactions = append(a.transactions, transaction)
+			if len(a.transactions) > maxTransactions {
+				a.transactions = a.transactions[len(a.transactions)-maxTransactions:]
+			}
+			a.publishChanges(unitID)
+			if exception == &Success && writeFunctions[request.GetFunction()] {
+				// the write may have changed state only snapshots hold, like a week program
+				a.stateChanged()
+			}
+			return data, exception
+		}
+	}
+}
+
+// stateChanged makes PersistState write the state. The caller holds the simulation lock.
+func (a *API) stateChanged() {
+	if a.changed == nil {
+		return
+	}
+	select {
+	case a.changed <- struct{}{}:
+	default:
+	}
+}
+
+// publishChanges sends an event for every state field of the device that changed since the last
+// call. The caller holds the simulation lock.
+func (a *API) publishChanges(unitID uint8) {
+	device, ok := a.lookup(unitID)
+	if !ok {
+		return
+	}
+	previous := a.states[unitID]
+	state := deviceState(device.logic)
+	a.states[unitID] = state
+	names := make([]string, 0, len(state))
+	for name, value := range state {
+		if previous[name] != value {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		a.stateChanged()
+	}
+	now := time.Now()
+	for _, name := range names {
+		event := StateEvent{Unit: unitID, Type: device.deviceType, Field: name, Value: state[name], Time: now}
+		for events := range a.subscribers {
+			select {
+			case events <- event:
+			default:
+				// a stalled client misses events instead of blocking the simulation
+			}
+		}
+	}
+}
+
+// publishInterval is the simulated time between the checks for state changed by time alone.
+const publishInterval = time.Second
+
+// PublishTimedChanges publishes the state changes no request causes, like an ending boost, the week
+// program or the thermal model, every second of simulated time. It doesn't return.
+func (a *API) PublishTimedChanges() {
+	for {
+		clock.Wait(clock.Now().Add(publishInterval), nil)
+		simulation.Lock()
+		for _, unit := range a.units() {
+			a.publishChanges(unit)
+		}
+		simulation.Unlock()
+	}
+}
+
+// ListenAndServe serves the API on the address in the background.
+func (a *API) ListenAndServe(address string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /devices", a.listDevices)
+	mux.HandleFunc("GET /devices/{unit}", a.getDevice)
+	mux.HandleFunc("PATCH /devices/{unit}", a.patchDevice)
+	mux.HandleFunc("POST /devices/{unit}/reboot", a.rebootDevice)
+	mux.HandleFunc("GET /devices/{unit}/alarms", a.getAlarms)
+	mux.HandleFunc("POST /devices/{unit}/alarms", a.raiseAlarm)
+	mux.HandleFunc("DELETE /devices/{unit}/alarms/{code}", a.clearAlarm)
+	mux.HandleFunc("GET /events", a.streamEvents)
+	mux.HandleFunc("GET /transactions", a.listTransactions)
+	mux.HandleFunc("GET /snapshot", a.getSnapshot)
+	mux.HandleFunc("PUT /snapshot", a.putSnapshot)
+	mux.HandleFunc("GET /clock", a.getClock)
+	mux.HandleFunc("PATCH /clock", a.patchClock)
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(dashboard)
+	})
+
+	server := &http.Server{Addr: address, Handler: mux}
+	listen, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := server.Serve(listen); err != nil {
+			log.Printf("API server stopped: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+type apiDeviceSummary struct {
+	Unit uint8  `json:"unit"`
+	Type string `json:"type"`
+}
+
+func (a *API) listDevices(w http.ResponseWriter, r *http.Request) {
+	summaries := []apiDeviceSummary{}
+	for _, unit := range a.units() {
+		if device, ok := a.lookup(unit); ok {
+			summaries = append(summaries, apiDeviceSummary{Unit: unit, Type: device.deviceType})
+		}
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+func (a *API) listTransactions(w http.ResponseWriter, r *http.Request) {
+	simulation.Lock()
+	transactions := append([]Transaction{}, a.transactions...)
+	simulation.Unlock()
+	writeJSON(w, http.StatusOK, transactions)
+}
+
+func (a *API) getDevice(w http.ResponseWriter, r *http.Request) {
+	device, ok := a.device(w, r)
+	if !ok {
+		return
+	}
+	simulation.Lock()
+	state := deviceState(device.logic)
+	simulation.Unlock()
+	writeJSON(w, http.StatusOK, state)
+}
+
+func (a *API) patchDevice(w http.ResponseWriter, r *http.Request) {
+	device, ok := a.device(w, r)
+	if !ok {
+		return
+	}
+	var changes map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON object: %w", err))
+		return
+	}
+	state, err := a.setState(device, changes)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// setState changes the state fields of the device and publishes the changes, it returns the new state.
+func (a *API) setState(device apiDevice, changes map[string]json.RawMessage) (map[string]any, error) {
+	simulation.Lock()
+	defer simulation.Unlock()
+	if err := setDeviceState(device.logic, changes); err != nil {
+		return nil, err
+	}
+	a.publishChanges(device.unitID)
+	return deviceState(device.logic), nil
+}
+
+// subscribe returns a channel receiving the state events until it is unsubscribed.
+func (a *API) subscribe() chan StateEvent {
+	events := make(chan StateEvent, 64)
+	simulation.Lock()
+	a.subscribers[events] = struct{}{}
+	simulation.Unlock()
+	return events
+}
+
+func (a *API) unsubscribe(events chan StateEvent) {
+	simulation.Lock()
+	delete(a.subscribers, events)
+	simulation.Unlock()
+}
+
+func (a *API) streamEvents(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgradeWebsocket(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	events := a.subscribe()
+	defer a.unsubscribe(events)
+
+	for {
+		select {
+		case event := <-events:
+			message, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("failed to encode event: %v\n", err)
+				continue
+			}
+			if err := ws.WriteText(message); err != nil {
+				return
+			}
+		case <-ws.closed:
+			return
+		}
+	}
+}
+
+// device looks up the device of the unit in the request path, answering Not Found if there is none.
+func (a *API) device(w http.ResponseWriter, r *http.Request) (apiDevice, bool) {
+	unit, err := strconv.ParseUint(r.PathValue("unit"), 10, 8)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("invalid unit ID %q", r.PathValue("unit")))
+		return apiDevice{}, false
+	}
+	device, ok := a.lookup(uint8(unit))
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no device with unit ID %d", unit))
+		return apiDevice{}, false
+	}
+	return device, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// DeviceState holds pointers to the variables making up the state of a device by name, see
+// HRULogic. Variables of nested parts are named with a prefix, e.g. node1.state.
+type DeviceState map[string]any
+
+// stateVars returns the state variables of the device, advanced to now. The values are settable.
+func stateVars(logic HRULogic) map[string]reflect.Value {
+	if timed, ok := logic.(Timed); ok {
+		timed.Advance(clock.Now())
+	}
+	vars := map[string]reflect.Value{}
+	for name, pointer := range logic.State() {
+		vars[name] = reflect.ValueOf(pointer).Elem()
+	}
+	return vars
+}
+
+// stateFields returns the scalar state variables, the fields of the API. Week programs, fan ramps and
+// the like are left to snapshots.
+func stateFields(logic HRULogic) map[string]reflect.Value {
+	fields := stateVars(logic)
+	for name, field := range fields {
+		switch field.Kind() {
+		case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			delete(fields, name)
+		}
+	}
+	return fields
+}
+
+// deviceState returns the state fields of the device. The caller holds the simulation lock.
+func deviceState(logic HRULogic) map[string]any {
+	state := map[string]any{}
+	for name, field := range stateFields(logic) {
+		state[name] = field.Interface()
+	}
+	return state
+}
+
+// stateValue converts a value typed by an operator to JSON for the state field with the current value,
+// only strings need quoting.
+func stateValue(current any, input string) json.RawMessage {
+	if _, ok := current.(string); ok {
+		raw, _ := json.Marshal(input)
+		return raw
+	}
+	return json.RawMessage(input)
+}
+
+// setDeviceState sets the state fields to the JSON values, either all of them or none. The caller
+// holds the simulation lock.
+func setDeviceState(logic HRULogic, changes map[string]json.RawMessage) error {
+	apply, err := parseDeviceState(stateFields(logic), changes)
+	if err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
+
+// parseDeviceState checks the JSON values against the state fields or variables and returns the
+// function setting them. The caller holds the simulation lock.
+func parseDeviceState(fields map[string]reflect.Value, changes map[string]json.RawMessage) (func(), error) {
+	values := map[string]reflect.Value{}
+	for name, raw := range changes {
+		field, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown state field %q", name)
+		}
+		value := reflect.New(field.Type())
+		if field.Kind() == reflect.Struct {
+			// keep the parts that are no state, e.g. the registers of a week program
+			value.Elem().Set(field)
+		}
+		if err := json.Unmarshal(raw, value.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+		values[name] = value.Elem()
+	}
+	return func() {
+		for name, value := range values {
+			fields[name].Set(value)
+			log.Printf(">>> CHANGE: %s=%v\n", name, value.Interface())
+		}
+	}, nil
+}
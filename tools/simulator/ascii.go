@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/goburrow/serial"
+	. "github.com/tbrandon/mbserver"
+)
+
+// asciiMaxFrameLength is the maximum size of a Modbus ASCII frame: ':' + 2 * 253 hex characters + CRLF.
+const asciiMaxFrameLength = 513
+
+// ASCIIFrame is the Modbus ASCII frame.
+type ASCIIFrame struct {
+	Address  uint8
+	Function uint8
+	Data     []byte
+}
+
+// lrc returns the longitudinal redundancy check of data: the two's complement of the byte sum.
+func lrc(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return -sum
+}
+
+// NewASCIIFrame parses a Modbus ASCII frame, including the leading ':' and the trailing CRLF. The
+// shortest one has no data, like FC7, validateRequest checks the data of each function.
+func NewASCIIFrame(packet []byte) (*ASCIIFrame, error) {
+	if len(packet) < 9 || packet[0] != ':' || !bytes.HasSuffix(packet, []byte("\r\n")) {
+		return nil, fmt.Errorf("ASCII Frame error: invalid frame delimiters: %q", packet)
+	}
+	raw, err := hex.DecodeString(string(packet[1 : len(packet)-2]))
+	if err != nil {
+		return nil, fmt.Errorf("ASCII Frame error: %v", err)
+	}
+	pLen := len(raw)
+	if lrcCalc := lrc(raw[:pLen-1]); lrcCalc != raw[pLen-1] {
+		return nil, fmt.Errorf("ASCII Frame error: LRC (expected 0x%x, got 0x%x)", raw[pLen-1], lrcCalc)
+	}
+	return &ASCIIFrame{
+		Address:  raw[0],
+		Function: raw[1],
+		Data:     raw[2 : pLen-1],
+	}, nil
+}
+
+// Copy the ASCIIFrame.
+func (frame *ASCIIFrame) Copy() Framer {
+	copy := *frame
+	return &copy
+}
+
+// Bytes returns the Modbus byte stream based on the ASCIIFrame fields.
+func (frame *ASCIIFrame) Bytes() []byte {
+	raw := append([]byte{frame.Address, frame.Function}, frame.Data...)
+	raw = append(raw, lrc(raw))
+	return []byte(":" + strings.ToUpper(hex.EncodeToString(raw)) + "\r\n")
+}
+
+// GetFunction returns the Modbus function code.
+func (frame *ASCIIFrame) GetFunction() uint8 {
+	return frame.Function
+}
+
+// GetData returns the ASCIIFrame Data byte field.
+func (frame *ASCIIFrame) GetData() []byte {
+	return frame.Data
+}
+
+// SetData sets the ASCIIFrame Data byte field.
+func (frame *ASCIIFrame) SetData(data []byte) {
+	frame.Data = data
+}
+
+// SetException sets the Modbus exception code in the frame.
+func (frame *ASCIIFrame) SetException(exception *Exception) {
+	frame.Function = frame.Function | 0x80
+	frame.Data = []byte{byte(*exception)}
+}
+
+// serveASCII reads ASCII frames from port and answers them until the port is closed. A ':' always
+// starts a new frame, so any incomplete data before it is dropped.
+func serveASCII(bus *Bus, port io.ReadWriter) error {
+	buffer := make([]byte, 0, 2*asciiMaxFrameLength)
+	chunk := make([]byte, asciiMaxFrameLength)
+	for {
+		n, err := port.Read(chunk)
+		if errors.Is(err, serial.ErrTimeout) {
+			// ASCII allows up to a second between characters, partial frames are kept
+			continue
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		buffer = append(buffer, chunk[:n]...)
+
+		for {
+			start := bytes.IndexByte(buffer, ':')
+			if start < 0 {
+				buffer = buffer[:0]
+				break
+			}
+			if start > 0 {
+				log.Printf("discarding unframed ASCII data %q\n", buffer[:start])
+				buffer = buffer[:copy(buffer, buffer[start:])]
+			}
+			end := bytes.Index(buffer, []byte("\r\n"))
+			if next := bytes.IndexByte(buffer[1:], ':') + 1; next > 0 && (end < 0 || next < end) {
+				log.Printf("discarding incomplete ASCII frame %q\n", buffer[:next])
+				buffer = buffer[:copy(buffer, buffer[next:])]
+				continue
+			}
+			if end < 0 {
+				break
+			}
+
+			packet := make([]byte, end+2)
+			copy(packet, buffer)
+			buffer = buffer[:copy(buffer, buffer[end+2:])]
+
+			frame, err := NewASCIIFrame(packet)
+			if err != nil {
+				log.Printf("bad ASCII frame error %v\n", err)
+				bus.countCommunicationError()
+				continue
+			}
+			if response := bus.handle(frame); response != nil {
+				if bus.faults.dropConnection(port, frame) {
+					return nil
+				}
+				if err := bus.faults.write(port, bus.faults.encode(response)); err != nil {
+					return err
+				}
+			}
+		}
+		if len(buffer) > asciiMaxFrameLength {
+			log.Printf("discarding %d bytes of unframed ASCII data\n", len(buffer))
+			buffer = buffer[:0]
+		}
+	}
+}
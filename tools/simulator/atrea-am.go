@@ -3,16 +3,32 @@ package main
 import (
 	"log"
 	"math"
+	"time"
 
 	. "github.com/tbrandon/mbserver"
 )
 
+// Event codes of the AtreaAM event log, read from the FIFO queue at atreaAMEventLog.
+const (
+	atreaAMEventLog = 1100
+
+	atreaAMEventPowerOn           = 1
+	atreaAMEventModeChanged       = 2
+	atreaAMEventPowerChanged      = 3
+	atreaAMEventTemperatureChange = 4
+)
+
 type AtreaAM struct {
 	powerRelative    float64
 	powerAbsolute    float64
 	powerAbsoluteMax int
 	temperature      float64
 	mode             int
+	events           []uint16
+	alarm            bool
+	filterAlarm      bool
+	frostProtection  bool
+	program          WeekProgram
 }
 
 func NewAtreaAM(max int) *AtreaAM {
@@ -22,54 +38,124 @@ func NewAtreaAM(max int) *AtreaAM {
 		powerAbsoluteMax: max,
 		temperature:      26,
 		mode:             1,
+		events:           []uint16{atreaAMEventPowerOn},
+		program:          NewWeekProgram(2000, 7),
+	}
+}
+
+// Advance switches to the power and mode of the week program slot starting.
+func (a *AtreaAM) Advance(now time.Time) {
+	slot, ok := a.program.due(now)
+	if !ok {
+		return
+	}
+	a.powerRelative = float64(slot.power)
+	a.powerAbsolute = a.powerRelative / 100.0 * float64(a.powerAbsoluteMax)
+	a.logPowerChange()
+	a.mode = slot.mode
+	log.Printf(">>> CHANGE: mode=%d, week program\n", a.mode)
+	a.logEvent(atreaAMEventModeChanged)
+}
+
+// logEvent queues an event, the oldest one is dropped when the queue is full.
+func (a *AtreaAM) logEvent(event uint16) {
+	a.events = append(a.events, event)
+	if len(a.events) > fifoMaxCount {
+		a.events = a.events[len(a.events)-fifoMaxCount:]
+	}
+}
+
+func (a *AtreaAM) logPowerChange() {
+	log.Printf(">>> CHANGE: powerRelative=%.0f, powerAbsolute=%.0f\n", math.Round(a.powerRelative), math.Round(a.powerAbsolute))
+	a.logEvent(atreaAMEventPowerChanged)
+}
+
+// State implements HRULogic.
+func (a *AtreaAM) State() DeviceState {
+	return DeviceState{
+		"powerRelative":    &a.powerRelative,
+		"powerAbsolute":    &a.powerAbsolute,
+		"powerAbsoluteMax": &a.powerAbsoluteMax,
+		"temperature":      &a.temperature,
+		"mode":             &a.mode,
+		"alarm":            &a.alarm,
+		"filterAlarm":      &a.filterAlarm,
+		"frostProtection":  &a.frostProtection,
+		"events":           &a.events,
+		"program":          &a.program,
 	}
 }
 
 func (a *AtreaAM) Configure(serv *Server) {
-	OnReadInputRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
-		if register == 1004 && numRegs == 1 {
-			return []uint16{uint16(a.powerRelative)}, &Success
-		}
-		if register == 1005 && numRegs == 1 {
-			return []uint16{uint16(a.powerAbsolute)}, &Success
-		}
-		if register == 1001 && numRegs == 1 {
-			return []uint16{uint16(a.mode)}, &Success
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "ATREA", ProductCode: "aMotion AM", Revision: "4.2.1"})
+	// input registers, written through the holding registers at the same addresses
+	registers := RegisterMap{
+		1001: {
+			Get: func() float64 { return float64(a.mode) },
+			Set: func(value float64) *Exception {
+				a.mode = int(value)
+				log.Printf(">>> CHANGE: mode=%d\n", a.mode)
+				a.logEvent(atreaAMEventModeChanged)
+				return &Success
+			},
+		},
+		1002: {
+			Get: func() float64 { return a.temperature },
+			Set: func(value float64) *Exception {
+				a.temperature = value
+				log.Printf(">>> CHANGE: temperature=%f\n", a.temperature)
+				a.logEvent(atreaAMEventTemperatureChange)
+				return &Success
+			},
+			Scale: 10,
+		},
+		1004: {
+			Get: func() float64 { return a.powerRelative },
+			Set: func(value float64) *Exception {
+				a.powerRelative = value
+				a.powerAbsolute = a.powerRelative / 100.0 * float64(a.powerAbsoluteMax)
+				a.logPowerChange()
+				return &Success
+			},
+		},
+		1005: {
+			Get: func() float64 { return a.powerAbsolute },
+			Set: func(value float64) *Exception {
+				a.powerAbsolute = value
+				a.powerRelative = a.powerAbsolute / float64(a.powerAbsoluteMax) * 100.0
+				a.logPowerChange()
+				return &Success
+			},
+		},
+	}
+	a.program.Apply(registers)
+	OnReadInputRegisters(serv, registers.Read)
+	OnWriteHoldingRegister(serv, registers.WriteSingle)
+	// only the week program takes block writes
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		if !a.program.contains(register) {
+			return &IllegalFunction
 		}
-		if register == 1002 && numRegs == 1 {
-			return []uint16{uint16(math.Round(a.temperature * 10))}, &Success
+		return registers.Write(register, values)
+	})
+	OnReadHoldingRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
+		return []uint16{}, &IllegalFunction
+	})
+	// reading the event log drains it
+	OnReadFIFOQueue(serv, func(address uint16) ([]uint16, *Exception) {
+		if address == atreaAMEventLog {
+			events := a.events
+			a.events = nil
+			return events, &Success
 		}
 		return []uint16{}, &IllegalDataAddress
 	})
-	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
-		if register == 1004 {
-			a.powerRelative = float64(value)
-			a.powerAbsolute = a.powerRelative / 100.0 * float64(a.powerAbsoluteMax)
-			log.Printf(">>> CHANGE: powerRelative=%d, powerAbsolute=%d\n", math.Round(a.powerRelative), math.Round(a.powerAbsolute))
-			return &Success
-		}
-		if register == 1005 {
-			a.powerAbsolute = float64(value)
-			a.powerRelative = a.powerAbsolute / float64(a.powerAbsoluteMax) * 100.0
-			log.Printf(">>> CHANGE: powerRelative=%d, powerAbsolute=%d\n", math.Round(a.powerRelative), math.Round(a.powerAbsolute))
-			return &Success
+	// discrete inputs 0-2: alarm, filter change, frost protection active
+	OnReadDiscreteInputs(serv, func(address uint16, numInputs int) ([]bool, *Exception) {
+		inputs := []bool{a.alarm, a.filterAlarm, a.frostProtection}
+		if int(address)+numInputs > len(inputs) {
+			return []bool{}, &IllegalDataAddress
 		}
-		if register == 1001 {
-			a.mode = int(value)
-			log.Printf(">>> CHANGE: mode=%d\n", a.mode)
-			return &Success
-		}
-		if register == 1002 {
-			a.temperature = float64(value / 10.0)
-			log.Printf(">>> CHANGE: temperature=%f\n", a.temperature)
-			return &Success
-		}
-		return &IllegalDataAddress
-	})
-	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
-		return &IllegalFunction
-	})
-	OnReadHoldingRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
-		return []uint16{}, &IllegalFunction
+		return inputs[address : int(address)+numInputs], &Success
 	})
 }
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// atreaEC5ModeAuto is the automatic mode, the unit modulates the fan power with the CO2 concentration.
+const atreaEC5ModeAuto = 1
+
+// AtreaEC5 simulates the Duplex EC5 controllers. Unlike the RD5 there is no unlock sequence,
+// setpoints live in their own holding register block and the current values plus diagnostics are
+// published as input registers.
+type AtreaEC5 struct {
+	power              int
+	temperature        float64
+	mode               int
+	outdoorTemperature float64
+	supplyTemperature  float64
+	extractTemperature float64
+	exhaustTemperature float64
+	supplyFanRPM       int
+	extractFanRPM      int
+	filterHours        int
+	operatingHours     int
+	errors             int
+	firmwareVersion    int
+	frostProtection    bool
+	co2                int
+	// co2Sensor is the unit ID of the CO2 sensor followed in automatic mode, the internal co2 is used
+	// without one.
+	co2Sensor int
+	// counted is the simulated time the hours are counted up to.
+	counted time.Time
+	fans    FanRamp
+	thermal ThermalModel
+	frost   FrostProtection
+	demand  DemandControl
+	program WeekProgram
+	devices func(unitID uint8) (HRULogic, bool)
+}
+
+func NewAtreaEC5(co2Sensor int) *AtreaEC5 {
+	return &AtreaEC5{
+		power:              50,
+		temperature:        22,
+		mode:               2,
+		outdoorTemperature: 6.4,
+		supplyTemperature:  19.1,
+		extractTemperature: 22.3,
+		exhaustTemperature: 9.6,
+		supplyFanRPM:       1450,
+		extractFanRPM:      1420,
+		filterHours:        1200,
+		operatingHours:     86000,
+		errors:             0,
+		firmwareVersion:    0x0214,
+		co2:                550,
+		co2Sensor:          co2Sensor,
+		counted:            clock.Now(),
+		fans:               NewFanRamp(50),
+		thermal:            NewThermalModel(0.9),
+		frost:              NewFrostProtection(-3),
+		demand:             NewDemandControl(),
+		program:            NewWeekProgram(13000, 7),
+	}
+}
+
+func (a *AtreaEC5) link(devices func(unitID uint8) (HRULogic, bool)) {
+	a.devices = devices
+}
+
+// currentCO2 returns the concentration of the linked CO2 sensor, the internal one without a sensor or
+// while it is missing.
+func (a *AtreaEC5) currentCO2() int {
+	if co2, ok := sensorCO2(a.devices, a.co2Sensor); ok {
+		return co2
+	}
+	return a.co2
+}
+
+// targetPower returns the power the fans run at, the setpoint or the demand in automatic mode.
+func (a *AtreaEC5) targetPower() float64 {
+	if a.mode == atreaEC5ModeAuto {
+		return a.demand.power(a.currentCO2())
+	}
+	return float64(a.power)
+}
+
+// Advance follows the week program, ramps the fans to the power setpoint, protects the exchanger from
+// freezing, counts the operating and filter hours while the fans run and moves the temperatures.
+func (a *AtreaEC5) Advance(now time.Time) {
+	if slot, ok := a.program.due(now); ok {
+		a.power, a.mode = slot.power, slot.mode
+		log.Printf(">>> CHANGE: power=%d, mode=%d, week program\n", a.power, a.mode)
+	}
+	frost := a.frost.active(a.frostProtection, a.outdoorTemperature)
+	changed := frost != a.frostProtection
+	if changed {
+		a.frostProtection = frost
+		log.Printf(">>> CHANGE: frostProtection=%v\n", a.frostProtection)
+	}
+	if a.fans.Advance(now, a.targetPower()) || changed {
+		a.updateFanRPM()
+	}
+	a.thermal.Advance(now, a.supplyPower(), false, a.outdoorTemperature, a.extractTemperature, &a.supplyTemperature, &a.exhaustTemperature)
+	hours := int(now.Sub(a.counted) / time.Hour)
+	if hours <= 0 {
+		return
+	}
+	a.counted = a.counted.Add(time.Duration(hours) * time.Hour)
+	if a.power > 0 {
+		a.filterHours += hours
+		a.operatingHours += hours
+	}
+}
+
+// filterAlarm reports whether the filters are due for a change, the unit asks for it every 1000
+// operating hours.
+func (a *AtreaEC5) filterAlarm() bool {
+	return a.filterHours >= 1000
+}
+
+// supplyPower returns the power of the supply fan, frost protection halves the supply air so the
+// warm extract air keeps the exchanger above freezing.
+func (a *AtreaEC5) supplyPower() float64 {
+	if a.frostProtection {
+		return a.fans.Power() / 2
+	}
+	return a.fans.Power()
+}
+
+// updateFanRPM sets the fan speeds for the fan power.
+func (a *AtreaEC5) updateFanRPM() {
+	a.supplyFanRPM = int(math.Round(a.supplyPower() * 29))
+	a.extractFanRPM = int(math.Round(a.fans.Power() * 28.4))
+}
+
+// alarmRegisters reports the error codes E1-E16 as bits of input register 12209.
+func (a *AtreaEC5) alarmRegisters() []alarmRegister {
+	return []alarmRegister{{prefix: "E", bits: &a.errors}}
+}
+
+// State implements HRULogic.
+func (a *AtreaEC5) State() DeviceState {
+	return DeviceState{
+		"power":              &a.power,
+		"temperature":        &a.temperature,
+		"mode":               &a.mode,
+		"outdoorTemperature": &a.outdoorTemperature,
+		"supplyTemperature":  &a.supplyTemperature,
+		"extractTemperature": &a.extractTemperature,
+		"exhaustTemperature": &a.exhaustTemperature,
+		"supplyFanRPM":       &a.supplyFanRPM,
+		"extractFanRPM":      &a.extractFanRPM,
+		"filterHours":        &a.filterHours,
+		"operatingHours":     &a.operatingHours,
+		"errors":             &a.errors,
+		"firmwareVersion":    &a.firmwareVersion,
+		"frostProtection":    &a.frostProtection,
+		"co2":                &a.co2,
+		"co2Sensor":          &a.co2Sensor,
+		"fans":               &a.fans,
+		"program":            &a.program,
+	}
+}
+
+func (a *AtreaEC5) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "ATREA", ProductCode: "DUPLEX EC5", Revision: "2.20"})
+	program := RegisterMap{}
+	a.program.Apply(program)
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if a.program.contains(register) {
+			return program.Read(register, 1)
+		}
+		if register == 12000 {
+			return []uint16{uint16(a.power)}, &Success
+		}
+		if register == 12001 {
+			return []uint16{uint16(a.mode)}, &Success
+		}
+		if register == 12002 {
+			return []uint16{uint16(math.Round(a.temperature * 10))}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 12100 {
+			return []uint16{uint16(math.Round(a.fans.Power()))}, &Success
+		}
+		if register == 12101 {
+			return []uint16{uint16(a.mode)}, &Success
+		}
+		if register == 12102 {
+			return []uint16{uint16(math.Round(a.temperature * 10))}, &Success
+		}
+		if register == 12200 {
+			return []uint16{uint16(int16(math.Round(a.outdoorTemperature * 10)))}, &Success
+		}
+		if register == 12201 {
+			return []uint16{uint16(int16(math.Round(a.supplyTemperature * 10)))}, &Success
+		}
+		if register == 12202 {
+			return []uint16{uint16(int16(math.Round(a.extractTemperature * 10)))}, &Success
+		}
+		if register == 12203 {
+			return []uint16{uint16(int16(math.Round(a.exhaustTemperature * 10)))}, &Success
+		}
+		if register == 12204 {
+			return []uint16{uint16(a.supplyFanRPM)}, &Success
+		}
+		if register == 12205 {
+			return []uint16{uint16(a.extractFanRPM)}, &Success
+		}
+		if register == 12206 {
+			return []uint16{uint16(a.filterHours)}, &Success
+		}
+		if register == 12207 {
+			return RegisterCodec{}.Uint32(uint32(a.operatingHours)), &Success
+		}
+		if register == 12209 {
+			return []uint16{uint16(a.errors)}, &Success
+		}
+		if register == 12210 {
+			return []uint16{uint16(a.firmwareVersion)}, &Success
+		}
+		if register == 12211 {
+			return []uint16{uint16(a.currentCO2())}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if a.program.contains(register) {
+			return program.WriteSingle(register, value)
+		}
+		if register == 12000 {
+			if value > 100 {
+				return &IllegalDataValue
+			}
+			a.power = int(value)
+			log.Printf(">>> CHANGE: power=%d\n", a.power)
+			return &Success
+		}
+		if register == 12001 {
+			a.mode = int(value)
+			log.Printf(">>> CHANGE: mode=%d\n", a.mode)
+			return &Success
+		}
+		if register == 12002 {
+			a.temperature = float64(value) / 10.0
+			log.Printf(">>> CHANGE: temperature=%.1f\n", a.temperature)
+			return &Success
+		}
+		if register == 12300 {
+			if value != 1 {
+				return &IllegalDataValue
+			}
+			a.filterHours = 0
+			log.Printf(">>> CHANGE: filterHours=%d\n", a.filterHours)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	// only the week program takes block writes
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		if !a.program.contains(register) {
+			return &IllegalFunction
+		}
+		return program.Write(register, values)
+	})
+	OnReadExceptionStatus(serv, func() uint8 {
+		var status uint8
+		if a.errors != 0 {
+			status |= exceptionStatusFault
+		}
+		if a.filterAlarm() {
+			status |= exceptionStatusService
+		}
+		return status
+	})
+	// discrete inputs 0-2: alarm, filter change, frost protection active
+	OnReadDiscreteInputs(serv, func(address uint16, numInputs int) ([]bool, *Exception) {
+		inputs := []bool{a.errors != 0, a.filterAlarm(), a.frostProtection}
+		if int(address)+numInputs > len(inputs) {
+			return []bool{}, &IllegalDataAddress
+		}
+		return inputs[address : int(address)+numInputs], &Success
+	})
+}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"log"
-	"math"
+	"time"
 
 	. "github.com/tbrandon/mbserver"
 )
@@ -11,69 +11,99 @@ type AtreaRD5 struct {
 	power           int
 	temperature     float64
 	mode            int
-	editPower       bool
-	editTemperature bool
-	editMode        bool
+	alarm           bool
+	filterAlarm     bool
+	frostProtection bool
+	program         WeekProgram
 }
 
 func NewAtreaRD5() *AtreaRD5 {
 	return &AtreaRD5{
-		power:           50,
-		temperature:     26,
-		mode:            1,
-		editPower:       false,
-		editMode:        false,
-		editTemperature: false,
+		power:       50,
+		temperature: 26,
+		mode:        1,
+		program:     NewWeekProgram(11000, 7),
+	}
+}
+
+// Advance switches to the power and mode of the week program slot starting.
+func (a *AtreaRD5) Advance(now time.Time) {
+	if slot, ok := a.program.due(now); ok {
+		a.power, a.mode = slot.power, slot.mode
+		log.Printf(">>> CHANGE: power=%d, mode=%d, week program\n", a.power, a.mode)
+	}
+}
+
+// State implements HRULogic.
+func (a *AtreaRD5) State() DeviceState {
+	return DeviceState{
+		"power":           &a.power,
+		"temperature":     &a.temperature,
+		"mode":            &a.mode,
+		"alarm":           &a.alarm,
+		"filterAlarm":     &a.filterAlarm,
+		"frostProtection": &a.frostProtection,
+		"program":         &a.program,
 	}
 }
 
 func (a *AtreaRD5) Configure(serv *Server) {
-	OnReadHoldingRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
-		if (register == 10704 || register == 10708) && numRegs == 1 {
-			return []uint16{uint16(a.power)}, &Success
-		}
-		if (register == 10706 || register == 10710) && numRegs == 1 {
-			return []uint16{uint16(math.Round(a.temperature * 10))}, &Success
-		}
-		if (register == 10705 || register == 10709) && numRegs == 1 {
-			return []uint16{uint16(a.mode)}, &Success
-		}
-		return []uint16{}, &IllegalDataAddress
-	})
-	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
-		if register == 10700 && value == 0 {
-			a.editPower = true
-			return &Success
-		}
-		if register == 10702 && value == 0 {
-			a.editTemperature = true
-			return &Success
-		}
-		if register == 10701 && value == 0 {
-			a.editMode = true
-			return &Success
-		}
-		if register == 10708 && a.editPower {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "ATREA", ProductCode: "DUPLEX RD5", Revision: "1.22"})
+	// values are read at 10704-10706 and 10708-10710, writing one of the latter has to be unlocked
+	// by writing 0 to the matching register at 10700-10702 first
+	power := &Register{
+		Get: func() float64 { return float64(a.power) },
+		Set: func(value float64) *Exception {
 			a.power = int(value)
-			a.editPower = false
 			log.Printf(">>> CHANGE: power=%d\n", a.power)
 			return &Success
-		}
-		if register == 10710 && a.editTemperature {
-			a.temperature = float64(value / 10.0)
-			a.editTemperature = false
-			log.Printf(">>> CHANGE: temperature=%f\n", a.temperature)
-			return &Success
-		}
-		if register == 10709 && a.editMode {
+		},
+	}
+	mode := &Register{
+		Get: func() float64 { return float64(a.mode) },
+		Set: func(value float64) *Exception {
 			a.mode = int(value)
-			a.editMode = false
 			log.Printf(">>> CHANGE: mode=%d\n", a.mode)
 			return &Success
+		},
+	}
+	temperature := &Register{
+		Get: func() float64 { return a.temperature },
+		Set: func(value float64) *Exception {
+			a.temperature = value
+			log.Printf(">>> CHANGE: temperature=%f\n", a.temperature)
+			return &Success
+		},
+		Scale: 10,
+	}
+	registers := RegisterMap{
+		10704: power.ReadOnly(),
+		10705: mode.ReadOnly(),
+		10706: temperature.ReadOnly(),
+		10708: power,
+		10709: mode,
+		10710: temperature,
+	}
+	for i := uint16(0); i < 3; i++ {
+		sequence := &EditSequence{Unlock: EditWrite{Address: 10700 + i}, Payload: []uint16{10708 + i}}
+		sequence.Apply(registers)
+	}
+	a.program.Apply(registers)
+	OnReadHoldingRegisters(serv, registers.Read)
+	OnWriteHoldingRegister(serv, registers.WriteSingle)
+	// only the week program takes block writes
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		if !a.program.contains(register) {
+			return &IllegalFunction
 		}
-		return &IllegalDataAddress
+		return registers.Write(register, values)
 	})
-	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
-		return &IllegalFunction
+	// discrete inputs 0-2: alarm, filter change, frost protection active
+	OnReadDiscreteInputs(serv, func(address uint16, numInputs int) ([]bool, *Exception) {
+		inputs := []bool{a.alarm, a.filterAlarm, a.frostProtection}
+		if int(address)+numInputs > len(inputs) {
+			return []bool{}, &IllegalDataAddress
+		}
+		return inputs[address : int(address)+numInputs], &Success
 	})
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const (
+	blaubergDirectionExtract      = 0
+	blaubergDirectionRecuperation = 1
+	blaubergDirectionSupply       = 2
+)
+
+// BlaubergVento simulates a single-room Vento Expert push-pull unit.
+type BlaubergVento struct {
+	powerOn           bool
+	speed             int
+	manualSpeed       int
+	direction         int
+	timerMode         int
+	timerMinutes      int
+	humidity          int
+	humidityTrigger   bool
+	humiditySetpoint  int
+	humidityTriggered bool
+	// counted is the simulated time the timer is counted down to.
+	counted time.Time
+}
+
+func NewBlaubergVento() *BlaubergVento {
+	return &BlaubergVento{
+		powerOn:          true,
+		speed:            2,
+		manualSpeed:      128,
+		direction:        blaubergDirectionRecuperation,
+		timerMode:        0,
+		timerMinutes:     0,
+		humidity:         52,
+		humidityTrigger:  true,
+		humiditySetpoint: 60,
+		counted:          clock.Now(),
+	}
+}
+
+// Advance counts the night or party timer down, the unit leaves the timer mode when it runs out.
+func (b *BlaubergVento) Advance(now time.Time) {
+	minutes := int(now.Sub(b.counted) / time.Minute)
+	if minutes <= 0 {
+		return
+	}
+	b.counted = b.counted.Add(time.Duration(minutes) * time.Minute)
+	if b.timerMode == 0 {
+		return
+	}
+	b.timerMinutes = max(b.timerMinutes-minutes, 0)
+	if b.timerMinutes == 0 {
+		b.timerMode = 0
+		log.Printf(">>> CHANGE: timerMode=%d, timer ended\n", b.timerMode)
+	}
+}
+
+func (b *BlaubergVento) updateHumidityTrigger() {
+	b.humidityTriggered = b.humidityTrigger && b.humidity >= b.humiditySetpoint
+}
+
+// State implements HRULogic.
+func (b *BlaubergVento) State() DeviceState {
+	return DeviceState{
+		"powerOn":           &b.powerOn,
+		"speed":             &b.speed,
+		"manualSpeed":       &b.manualSpeed,
+		"direction":         &b.direction,
+		"timerMode":         &b.timerMode,
+		"timerMinutes":      &b.timerMinutes,
+		"humidity":          &b.humidity,
+		"humidityTrigger":   &b.humidityTrigger,
+		"humiditySetpoint":  &b.humiditySetpoint,
+		"humidityTriggered": &b.humidityTriggered,
+	}
+}
+
+func (b *BlaubergVento) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Blauberg", ProductCode: "VENTO Expert", Revision: "2.0.3"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 1 {
+			if b.powerOn {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		if register == 2 {
+			return []uint16{uint16(b.speed)}, &Success
+		}
+		if register == 3 {
+			return []uint16{uint16(b.manualSpeed)}, &Success
+		}
+		if register == 4 {
+			return []uint16{uint16(b.direction)}, &Success
+		}
+		if register == 5 {
+			return []uint16{uint16(b.timerMode)}, &Success
+		}
+		if register == 6 {
+			if b.humidityTrigger {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		if register == 7 {
+			return []uint16{uint16(b.humiditySetpoint)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 1 {
+			return []uint16{uint16(b.humidity)}, &Success
+		}
+		if register == 2 {
+			return []uint16{uint16(b.timerMinutes)}, &Success
+		}
+		if register == 3 {
+			if b.humidityTriggered {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 1 {
+			b.powerOn = value != 0
+			log.Printf(">>> CHANGE: powerOn=%v\n", b.powerOn)
+			return &Success
+		}
+		if register == 2 {
+			// 1-3 are the presets, 255 switches to the manual speed in register 3
+			if (value < 1 || value > 3) && value != 255 {
+				return &IllegalDataValue
+			}
+			b.speed = int(value)
+			log.Printf(">>> CHANGE: speed=%d\n", b.speed)
+			return &Success
+		}
+		if register == 3 {
+			if value > 255 {
+				return &IllegalDataValue
+			}
+			b.manualSpeed = int(value)
+			log.Printf(">>> CHANGE: manualSpeed=%d\n", b.manualSpeed)
+			return &Success
+		}
+		if register == 4 {
+			if value > blaubergDirectionSupply {
+				return &IllegalDataValue
+			}
+			b.direction = int(value)
+			log.Printf(">>> CHANGE: direction=%d\n", b.direction)
+			return &Success
+		}
+		if register == 5 {
+			// 0 = off, 1 = night mode (8 hours), 2 = party mode (4 hours)
+			switch value {
+			case 0:
+				b.timerMinutes = 0
+			case 1:
+				b.timerMinutes = 8 * 60
+			case 2:
+				b.timerMinutes = 4 * 60
+			default:
+				return &IllegalDataValue
+			}
+			b.timerMode = int(value)
+			log.Printf(">>> CHANGE: timerMode=%d, timerMinutes=%d\n", b.timerMode, b.timerMinutes)
+			return &Success
+		}
+		if register == 6 {
+			b.humidityTrigger = value != 0
+			b.updateHumidityTrigger()
+			log.Printf(">>> CHANGE: humidityTrigger=%v\n", b.humidityTrigger)
+			return &Success
+		}
+		if register == 7 {
+			if value < 40 || value > 80 {
+				return &IllegalDataValue
+			}
+			b.humiditySetpoint = int(value)
+			b.updateHumidityTrigger()
+			log.Printf(">>> CHANGE: humiditySetpoint=%d\n", b.humiditySetpoint)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+}
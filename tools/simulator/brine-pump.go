@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log"
+	"math"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const brinePumpCoilEnable = 0
+
+// BrinePump simulates the circulation pump controller of a ground-brine heat exchanger (GWC). While
+// the pump runs the brine leaving the ground loop is close to the ground temperature, when it stops
+// the brine in the pipes drifts towards the outdoor temperature.
+type BrinePump struct {
+	enabled            bool
+	speed              int
+	nominalFlow        float64
+	groundTemperature  float64
+	outdoorTemperature float64
+}
+
+func NewBrinePump() *BrinePump {
+	return &BrinePump{
+		enabled:            true,
+		speed:              60,
+		nominalFlow:        18,
+		groundTemperature:  8,
+		outdoorTemperature: -5,
+	}
+}
+
+// flow returns the brine flow in l/min.
+func (b *BrinePump) flow() float64 {
+	if !b.enabled {
+		return 0
+	}
+	return b.nominalFlow * float64(b.speed) / 100
+}
+
+func (b *BrinePump) inletTemperature() float64 {
+	if !b.enabled {
+		return b.outdoorTemperature
+	}
+	return b.groundTemperature - 1
+}
+
+func (b *BrinePump) outletTemperature() float64 {
+	if !b.enabled {
+		return b.outdoorTemperature
+	}
+	// the air heat exchanger takes more heat out of the brine the slower it flows
+	return b.inletTemperature() - 6*(1-float64(b.speed)/200)
+}
+
+// State implements HRULogic.
+func (b *BrinePump) State() DeviceState {
+	return DeviceState{
+		"enabled":            &b.enabled,
+		"speed":              &b.speed,
+		"nominalFlow":        &b.nominalFlow,
+		"groundTemperature":  &b.groundTemperature,
+		"outdoorTemperature": &b.outdoorTemperature,
+	}
+}
+
+func (b *BrinePump) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Luftuj", ProductCode: "GWC brine pump", Revision: "1.0.0"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(b.speed)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(math.Round(b.flow() * 10))}, &Success
+		}
+		if register == 1 {
+			return []uint16{uint16(int16(math.Round(b.inletTemperature() * 10)))}, &Success
+		}
+		if register == 2 {
+			return []uint16{uint16(int16(math.Round(b.outletTemperature() * 10)))}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 0 {
+			if value < 20 || value > 100 {
+				return &IllegalDataValue
+			}
+			b.speed = int(value)
+			log.Printf(">>> CHANGE: speed=%d, flow=%.1f\n", b.speed, b.flow())
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnReadCoils(serv, func(address uint16, numCoils int) ([]bool, *Exception) {
+		if address == brinePumpCoilEnable && numCoils == 1 {
+			return []bool{b.enabled}, &Success
+		}
+		return []bool{}, &IllegalDataAddress
+	})
+	OnWriteCoil(serv, func(address uint16, value bool) *Exception {
+		if address == brinePumpCoilEnable {
+			b.enabled = value
+			log.Printf(">>> CHANGE: enabled=%v, flow=%.1f\n", b.enabled, b.flow())
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// Bus routes requests to the simulated devices by their unit ID, the way devices share an RS485
+// bus or sit behind a Modbus TCP gateway.
+type Bus struct {
+	devices map[uint8]*Server
+	// fallback answers the unit IDs without a device of their own, nil leaves them unanswered
+	fallback *Server
+	// broadcast applies writes to unit ID 0 to every device without answering them
+	broadcast bool
+	// gatewayFault answers Modbus TCP requests as a gateway that cannot reach the devices, nil
+	// when disabled
+	gatewayFault      *Exception
+	gatewayFaultUnits map[uint8]bool
+	faults            Faults
+	// rebooting holds the unit IDs of the devices that are down for a reboot and don't answer
+	rebooting map[uint8]bool
+	// conns are the open TCP connections, closed by a reboot, and new ones are refused until
+	// refuseUntil. Both are guarded by connsLock.
+	conns       map[net.Conn]struct{}
+	refuseUntil time.Time
+	connsLock   sync.Mutex
+}
+
+// broadcastFunctions are the function codes a broadcast may carry, other broadcasts are ignored.
+var broadcastFunctions = map[uint8]bool{
+	FnWriteSingleCoil:       true,
+	FnWriteHoldingRegister:  true,
+	FnWriteMultipleCoils:    true,
+	FnWriteHoldingRegisters: true,
+	FnWriteFileRecord:       true,
+	FnMaskWriteRegister:     true,
+}
+
+func NewBus(broadcast bool) *Bus {
+	return &Bus{
+		devices:   map[uint8]*Server{},
+		broadcast: broadcast,
+		rebooting: map[uint8]bool{},
+		conns:     map[net.Conn]struct{}{},
+	}
+}
+
+// Add registers the device under unitID. Unit ID 0 makes the device answer every unit ID that has
+// no device of its own, which keeps single device setups working with any client configuration.
+// With broadcasts enabled unit ID 0 itself is the broadcast address.
+func (b *Bus) Add(unitID uint8, s *Server) error {
+	if unitID == 0 {
+		if b.fallback != nil {
+			return fmt.Errorf("a device without unit ID is already registered")
+		}
+		b.fallback = s
+		return nil
+	}
+	if _, ok := b.devices[unitID]; ok {
+		return fmt.Errorf("unit ID %d is already registered", unitID)
+	}
+	b.devices[unitID] = s
+	return nil
+}
+
+// Remove drops the device of the unit ID and the state kept for its server. While the bus is served
+// the caller holds the simulation lock, like for Add.
+func (b *Bus) Remove(unitID uint8) {
+	s := b.fallback
+	if unitID == 0 {
+		b.fallback = nil
+	} else {
+		s = b.devices[unitID]
+		delete(b.devices, unitID)
+	}
+	if s != nil {
+		releaseServer(s)
+	}
+}
+
+// SetGatewayFault makes Modbus TCP requests for the given unit IDs, or all of them when none are
+// given, fail with the gateway exception as if the downstream bus was broken.
+func (b *Bus) SetGatewayFault(exception *Exception, unitIDs []uint8) {
+	b.gatewayFault = exception
+	b.gatewayFaultUnits = map[uint8]bool{}
+	for _, unitID := range unitIDs {
+		b.gatewayFaultUnits[unitID] = true
+	}
+}
+
+// SetFaults sets the faults injected into the responses.
+func (b *Bus) SetFaults(faults Faults) {
+	b.faults = faults
+}
+
+// connect registers the connection, it returns false if connections are refused.
+func (b *Bus) connect(conn net.Conn) bool {
+	b.connsLock.Lock()
+	defer b.connsLock.Unlock()
+	if time.Now().Before(b.refuseUntil) {
+		return false
+	}
+	b.conns[conn] = struct{}{}
+	return true
+}
+
+func (b *Bus) disconnect(conn net.Conn) {
+	b.connsLock.Lock()
+	defer b.connsLock.Unlock()
+	delete(b.conns, conn)
+}
+
+// refuseConnections closes the open connections and refuses new ones for the duration.
+func (b *Bus) refuseConnections(duration time.Duration) {
+	b.connsLock.Lock()
+	defer b.connsLock.Unlock()
+	for conn := range b.conns {
+		conn.Close()
+	}
+	if until := time.Now().Add(duration); until.After(b.refuseUntil) {
+		b.refuseUntil = until
+	}
+}
+
+// resetConnection closes the connection with a TCP reset, the way a host without a listening
+// socket refuses it.
+func resetConnection(conn net.Conn) {
+	tcp := conn
+	for {
+		wrapper, ok := tcp.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		tcp = wrapper.NetConn()
+	}
+	if tcpConn, ok := tcp.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// servers returns every device on the bus that is up.
+func (b *Bus) servers() []*Server {
+	servers := make([]*Server, 0, len(b.devices)+1)
+	for unitID, s := range b.devices {
+		if !b.rebooting[unitID] {
+			servers = append(servers, s)
+		}
+	}
+	if b.fallback != nil && !b.rebooting[0] {
+		servers = append(servers, b.fallback)
+	}
+	return servers
+}
+
+// device returns the device answering the unit ID, nil if there is none or it is rebooting.
+func (b *Bus) device(unitID uint8) *Server {
+	if s, ok := b.devices[unitID]; ok {
+		if b.rebooting[unitID] {
+			return nil
+		}
+		return s
+	}
+	if b.rebooting[0] {
+		return nil
+	}
+	return b.fallback
+}
+
+// unitID returns the unit ID (MBAP) or slave address (RTU, ASCII) of the frame.
+func unitID(frame Framer) uint8 {
+	switch frame := frame.(type) {
+	case *TCPFrame:
+		return frame.Device
+	case *RTUFrame:
+		return frame.Address
+	case *ASCIIFrame:
+		return frame.Address
+	}
+	return 0
+}
+
+func (b *Bus) isBroadcast(request Framer) bool {
+	return b.broadcast && unitID(request) == 0
+}
+
+// handle dispatches the request to the device it is addressed to. Like on a serial bus, the
+// response is nil when there is no such device or the request is a broadcast. The response is
+// delayed by the latency of the device, without blocking the other devices meanwhile.
+func (b *Bus) handle(request Framer) Framer {
+	response, latency := b.route(request)
+	time.Sleep(latency)
+	return response
+}
+
+// route dispatches the request and returns the response with the latency of the device.
+func (b *Bus) route(request Framer) (Framer, time.Duration) {
+	simulation.Lock()
+	defer simulation.Unlock()
+
+	// every device sees every message on the bus
+	for _, s := range b.servers() {
+		serverDiagnostics(s).busMessages++
+	}
+	if b.isBroadcast(request) {
+		if !broadcastFunctions[request.GetFunction()] {
+			log.Printf("ignoring broadcast of function %d\n", request.GetFunction())
+			return nil, 0
+		}
+		for _, s := range b.servers() {
+			dispatch(s, request)
+			serverDiagnostics(s).serverNoResponses++
+		}
+		return nil, 0
+	}
+	s := b.device(unitID(request))
+	if s == nil || b.faults.silence(request) {
+		return nil, 0
+	}
+	return dispatch(s, request), responseLatencies[s].delay()
+}
+
+// handleGateway is handle for the Modbus TCP framings, where a gateway answers requests for unknown
+// unit IDs with the Gateway Target Device Failed to Respond exception.
+func (b *Bus) handleGateway(request Framer) Framer {
+	unit := unitID(request)
+	if b.gatewayFault != nil && (len(b.gatewayFaultUnits) == 0 || b.gatewayFaultUnits[unit]) {
+		return gatewayException(request, b.gatewayFault)
+	}
+	simulation.Lock()
+	missing := !b.isBroadcast(request) && b.device(unit) == nil
+	simulation.Unlock()
+	if missing {
+		return gatewayException(request, &GatewayTargetDeviceFailedtoRespond)
+	}
+	return b.handle(request)
+}
+
+func gatewayException(request Framer, exception *Exception) Framer {
+	response := request.Copy()
+	response.SetException(exception)
+	return response
+}
@@ -0,0 +1,22 @@
+package main
+
+// SummerBypass decides the automatic summer bypass of a heat recovery unit: it opens when the rooms
+// are warmer than the comfort temperature and the outdoor air is cooler than them, so it can cool the
+// rooms, but not below MinOutdoor, to avoid cold drafts. The temperatures have to move back past the
+// thresholds by Hysteresis before it closes again, so it doesn't flap around them.
+type SummerBypass struct {
+	MinOutdoor float64
+	Hysteresis float64
+}
+
+func NewSummerBypass() SummerBypass {
+	return SummerBypass{MinOutdoor: 12, Hysteresis: 1}
+}
+
+// open returns whether the bypass is open with the temperatures, given whether it was open before.
+func (b SummerBypass) open(wasOpen bool, indoor, outdoor, comfort float64) bool {
+	if wasOpen {
+		return indoor >= comfort-b.Hysteresis && outdoor >= b.MinOutdoor-b.Hysteresis && outdoor < indoor
+	}
+	return indoor > comfort && outdoor >= b.MinOutdoor && outdoor < indoor-b.Hysteresis
+}
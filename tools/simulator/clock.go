@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Clock is the time of the simulated devices. It follows the wall clock, but stands still while the
+// simulation is paused, so damper travel, pollution events, watchdogs, weather replays and scenarios
+// freeze and the registers keep answering with the values of that moment. The scale speeds it up,
+// so filter counters and timers of hours or days elapse within seconds (--time-scale).
+type Clock struct {
+	lock sync.Mutex
+	// simulated is the simulated time at anchor, the wall clock time of the last change of the clock.
+	simulated time.Time
+	anchor    time.Time
+	paused    bool
+	scale     float64
+	// changed is closed and replaced on every change of the clock, to wake up Wait.
+	changed chan struct{}
+}
+
+// clock is the time of all devices.
+var clock = NewClock()
+
+// Timed is implemented by devices whose state runs with the simulated time, like filter counters
+// and boost timers. Advance brings the state up to now, it is called before every request and every
+// access to the state, with the simulation lock held.
+type Timed interface {
+	Advance(now time.Time)
+}
+
+func NewClock() *Clock {
+	now := time.Now()
+	return &Clock{simulated: now, anchor: now, scale: 1, changed: make(chan struct{})}
+}
+
+// Now returns the simulated time.
+func (c *Clock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now()
+}
+
+func (c *Clock) now() time.Time {
+	if c.paused {
+		return c.simulated
+	}
+	return c.simulated.Add(time.Duration(float64(time.Since(c.anchor)) * c.scale))
+}
+
+// Since returns the simulated time elapsed since t.
+func (c *Clock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *Clock) Paused() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.paused
+}
+
+// SetPaused pauses or resumes the simulated time.
+func (c *Clock) SetPaused(paused bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.paused == paused {
+		return
+	}
+	c.reanchor()
+	c.paused = paused
+	if paused {
+		log.Printf(">>> CLOCK: paused\n")
+	} else {
+		log.Printf(">>> CLOCK: resumed\n")
+	}
+}
+
+// SetScale sets how many times faster than the wall clock the simulated time runs, it has to be
+// positive.
+func (c *Clock) SetScale(scale float64) error {
+	if scale <= 0 || math.IsInf(scale, 0) || math.IsNaN(scale) {
+		return fmt.Errorf("invalid time scale %v, it has to be positive", scale)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.scale == scale {
+		return nil
+	}
+	c.reanchor()
+	c.scale = scale
+	log.Printf(">>> CLOCK: time scale %v\n", scale)
+	return nil
+}
+
+// reanchor starts counting from the current simulated time, before the clock changes. The caller
+// holds the clock lock.
+func (c *Clock) reanchor() {
+	c.simulated = c.now()
+	c.anchor = time.Now()
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Wait blocks until the simulated time reaches t, it returns false if stop is closed before.
+func (c *Clock) Wait(t time.Time, stop <-chan struct{}) bool {
+	for {
+		c.lock.Lock()
+		remaining, paused, scale, changed := t.Sub(c.now()), c.paused, c.scale, c.changed
+		c.lock.Unlock()
+		if remaining <= 0 {
+			return true
+		}
+		var elapsed <-chan time.Time
+		if !paused {
+			elapsed = time.After(time.Duration(float64(remaining) / scale))
+		}
+		select {
+		case <-elapsed:
+		case <-changed:
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// ClockState is the state of the clock served by the API.
+type ClockState struct {
+	Time      time.Time `json:"time"`
+	Paused    bool      `json:"paused"`
+	TimeScale float64   `json:"timeScale"`
+}
+
+func (c *Clock) State() ClockState {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return ClockState{Time: c.now(), Paused: c.paused, TimeScale: c.scale}
+}
+
+func (a *API) getClock(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, clock.State())
+}
+
+func (a *API) patchClock(w http.ResponseWriter, r *http.Request) {
+	var changes struct {
+		Paused    *bool    `json:"paused"`
+		TimeScale *float64 `json:"timeScale"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid clock: %w", err))
+		return
+	}
+	if changes.TimeScale != nil {
+		if err := clock.SetScale(*changes.TimeScale); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if changes.Paused != nil {
+		clock.SetPaused(*changes.Paused)
+	}
+	writeJSON(w, http.StatusOK, clock.State())
+}
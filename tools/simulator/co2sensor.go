@@ -0,0 +1,105 @@
+package main
+
+import (
+	"log"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const (
+	co2SensorCoilCalibrate = 0
+	co2SensorCoilABC       = 1
+
+	co2SensorOutdoorPPM = 400
+)
+
+// CO2Sensor simulates a standalone NDIR CO2 transmitter. Triggering the calibration coil performs a
+// fresh-air calibration, i.e. the current reading becomes the outdoor baseline of 400 ppm.
+type CO2Sensor struct {
+	concentration int
+	offset        int
+	abc           bool
+	calibrations  int
+}
+
+func NewCO2Sensor() *CO2Sensor {
+	return &CO2Sensor{
+		concentration: 650,
+		offset:        0,
+		abc:           true,
+	}
+}
+
+func (c *CO2Sensor) ppm() int {
+	return max(c.concentration+c.offset, 0)
+}
+
+// CO2 returns the reading, for units in demand control following the sensor.
+func (c *CO2Sensor) CO2() int {
+	return c.ppm()
+}
+
+// State implements HRULogic.
+func (c *CO2Sensor) State() DeviceState {
+	return DeviceState{
+		"concentration": &c.concentration,
+		"offset":        &c.offset,
+		"abc":           &c.abc,
+		"calibrations":  &c.calibrations,
+	}
+}
+
+func (c *CO2Sensor) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Luftuj", ProductCode: "CO2 sensor", Revision: "1.0.0"})
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0x0000 {
+			return []uint16{uint16(c.ppm())}, &Success
+		}
+		if register == 0x0001 {
+			return []uint16{uint16(c.calibrations)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0x0010 {
+			return []uint16{uint16(int16(c.offset))}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 0x0010 {
+			c.offset = int(int16(value))
+			log.Printf(">>> CHANGE: offset=%d, ppm=%d\n", c.offset, c.ppm())
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnReadCoils(serv, func(address uint16, numCoils int) ([]bool, *Exception) {
+		if address == co2SensorCoilCalibrate && numCoils == 1 {
+			return []bool{false}, &Success
+		}
+		if address == co2SensorCoilABC && numCoils == 1 {
+			return []bool{c.abc}, &Success
+		}
+		return []bool{}, &IllegalDataAddress
+	})
+	OnWriteCoil(serv, func(address uint16, value bool) *Exception {
+		if address == co2SensorCoilCalibrate {
+			if value {
+				c.offset = co2SensorOutdoorPPM - c.concentration
+				c.calibrations++
+				log.Printf(">>> CHANGE: calibrated, offset=%d, ppm=%d\n", c.offset, c.ppm())
+			}
+			return &Success
+		}
+		if address == co2SensorCoilABC {
+			c.abc = value
+			log.Printf(">>> CHANGE: abc=%v\n", c.abc)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+}
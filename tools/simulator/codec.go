@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+)
+
+// WordOrder is the order in which the bytes A (most significant) to D of a 32-bit value are
+// stored in a register pair. The byte swap of BADC and DCBA also applies to 16-bit values and
+// strings.
+type WordOrder int
+
+const (
+	OrderABCD WordOrder = iota // big endian, the Modbus default
+	OrderCDAB                  // low word first
+	OrderBADC                  // bytes swapped within each word
+	OrderDCBA                  // little endian
+)
+
+// RegisterCodec converts typed values to registers and back in one byte and word order, so devices
+// don't have to hand-roll the uint16 math. The zero value uses OrderABCD.
+type RegisterCodec struct {
+	Order WordOrder
+}
+
+func (c RegisterCodec) swapsBytes() bool {
+	return c.Order == OrderBADC || c.Order == OrderDCBA
+}
+
+// permute reorders big endian bytes to the codec order and back, every order is its own inverse.
+func (c RegisterCodec) permute(b []byte) {
+	switch c.Order {
+	case OrderCDAB:
+		b[0], b[1], b[2], b[3] = b[2], b[3], b[0], b[1]
+	case OrderBADC:
+		b[0], b[1], b[2], b[3] = b[1], b[0], b[3], b[2]
+	case OrderDCBA:
+		b[0], b[1], b[2], b[3] = b[3], b[2], b[1], b[0]
+	}
+}
+
+func (c RegisterCodec) Uint16(value uint16) []uint16 {
+	if c.swapsBytes() {
+		value = value<<8 | value>>8
+	}
+	return []uint16{value}
+}
+
+func (c RegisterCodec) Int16(value int16) []uint16 {
+	return c.Uint16(uint16(value))
+}
+
+// Scaled encodes value multiplied by scale as a signed 16-bit integer, e.g. temperatures in 0.1 °C
+// with scale 10.
+func (c RegisterCodec) Scaled(value float64, scale float64) []uint16 {
+	return c.Int16(int16(math.Round(value * scale)))
+}
+
+func (c RegisterCodec) Uint32(value uint32) []uint16 {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, value)
+	c.permute(b)
+	return []uint16{binary.BigEndian.Uint16(b[0:2]), binary.BigEndian.Uint16(b[2:4])}
+}
+
+func (c RegisterCodec) Int32(value int32) []uint16 {
+	return c.Uint32(uint32(value))
+}
+
+// Float32 encodes value as an IEEE 754 single precision float.
+func (c RegisterCodec) Float32(value float64) []uint16 {
+	return c.Uint32(math.Float32bits(float32(value)))
+}
+
+// String encodes value as ASCII, two characters per register, padded with NUL to numRegs registers
+// and truncated if longer.
+func (c RegisterCodec) String(value string, numRegs int) []uint16 {
+	b := make([]byte, 2*numRegs)
+	copy(b, value)
+	registers := make([]uint16, numRegs)
+	for i := range registers {
+		registers[i] = c.Uint16(binary.BigEndian.Uint16(b[2*i:]))[0]
+	}
+	return registers
+}
+
+// DecodeUint16 decodes the first register.
+func (c RegisterCodec) DecodeUint16(registers []uint16) uint16 {
+	return c.Uint16(registers[0])[0]
+}
+
+func (c RegisterCodec) DecodeInt16(registers []uint16) int16 {
+	return int16(c.DecodeUint16(registers))
+}
+
+// DecodeScaled is the inverse of Scaled.
+func (c RegisterCodec) DecodeScaled(registers []uint16, scale float64) float64 {
+	return float64(c.DecodeInt16(registers)) / scale
+}
+
+// DecodeUint32 decodes the first two registers.
+func (c RegisterCodec) DecodeUint32(registers []uint16) uint32 {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint16(b[0:2], registers[0])
+	binary.BigEndian.PutUint16(b[2:4], registers[1])
+	c.permute(b)
+	return binary.BigEndian.Uint32(b)
+}
+
+func (c RegisterCodec) DecodeInt32(registers []uint16) int32 {
+	return int32(c.DecodeUint32(registers))
+}
+
+func (c RegisterCodec) DecodeFloat32(registers []uint16) float64 {
+	return float64(math.Float32frombits(c.DecodeUint32(registers)))
+}
+
+// DecodeString decodes ASCII registers, trailing NUL and space padding is removed.
+func (c RegisterCodec) DecodeString(registers []uint16) string {
+	b := make([]byte, 2*len(registers))
+	for i, register := range registers {
+		binary.BigEndian.PutUint16(b[2*i:], c.Uint16(register)[0])
+	}
+	return strings.TrimRight(string(b), "\x00 ")
+}
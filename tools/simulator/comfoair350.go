@@ -0,0 +1,191 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// ComfoAir350 simulates the legacy Zehnder ComfoAir 350 behind a Modbus gateway. The gateway
+// passes the serial protocol values through, so temperatures use its (T + 20) * 2 encoding.
+type ComfoAir350 struct {
+	level              int
+	comfortTemperature float64
+	outdoorTemperature float64
+	supplyTemperature  float64
+	extractTemperature float64
+	exhaustTemperature float64
+	bypassPercent      int
+	filterDirty        bool
+	filterHours        int
+	filterLifetime     int
+	supplyFanPercents  [4]int
+	extractFanPercents [4]int
+	// counted is the simulated time the filter hours are counted up to.
+	counted time.Time
+	bypass  SummerBypass
+}
+
+func NewComfoAir350() *ComfoAir350 {
+	return &ComfoAir350{
+		level:              2,
+		comfortTemperature: 21,
+		outdoorTemperature: 9.5,
+		supplyTemperature:  18.0,
+		extractTemperature: 21.5,
+		exhaustTemperature: 12.5,
+		bypassPercent:      0,
+		filterDirty:        false,
+		filterHours:        1400,
+		filterLifetime:     2000,
+		supplyFanPercents:  [4]int{15, 35, 50, 70},
+		extractFanPercents: [4]int{15, 35, 50, 70},
+		counted:            clock.Now(),
+		bypass:             NewSummerBypass(),
+	}
+}
+
+// Advance opens and closes the summer bypass, counts the filter hours, the fans never stop, and marks
+// the filter dirty when they reach the lifetime.
+func (c *ComfoAir350) Advance(now time.Time) {
+	c.updateBypass()
+	hours := int(now.Sub(c.counted) / time.Hour)
+	if hours <= 0 {
+		return
+	}
+	c.counted = c.counted.Add(time.Duration(hours) * time.Hour)
+	c.filterHours += hours
+	if c.filterHours >= c.filterLifetime && !c.filterDirty {
+		c.filterDirty = true
+		log.Printf(">>> CHANGE: filterDirty=%v\n", c.filterDirty)
+	}
+}
+
+func comfoAirTemperature(value float64) uint16 {
+	return uint16(math.Round((value + 20) * 2))
+}
+
+// updateBypass opens the bypass fully when the rooms, measured in the extract air, are warmer than the
+// comfort temperature and the outdoor air can cool them.
+func (c *ComfoAir350) updateBypass() {
+	open := c.bypass.open(c.bypassPercent > 0, c.extractTemperature, c.outdoorTemperature, c.comfortTemperature)
+	if open == (c.bypassPercent > 0) {
+		return
+	}
+	c.bypassPercent = 0
+	if open {
+		c.bypassPercent = 100
+	}
+	log.Printf(">>> CHANGE: bypassPercent=%d\n", c.bypassPercent)
+}
+
+// State implements HRULogic.
+func (c *ComfoAir350) State() DeviceState {
+	return DeviceState{
+		"level":              &c.level,
+		"comfortTemperature": &c.comfortTemperature,
+		"outdoorTemperature": &c.outdoorTemperature,
+		"supplyTemperature":  &c.supplyTemperature,
+		"extractTemperature": &c.extractTemperature,
+		"exhaustTemperature": &c.exhaustTemperature,
+		"bypassPercent":      &c.bypassPercent,
+		"filterDirty":        &c.filterDirty,
+		"filterHours":        &c.filterHours,
+		"filterLifetime":     &c.filterLifetime,
+		"supplyFanPercents":  &c.supplyFanPercents,
+		"extractFanPercents": &c.extractFanPercents,
+	}
+}
+
+func (c *ComfoAir350) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Zehnder", ProductCode: "ComfoAir 350", Revision: "3.60"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0x10 {
+			return []uint16{uint16(c.level)}, &Success
+		}
+		if register == 0x11 {
+			return []uint16{comfoAirTemperature(c.comfortTemperature)}, &Success
+		}
+		if register == 0x13 {
+			return []uint16{uint16(c.filterLifetime)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0x20 {
+			return []uint16{comfoAirTemperature(c.outdoorTemperature)}, &Success
+		}
+		if register == 0x21 {
+			return []uint16{comfoAirTemperature(c.supplyTemperature)}, &Success
+		}
+		if register == 0x22 {
+			return []uint16{comfoAirTemperature(c.extractTemperature)}, &Success
+		}
+		if register == 0x23 {
+			return []uint16{comfoAirTemperature(c.exhaustTemperature)}, &Success
+		}
+		if register == 0x24 {
+			return []uint16{uint16(c.supplyFanPercents[c.level-1])}, &Success
+		}
+		if register == 0x25 {
+			return []uint16{uint16(c.extractFanPercents[c.level-1])}, &Success
+		}
+		if register == 0x26 {
+			return []uint16{uint16(c.bypassPercent)}, &Success
+		}
+		if register == 0x27 {
+			if c.filterDirty {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		if register == 0x28 {
+			return []uint16{uint16(c.filterHours)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 0x10 {
+			// 1 = away, 2 = low, 3 = medium, 4 = high
+			if value < 1 || value > 4 {
+				return &IllegalDataValue
+			}
+			c.level = int(value)
+			log.Printf(">>> CHANGE: level=%d\n", c.level)
+			return &Success
+		}
+		if register == 0x11 {
+			c.comfortTemperature = float64(value)/2 - 20
+			log.Printf(">>> CHANGE: comfortTemperature=%.1f\n", c.comfortTemperature)
+			c.updateBypass()
+			return &Success
+		}
+		if register == 0x12 && value == 1 {
+			c.filterDirty = false
+			c.filterHours = 0
+			log.Printf(">>> CHANGE: filterDirty=%v, filterHours=%d\n", c.filterDirty, c.filterHours)
+			return &Success
+		}
+		if register == 0x13 {
+			if value == 0 {
+				return &IllegalDataValue
+			}
+			c.filterLifetime = int(value)
+			log.Printf(">>> CHANGE: filterLifetime=%d\n", c.filterLifetime)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnReadExceptionStatus(serv, func() uint8 {
+		var status uint8
+		if c.filterDirty {
+			status |= exceptionStatusService
+		}
+		return status
+	})
+}
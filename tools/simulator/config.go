@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/goburrow/serial"
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes a test bench: the devices on the bus and the listeners serving it. It is loaded
+// with --config, otherwise built from the command line.
+type Config struct {
+	// Broadcast is on, off or auto (default): writes to unit ID 0 are broadcasts if a listener uses a
+	// serial framing.
+	Broadcast string           `json:"broadcast" yaml:"broadcast"`
+	Listeners []ListenerConfig `json:"listeners" yaml:"listeners"`
+	Devices   []DeviceConfig   `json:"devices" yaml:"devices"`
+}
+
+// ListenerConfig is a port serving the bus: a TCP port, a serial port or a pseudo terminal.
+type ListenerConfig struct {
+	// Port is the TCP port, framed as tcp (default), rtu-over-tcp or ascii-over-tcp. Bind is the
+	// address it listens on, all interfaces by default.
+	Port string `json:"port" yaml:"port"`
+	Bind string `json:"bind" yaml:"bind"`
+	// UDP also serves Modbus UDP on the port.
+	UDP bool `json:"udp" yaml:"udp"`
+	// SingleConnection accepts one TCP client at a time like many embedded Modbus TCP stacks: reject
+	// resets other connections, drop accepts and closes them. Empty allows any number.
+	SingleConnection string `json:"singleConnection" yaml:"singleConnection"`
+	// Serial is the serial device, PTY creates a pseudo terminal linked at PTYLink if set. Both are
+	// framed as rtu (default) or ascii.
+	Serial  string `json:"serial" yaml:"serial"`
+	PTY     bool   `json:"pty" yaml:"pty"`
+	PTYLink string `json:"ptyLink" yaml:"ptyLink"`
+	Framing string `json:"framing" yaml:"framing"`
+	// The line settings of serial ports and pseudo terminals, 19200 8E1 by default.
+	BaudRate int    `json:"baudRate" yaml:"baudRate"`
+	DataBits int    `json:"dataBits" yaml:"dataBits"`
+	Parity   string `json:"parity" yaml:"parity"`
+	StopBits int    `json:"stopBits" yaml:"stopBits"`
+}
+
+// DeviceConfig is a device on the bus. File is the file argument of the generic, lua and
+// weather-station types, Parameters tune the devices built with them:
+//
+//	atrea-am          maxPower (380 m³/h)
+//	atrea-ec5         co2Sensor (unit ID of the co2sensor followed in automatic mode, none by default)
+//	ducobox           zones (3)
+//	aereco-dxr        zones (4)
+//	rht-sensor        humidityNoise (1.5 %), temperatureNoise (0.2 °C)
+//
+// Latency delays the responses of the device, see Latency, --latency applies to devices without one.
+// Revert restores holding registers some time after they were written, see RegisterRevert.
+type DeviceConfig struct {
+	Unit       uint8              `json:"unit" yaml:"unit"`
+	Type       string             `json:"type" yaml:"type"`
+	File       string             `json:"file" yaml:"file"`
+	Parameters map[string]float64 `json:"parameters" yaml:"parameters"`
+	Latency    Latency            `json:"latency" yaml:"latency"`
+	Revert     []RegisterRevert   `json:"revert" yaml:"revert"`
+}
+
+// LoadConfig reads a JSON or YAML test bench description.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &config)
+	default:
+		err = json.Unmarshal(data, &config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(config.Listeners) == 0 {
+		return nil, fmt.Errorf("%s: no listeners", path)
+	}
+	if len(config.Devices) == 0 {
+		return nil, fmt.Errorf("%s: no devices", path)
+	}
+	units := map[uint8]bool{}
+	for _, device := range config.Devices {
+		if device.Unit > 247 {
+			return nil, fmt.Errorf("%s: invalid unit ID %d, expected 0-247", path, device.Unit)
+		}
+		if units[device.Unit] {
+			return nil, fmt.Errorf("%s: duplicate unit ID %d", path, device.Unit)
+		}
+		units[device.Unit] = true
+	}
+	return &config, nil
+}
+
+// Duration is a time.Duration written as a string in config files, e.g. "30s" or "1m30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	*d = Duration(duration)
+	return err
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (l *ListenerConfig) isSerial() bool {
+	return l.Serial != "" || l.PTY
+}
+
+// broadcastWrites resolves the broadcast mode of the config.
+func (c *Config) broadcastWrites() (bool, error) {
+	switch c.Broadcast {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	case "", "auto":
+		for _, listener := range c.Listeners {
+			if listener.isSerial() || listener.Framing == "rtu-over-tcp" || listener.Framing == "ascii-over-tcp" {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown broadcast mode '%s'. Valid options: on, off, auto", c.Broadcast)
+}
+
+// Listen serves the bus as configured, tlsConfig is used for the tcp framing if set. It returns
+// where the bus is served.
+func (l *ListenerConfig) Listen(bus *Bus, tlsConfig *tls.Config) (string, error) {
+	if l.isSerial() {
+		if l.SingleConnection != "" {
+			return "", fmt.Errorf("single connection is for TCP listeners, not serial ports or pseudo terminals")
+		}
+		var serve serveFunc
+		switch l.Framing {
+		case "", "rtu":
+			serve = serveRTU
+		case "ascii":
+			serve = serveASCII
+		default:
+			return "", fmt.Errorf("unknown serial framing '%s'. Valid options: rtu, ascii", l.Framing)
+		}
+		config := &serial.Config{
+			Address:  l.Serial,
+			BaudRate: l.BaudRate,
+			DataBits: l.DataBits,
+			Parity:   l.Parity,
+			StopBits: l.StopBits,
+			Timeout:  100 * time.Millisecond,
+		}
+		if config.BaudRate == 0 {
+			config.BaudRate = 19200
+		}
+		if config.DataBits == 0 {
+			config.DataBits = 8
+		}
+		if config.Parity == "" {
+			config.Parity = "E"
+		}
+		if config.StopBits == 0 {
+			config.StopBits = 1
+		}
+		if l.PTY {
+			return ListenPTY(bus, l.PTYLink, config, serve)
+		}
+		return l.Serial, ListenSerial(bus, config, serve)
+	}
+
+	if l.Port == "" {
+		return "", fmt.Errorf("listener without port, serial device or pty")
+	}
+	bind := l.Bind
+	if bind == "" {
+		bind = "0.0.0.0"
+	}
+	address := net.JoinHostPort(bind, l.Port)
+	var policy ConnectionPolicy
+	switch l.SingleConnection {
+	case "":
+		policy = MultipleConnections
+	case "reject":
+		policy = SingleConnectionReject
+	case "drop":
+		policy = SingleConnectionDrop
+	default:
+		return "", fmt.Errorf("unknown single connection mode '%s'. Valid options: reject, drop", l.SingleConnection)
+	}
+	var err error
+	switch l.Framing {
+	case "", "tcp":
+		if tlsConfig != nil {
+			err = ListenTLS(bus, address, policy, tlsConfig)
+		} else {
+			err = ListenStreamTCP(bus, address, policy, serveTCP)
+		}
+	case "rtu-over-tcp":
+		err = ListenStreamTCP(bus, address, policy, serveRTU)
+	case "ascii-over-tcp":
+		err = ListenStreamTCP(bus, address, policy, serveASCII)
+	default:
+		err = fmt.Errorf("unknown framing '%s'. Valid options: tcp, rtu-over-tcp, ascii-over-tcp", l.Framing)
+	}
+	if err == nil && l.UDP {
+		err = ListenUDP(bus, address)
+	}
+	if l.Bind != "" {
+		return address, err
+	}
+	return l.Port, err
+}
+
+// deviceParameters hands out the parameters of a device and remembers which ones were used.
+type deviceParameters struct {
+	values map[string]float64
+	used   map[string]bool
+}
+
+// get returns the parameter, or the default if it is not set.
+func (p *deviceParameters) get(name string, defaultValue float64) float64 {
+	p.used[name] = true
+	if value, ok := p.values[name]; ok {
+		return value
+	}
+	return defaultValue
+}
+
+// unknown returns the parameters the device did not ask for.
+func (p *deviceParameters) unknown() []string {
+	var names []string
+	for name := range p.values {
+		if !p.used[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"log"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// The Daikin DIII-Net Modbus adapter publishes every indoor unit as a block of registers. The
+// simulated VAM is connected as unit 0, so its control block starts at holding register 2000 and
+// its status block at input register 2000.
+const (
+	daikinControlBase = 2000
+	daikinStatusBase  = 2000
+)
+
+type DaikinVAM struct {
+	powerOn           bool
+	ventilationMode   int
+	ventilationAmount int
+	filterSign        bool
+	errorCode         int
+	roomTemperature   int
+}
+
+func NewDaikinVAM() *DaikinVAM {
+	return &DaikinVAM{
+		powerOn:           true,
+		ventilationMode:   0,
+		ventilationAmount: 1,
+		filterSign:        false,
+		errorCode:         0,
+		roomTemperature:   225,
+	}
+}
+
+// alarmRegisters reports the number of the active error in the status input registers.
+func (d *DaikinVAM) alarmRegisters() []alarmRegister {
+	return []alarmRegister{{code: &d.errorCode}}
+}
+
+// State implements HRULogic.
+func (d *DaikinVAM) State() DeviceState {
+	return DeviceState{
+		"powerOn":           &d.powerOn,
+		"ventilationMode":   &d.ventilationMode,
+		"ventilationAmount": &d.ventilationAmount,
+		"filterSign":        &d.filterSign,
+		"errorCode":         &d.errorCode,
+		"roomTemperature":   &d.roomTemperature,
+	}
+}
+
+func (d *DaikinVAM) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Daikin", ProductCode: "VAM-J", Revision: "1.06"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == daikinControlBase {
+			if d.powerOn {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		if register == daikinControlBase+1 {
+			return []uint16{uint16(d.ventilationMode)}, &Success
+		}
+		if register == daikinControlBase+2 {
+			return []uint16{uint16(d.ventilationAmount)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 1 {
+			// bit mask of connected units on the DIII-Net bus
+			return []uint16{0x1}, &Success
+		}
+		if register == daikinStatusBase {
+			var res uint16
+			if d.powerOn {
+				res |= 0x1
+			}
+			if d.filterSign {
+				res |= 0x2
+			}
+			return []uint16{res}, &Success
+		}
+		if register == daikinStatusBase+1 {
+			return []uint16{uint16(d.ventilationMode)}, &Success
+		}
+		if register == daikinStatusBase+2 {
+			return []uint16{uint16(d.ventilationAmount)}, &Success
+		}
+		if register == daikinStatusBase+3 {
+			return []uint16{uint16(d.errorCode)}, &Success
+		}
+		if register == daikinStatusBase+4 {
+			return []uint16{uint16(d.roomTemperature)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == daikinControlBase {
+			d.powerOn = value&0x1 != 0
+			log.Printf(">>> CHANGE: powerOn=%v\n", d.powerOn)
+			return &Success
+		}
+		if register == daikinControlBase+1 {
+			// 0 = automatic, 1 = heat exchange, 2 = bypass
+			if value > 2 {
+				return &IllegalDataValue
+			}
+			d.ventilationMode = int(value)
+			log.Printf(">>> CHANGE: ventilationMode=%d\n", d.ventilationMode)
+			return &Success
+		}
+		if register == daikinControlBase+2 {
+			// 0 = automatic, 1 = low, 2 = high, 3 = low fresh up, 4 = high fresh up
+			if value > 4 {
+				return &IllegalDataValue
+			}
+			d.ventilationAmount = int(value)
+			log.Printf(">>> CHANGE: ventilationAmount=%d\n", d.ventilationAmount)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnReadExceptionStatus(serv, func() uint8 {
+		var status uint8
+		if d.errorCode != 0 {
+			status |= exceptionStatusFault
+		}
+		if d.filterSign {
+			status |= exceptionStatusService
+		}
+		return status
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const (
+	damperOverrideNone  = 0
+	damperOverrideOpen  = 1
+	damperOverrideClose = 2
+)
+
+// Damper simulates a Belimo-style damper actuator. Positions are in 0.01 %, the feedback moves
+// towards the setpoint at a constant speed given by the full stroke travel time.
+type Damper struct {
+	setpoint      int
+	override      int
+	travelSeconds int
+	startPosition float64
+	startTime     time.Time
+}
+
+func NewDamper() *Damper {
+	return &Damper{
+		setpoint:      5000,
+		override:      damperOverrideNone,
+		travelSeconds: 90,
+		startPosition: 5000,
+		startTime:     clock.Now(),
+	}
+}
+
+func (d *Damper) target() int {
+	switch d.override {
+	case damperOverrideOpen:
+		return 10000
+	case damperOverrideClose:
+		return 0
+	}
+	return d.setpoint
+}
+
+func (d *Damper) position() float64 {
+	travelled := clock.Since(d.startTime).Seconds() / float64(d.travelSeconds) * 10000
+	target := float64(d.target())
+	if target > d.startPosition {
+		return math.Min(d.startPosition+travelled, target)
+	}
+	return math.Max(d.startPosition-travelled, target)
+}
+
+// retarget freezes the current position as the start of a new movement, it has to be called
+// before anything that changes the target.
+func (d *Damper) retarget() {
+	d.startPosition = d.position()
+	d.startTime = clock.Now()
+}
+
+func (d *Damper) moving() bool {
+	return math.Round(d.position()) != float64(d.target())
+}
+
+// State implements HRULogic.
+func (d *Damper) State() DeviceState {
+	return DeviceState{
+		"setpoint":      &d.setpoint,
+		"override":      &d.override,
+		"travelSeconds": &d.travelSeconds,
+		"startPosition": &d.startPosition,
+		"startTime":     &d.startTime,
+	}
+}
+
+func (d *Damper) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Luftuj", ProductCode: "Motorized damper", Revision: "1.0.0"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(d.setpoint)}, &Success
+		}
+		if register == 1 {
+			return []uint16{uint16(d.override)}, &Success
+		}
+		if register == 10 {
+			return []uint16{uint16(d.travelSeconds)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(math.Round(d.position()))}, &Success
+		}
+		if register == 1 {
+			if d.moving() {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 0 {
+			if value > 10000 {
+				return &IllegalDataValue
+			}
+			d.retarget()
+			d.setpoint = int(value)
+			log.Printf(">>> CHANGE: setpoint=%d\n", d.setpoint)
+			return &Success
+		}
+		if register == 1 {
+			if value > damperOverrideClose {
+				return &IllegalDataValue
+			}
+			d.retarget()
+			d.override = int(value)
+			log.Printf(">>> CHANGE: override=%d\n", d.override)
+			return &Success
+		}
+		if register == 10 {
+			if value < 10 || value > 300 {
+				return &IllegalDataValue
+			}
+			d.retarget()
+			d.travelSeconds = int(value)
+			log.Printf(">>> CHANGE: travelSeconds=%d\n", d.travelSeconds)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// Dantherm HCV/HCC units expose every value as a 32-bit register pair with the
+// low word first. Temperatures are IEEE 754 floats, everything else is an integer.
+type Dantherm struct {
+	mode               int
+	fanStep            int
+	bypass             bool
+	humidity           int
+	weekProgram        int
+	outdoorTemperature float64
+	supplyTemperature  float64
+	extractTemperature float64
+	exhaustTemperature float64
+	thermal            ThermalModel
+}
+
+func NewDantherm() *Dantherm {
+	return &Dantherm{
+		mode:               1,
+		fanStep:            2,
+		bypass:             false,
+		humidity:           45,
+		weekProgram:        0,
+		outdoorTemperature: 8.5,
+		supplyTemperature:  18.2,
+		extractTemperature: 21.4,
+		exhaustTemperature: 11.3,
+		thermal:            NewThermalModel(0.9),
+	}
+}
+
+// Advance moves the temperatures, the fan steps 1-4 run at a quarter of the power each.
+func (d *Dantherm) Advance(now time.Time) {
+	d.thermal.Advance(now, float64(d.fanStep*25), d.bypass, d.outdoorTemperature, d.extractTemperature, &d.supplyTemperature, &d.exhaustTemperature)
+}
+
+var danthermCodec = RegisterCodec{Order: OrderCDAB}
+
+// weekProgramActive reports whether the unit runs from its week program (operation mode 3).
+func (d *Dantherm) weekProgramActive() bool {
+	return d.mode == 3
+}
+
+// State implements HRULogic.
+func (d *Dantherm) State() DeviceState {
+	return DeviceState{
+		"mode":               &d.mode,
+		"fanStep":            &d.fanStep,
+		"bypass":             &d.bypass,
+		"humidity":           &d.humidity,
+		"weekProgram":        &d.weekProgram,
+		"outdoorTemperature": &d.outdoorTemperature,
+		"supplyTemperature":  &d.supplyTemperature,
+		"extractTemperature": &d.extractTemperature,
+		"exhaustTemperature": &d.exhaustTemperature,
+	}
+}
+
+func (d *Dantherm) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Dantherm", ProductCode: "HCV 400", Revision: "2.26"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 132 {
+			return danthermCodec.Float32(d.outdoorTemperature), &Success
+		}
+		if register == 134 {
+			return danthermCodec.Float32(d.supplyTemperature), &Success
+		}
+		if register == 136 {
+			return danthermCodec.Float32(d.extractTemperature), &Success
+		}
+		if register == 138 {
+			return danthermCodec.Float32(d.exhaustTemperature), &Success
+		}
+		if register == 196 {
+			return danthermCodec.Uint32(uint32(d.humidity)), &Success
+		}
+		if register == 198 {
+			if d.bypass {
+				return danthermCodec.Uint32(64), &Success
+			}
+			return danthermCodec.Uint32(0), &Success
+		}
+		if register == 324 {
+			return danthermCodec.Uint32(uint32(d.fanStep)), &Success
+		}
+		if register == 466 {
+			return danthermCodec.Uint32(uint32(d.weekProgram)), &Success
+		}
+		if register == 472 {
+			return danthermCodec.Uint32(uint32(d.mode)), &Success
+		}
+		if register == 474 {
+			if d.weekProgramActive() {
+				return danthermCodec.Uint32(1), &Success
+			}
+			return danthermCodec.Uint32(0), &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		if len(values) != 2 {
+			return &IllegalDataValue
+		}
+		value := int(danthermCodec.DecodeUint32(values))
+		if register == 324 {
+			if value > 4 {
+				return &IllegalDataValue
+			}
+			d.fanStep = value
+			log.Printf(">>> CHANGE: fanStep=%d\n", d.fanStep)
+			return &Success
+		}
+		if register == 198 {
+			d.bypass = value != 0
+			log.Printf(">>> CHANGE: bypass=%v\n", d.bypass)
+			return &Success
+		}
+		if register == 466 {
+			if value > 10 {
+				return &IllegalDataValue
+			}
+			d.weekProgram = value
+			log.Printf(">>> CHANGE: weekProgram=%d\n", d.weekProgram)
+			return &Success
+		}
+		if register == 472 {
+			if value > 6 {
+				return &IllegalDataValue
+			}
+			d.mode = value
+			log.Printf(">>> CHANGE: mode=%d, weekProgramActive=%v\n", d.mode, d.weekProgramActive())
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnReadInputRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
+		return []uint16{}, &IllegalFunction
+	})
+}
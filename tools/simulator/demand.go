@@ -0,0 +1,48 @@
+package main
+
+import "math"
+
+// CO2Source is implemented by devices measuring CO2, so units in demand control can follow them.
+type CO2Source interface {
+	// CO2 returns the concentration in ppm.
+	CO2() int
+}
+
+// linkedDevice is implemented by devices reading other devices of the simulator, link gives them the
+// lookup of a device by unit ID.
+type linkedDevice interface {
+	link(devices func(unitID uint8) (HRULogic, bool))
+}
+
+// DemandControl modulates the fan power of a unit in automatic mode with the CO2 concentration: the
+// minimum power up to Low ppm, full power from High ppm and linear in between.
+type DemandControl struct {
+	Low, High int
+	MinPower  float64
+}
+
+func NewDemandControl() DemandControl {
+	return DemandControl{Low: 600, High: 1200, MinPower: 20}
+}
+
+// power returns the fan power in percent for the CO2 concentration.
+func (d DemandControl) power(co2 int) float64 {
+	demand := math.Min(math.Max(float64(co2-d.Low)/float64(d.High-d.Low), 0), 1)
+	return math.Round(d.MinPower + demand*(100-d.MinPower))
+}
+
+// sensorCO2 returns the concentration of the CO2 sensor with the unit ID, false if there is none.
+func sensorCO2(devices func(unitID uint8) (HRULogic, bool), unitID int) (int, bool) {
+	if devices == nil || unitID < 0 || unitID > 247 {
+		return 0, false
+	}
+	logic, ok := devices(uint8(unitID))
+	if !ok {
+		return 0, false
+	}
+	sensor, ok := logic.(CO2Source)
+	if !ok {
+		return 0, false
+	}
+	return sensor.CO2(), true
+}
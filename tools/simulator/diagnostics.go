@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/binary"
+	"log"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// FC8 diagnostics sub-function codes.
+const (
+	diagnosticReturnQueryData                  = 0x00
+	diagnosticReturnDiagnosticRegister         = 0x02
+	diagnosticClearCounters                    = 0x0A
+	diagnosticReturnBusMessageCount            = 0x0B
+	diagnosticReturnBusCommunicationErrorCount = 0x0C
+	diagnosticReturnBusExceptionErrorCount     = 0x0D
+	diagnosticReturnServerMessageCount         = 0x0E
+	diagnosticReturnServerNoResponseCount      = 0x0F
+)
+
+// diagnosticCounters are the counters reported by the FC8 diagnostics sub-functions. Like real
+// devices they wrap around at 16 bits.
+type diagnosticCounters struct {
+	busMessages            uint16
+	busCommunicationErrors uint16
+	busExceptionErrors     uint16
+	serverMessages         uint16
+	serverNoResponses      uint16
+}
+
+// diagnostics holds the counters of each server, guarded by the simulation lock.
+var diagnostics = map[*Server]*diagnosticCounters{}
+
+func serverDiagnostics(s *Server) *diagnosticCounters {
+	counters, ok := diagnostics[s]
+	if !ok {
+		counters = &diagnosticCounters{}
+		diagnostics[s] = counters
+	}
+	return counters
+}
+
+// countCommunicationError counts a frame that was dropped because of a CRC or LRC error on every
+// device of the bus.
+func (b *Bus) countCommunicationError() {
+	simulation.Lock()
+	defer simulation.Unlock()
+	for _, s := range b.servers() {
+		serverDiagnostics(s).busCommunicationErrors++
+	}
+}
+
+// EnableDiagnostics registers FC8 on the server. Return Query Data echoes the request, the counter
+// sub-functions return the counters updated by every transport. FC7 answers with a clear exception
+// status until the device registers its own.
+func EnableDiagnostics(s *Server) {
+	OnReadExceptionStatus(s, func() uint8 {
+		return 0
+	})
+	registerFunctionHandler(s, FnDiagnostics, func(s *Server, frame Framer) ([]byte, *Exception) {
+		data := frame.GetData()
+		if len(data) < 4 {
+			return []byte{}, &IllegalDataValue
+		}
+		subFunction := binary.BigEndian.Uint16(data[0:2])
+		log.Printf("modbus_diagnostics: subfunction=%d\n", subFunction)
+
+		counters := serverDiagnostics(s)
+		var value uint16
+		switch subFunction {
+		case diagnosticReturnQueryData:
+			return data, &Success
+		case diagnosticReturnDiagnosticRegister:
+			value = 0
+		case diagnosticClearCounters:
+			*counters = diagnosticCounters{}
+			return data, &Success
+		case diagnosticReturnBusMessageCount:
+			value = counters.busMessages
+		case diagnosticReturnBusCommunicationErrorCount:
+			value = counters.busCommunicationErrors
+		case diagnosticReturnBusExceptionErrorCount:
+			value = counters.busExceptionErrors
+		case diagnosticReturnServerMessageCount:
+			value = counters.serverMessages
+		case diagnosticReturnServerNoResponseCount:
+			value = counters.serverNoResponses
+		default:
+			return []byte{}, &IllegalFunction
+		}
+		return append(data[0:2:2], Uint16ToBytes([]uint16{value})...), &Success
+	})
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// DucoBox registers are addressed per node: address = node * ducoNodeOffset + register offset.
+// Node 1 is the box itself, the following nodes are the zone valves.
+const (
+	ducoNodeOffset = 100
+
+	ducoNodeTypeBox   = 17
+	ducoNodeTypeValve = 29
+
+	// input register offsets
+	ducoInputNodeType      = 0
+	ducoInputState         = 1
+	ducoInputRemainingTime = 2
+	ducoInputFlowLevel     = 3
+	ducoInputSensorValue   = 4
+	ducoInputValvePosition = 5
+
+	// holding register offsets
+	ducoHoldingStateRequest = 0
+	ducoHoldingFlowSetpoint = 9
+)
+
+type ducoNode struct {
+	nodeType       int
+	state          int
+	remainingTime  int
+	flowLevel      int
+	sensorValue    int
+	valvePosition  int
+	flowSetpoint   int
+	hasSensorValue bool
+}
+
+type DucoBox struct {
+	nodes map[int]*ducoNode
+	// counted is the simulated time the timers of the nodes are counted down to.
+	counted time.Time
+}
+
+func NewDucoBox(zones int) *DucoBox {
+	d := &DucoBox{nodes: map[int]*ducoNode{}, counted: clock.Now()}
+	d.nodes[1] = &ducoNode{
+		nodeType:  ducoNodeTypeBox,
+		state:     0,
+		flowLevel: 30,
+	}
+	for i := 0; i < zones; i++ {
+		d.nodes[2+i] = &ducoNode{
+			nodeType:       ducoNodeTypeValve,
+			state:          0,
+			flowLevel:      30,
+			valvePosition:  50,
+			flowSetpoint:   50,
+			sensorValue:    650,
+			hasSensorValue: true,
+		}
+	}
+	return d
+}
+
+// Advance counts the timers of the nodes down, a node whose timer runs out goes back to auto.
+func (d *DucoBox) Advance(now time.Time) {
+	seconds := int(now.Sub(d.counted) / time.Second)
+	if seconds <= 0 {
+		return
+	}
+	d.counted = d.counted.Add(time.Duration(seconds) * time.Second)
+	for number, node := range d.nodes {
+		if node.remainingTime == 0 {
+			continue
+		}
+		node.remainingTime = max(node.remainingTime-seconds, 0)
+		if node.remainingTime == 0 {
+			node.state = 0
+			log.Printf(">>> CHANGE: node=%d, state=%d, timer ended\n", number, node.state)
+		}
+	}
+}
+
+// lookup splits a register address into its node and offset parts.
+func (d *DucoBox) lookup(register uint16) (*ducoNode, int, bool) {
+	node, ok := d.nodes[int(register)/ducoNodeOffset]
+	return node, int(register) % ducoNodeOffset, ok
+}
+
+// State implements HRULogic with the registers of each node, prefixed by its number.
+func (d *DucoBox) State() DeviceState {
+	state := DeviceState{}
+	for number, node := range d.nodes {
+		prefix := fmt.Sprintf("node%d.", number)
+		state[prefix+"state"] = &node.state
+		state[prefix+"remainingTime"] = &node.remainingTime
+		state[prefix+"flowLevel"] = &node.flowLevel
+		if node.hasSensorValue {
+			state[prefix+"sensorValue"] = &node.sensorValue
+		}
+		if node.nodeType == ducoNodeTypeValve {
+			state[prefix+"valvePosition"] = &node.valvePosition
+			state[prefix+"flowSetpoint"] = &node.flowSetpoint
+		}
+	}
+	return state
+}
+
+func (d *DucoBox) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Duco", ProductCode: "DucoBox Energy", Revision: "16056"})
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		node, offset, ok := d.lookup(register)
+		if !ok {
+			return []uint16{}, &IllegalDataAddress
+		}
+		if offset == ducoInputNodeType {
+			return []uint16{uint16(node.nodeType)}, &Success
+		}
+		if offset == ducoInputState {
+			return []uint16{uint16(node.state)}, &Success
+		}
+		if offset == ducoInputRemainingTime {
+			return []uint16{uint16(node.remainingTime)}, &Success
+		}
+		if offset == ducoInputFlowLevel {
+			return []uint16{uint16(node.flowLevel)}, &Success
+		}
+		if offset == ducoInputSensorValue && node.hasSensorValue {
+			return []uint16{uint16(node.sensorValue)}, &Success
+		}
+		if offset == ducoInputValvePosition && node.nodeType == ducoNodeTypeValve {
+			return []uint16{uint16(node.valvePosition)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		node, offset, ok := d.lookup(register)
+		if !ok {
+			return []uint16{}, &IllegalDataAddress
+		}
+		if offset == ducoHoldingStateRequest {
+			return []uint16{uint16(node.state)}, &Success
+		}
+		if offset == ducoHoldingFlowSetpoint && node.nodeType == ducoNodeTypeValve {
+			return []uint16{uint16(node.flowSetpoint)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		node, offset, ok := d.lookup(register)
+		if !ok {
+			return &IllegalDataAddress
+		}
+		if offset == ducoHoldingStateRequest {
+			// 0 = auto, 1-3 = manual levels, 4-6 = manual levels with 15 minute timer
+			if value > 6 {
+				return &IllegalDataValue
+			}
+			node.state = int(value)
+			node.remainingTime = 0
+			if value >= 4 {
+				node.remainingTime = 15 * 60
+			}
+			log.Printf(">>> CHANGE: node=%d, state=%d\n", int(register)/ducoNodeOffset, node.state)
+			return &Success
+		}
+		if offset == ducoHoldingFlowSetpoint && node.nodeType == ducoNodeTypeValve {
+			if value > 100 {
+				return &IllegalDataValue
+			}
+			node.flowSetpoint = int(value)
+			node.valvePosition = int(value)
+			log.Printf(">>> CHANGE: node=%d, flowSetpoint=%d\n", int(register)/ducoNodeOffset, node.flowSetpoint)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+}
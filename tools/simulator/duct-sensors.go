@@ -0,0 +1,118 @@
+package main
+
+import (
+	"log"
+	"math"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// DuctSensors simulates a four channel duct temperature module: outdoor (ODA), supply (SUP),
+// extract (ETA) and exhaust (EHA) air. Writing an efficiency recomputes SUP and EHA from ODA and
+// ETA for a balanced exchanger, so the integration's efficiency math can be checked against a known
+// value.
+type DuctSensors struct {
+	outdoor float64
+	supply  float64
+	extract float64
+	exhaust float64
+}
+
+func NewDuctSensors() *DuctSensors {
+	d := &DuctSensors{
+		outdoor: 0,
+		extract: 22,
+	}
+	d.applyEfficiency(85)
+	return d
+}
+
+func (d *DuctSensors) applyEfficiency(percent int) {
+	delta := (d.extract - d.outdoor) * float64(percent) / 100
+	d.supply = d.outdoor + delta
+	d.exhaust = d.extract - delta
+}
+
+// efficiency returns the supply side temperature efficiency in percent.
+func (d *DuctSensors) efficiency() int {
+	if d.extract == d.outdoor {
+		return 0
+	}
+	return int(math.Round((d.supply - d.outdoor) / (d.extract - d.outdoor) * 100))
+}
+
+func ductTemperature(value float64) uint16 {
+	return uint16(int16(math.Round(value * 10)))
+}
+
+// State implements HRULogic.
+func (d *DuctSensors) State() DeviceState {
+	return DeviceState{
+		"outdoor": &d.outdoor,
+		"supply":  &d.supply,
+		"extract": &d.extract,
+		"exhaust": &d.exhaust,
+	}
+}
+
+func (d *DuctSensors) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Luftuj", ProductCode: "Duct sensors", Revision: "1.0.0"})
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{ductTemperature(d.outdoor)}, &Success
+		}
+		if register == 1 {
+			return []uint16{ductTemperature(d.supply)}, &Success
+		}
+		if register == 2 {
+			return []uint16{ductTemperature(d.extract)}, &Success
+		}
+		if register == 3 {
+			return []uint16{ductTemperature(d.exhaust)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	// The holding registers set the simulated temperatures.
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 100 {
+			return []uint16{ductTemperature(d.outdoor)}, &Success
+		}
+		if register == 101 {
+			return []uint16{ductTemperature(d.supply)}, &Success
+		}
+		if register == 102 {
+			return []uint16{ductTemperature(d.extract)}, &Success
+		}
+		if register == 103 {
+			return []uint16{ductTemperature(d.exhaust)}, &Success
+		}
+		if register == 104 {
+			return []uint16{uint16(d.efficiency())}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		temperature := float64(int16(value)) / 10.0
+		if register == 100 {
+			d.outdoor = temperature
+		} else if register == 101 {
+			d.supply = temperature
+		} else if register == 102 {
+			d.extract = temperature
+		} else if register == 103 {
+			d.exhaust = temperature
+		} else if register == 104 {
+			if value > 100 {
+				return &IllegalDataValue
+			}
+			d.applyEfficiency(int(value))
+		} else {
+			return &IllegalDataAddress
+		}
+		log.Printf(">>> CHANGE: outdoor=%.1f, supply=%.1f, extract=%.1f, exhaust=%.1f\n", d.outdoor, d.supply, d.extract, d.exhaust)
+		return &Success
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+}
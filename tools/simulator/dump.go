@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+// Dump writes all fields of every device and the recent transactions in plain text, for
+// inspecting a misbehaving session that runs without the API. It is triggered by SIGUSR1.
+func (a *API) Dump(w io.Writer) {
+	state := clock.State()
+	fmt.Fprintf(w, "=== DUMP at %s (simulated %s, paused %v, time scale %v)\n",
+		time.Now().Format(time.RFC3339Nano), state.Time.Format(time.RFC3339Nano), state.Paused, state.TimeScale)
+	for _, unit := range a.units() {
+		device, ok := a.lookup(unit)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "unit %d %s\n", unit, device.deviceType)
+		simulation.Lock()
+		if timed, ok := device.logic.(Timed); ok {
+			timed.Advance(clock.Now())
+		}
+		dumpFields(w, device.logic)
+		simulation.Unlock()
+	}
+
+	simulation.Lock()
+	transactions := append([]Transaction{}, a.transactions...)
+	simulation.Unlock()
+	fmt.Fprintf(w, "transactions (%d)\n", len(transactions))
+	for _, transaction := range transactions {
+		result := "answered [" + transaction.Response + "]"
+		if transaction.Exception != "" {
+			result = "answered " + transaction.Exception
+		}
+		fmt.Fprintf(w, "  %s unit %d function %d [%s] %s\n", transaction.Time.Format(time.StampMilli),
+			transaction.Unit, transaction.Function, transaction.Request, result)
+	}
+	fmt.Fprintln(w, "=== END")
+}
+
+// dumpFields writes every field of the device struct, not only the state fields of the API. The
+// caller holds the simulation lock.
+func dumpFields(w io.Writer, logic HRULogic) {
+	device := reflect.ValueOf(logic)
+	if device.Kind() != reflect.Pointer || device.Elem().Kind() != reflect.Struct {
+		fmt.Fprintf(w, "  %+v\n", logic)
+		return
+	}
+	device = device.Elem()
+	for i := 0; i < device.NumField(); i++ {
+		field := device.Field(i)
+		value := reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+		fmt.Fprintf(w, "  %s %+v\n", device.Type().Field(i).Name, value.Interface())
+	}
+}
+
+// dumpTo writes the dump to stderr, or appends it to the file if path is set.
+func (a *API) dumpTo(path string) {
+	if path == "" {
+		a.Dump(os.Stderr)
+		return
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Dumping the state failed: %v\n", err)
+		return
+	}
+	defer file.Close()
+	a.Dump(file)
+	fmt.Fprintf(os.Stderr, "State dumped to %s\n", path)
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// DumpOnSignal does nothing, there is no SIGUSR1.
+func (a *API) DumpOnSignal(path string) {}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// DumpOnSignal writes the dump on every SIGUSR1, see dumpTo for the path.
+func (a *API) DumpOnSignal(path string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		for range signals {
+			a.dumpTo(path)
+		}
+	}()
+}
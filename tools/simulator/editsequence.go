@@ -0,0 +1,109 @@
+package main
+
+import (
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// EditWrite is the write of a value to a register.
+type EditWrite struct {
+	Address uint16 `json:"address" yaml:"address"`
+	Value   uint16 `json:"value" yaml:"value"`
+}
+
+// EditSequence is the edit protocol of units that only accept changes in a sequence. Writing the
+// Unlock value opens the sequence, writes to the Payload registers are then staged until the Confirm
+// value is written, which applies them together. Without Confirm a payload write is applied at once
+// and locks the sequence again. An open sequence locks again after Timeout if set.
+//
+// Payload writes to a locked sequence are answered with Illegal Data Address, unlock and confirm
+// writes of other values with Illegal Data Value.
+type EditSequence struct {
+	Unlock  EditWrite  `json:"unlock" yaml:"unlock"`
+	Payload []uint16   `json:"payload" yaml:"payload"`
+	Confirm *EditWrite `json:"confirm" yaml:"confirm"`
+	Timeout Duration   `json:"timeout" yaml:"timeout"`
+
+	// opened is when the sequence was unlocked, zero while it is locked.
+	opened time.Time
+	staged []func() *Exception
+}
+
+func (e *EditSequence) isOpen() bool {
+	if e.opened.IsZero() {
+		return false
+	}
+	if e.Timeout > 0 && clock.Since(e.opened) > time.Duration(e.Timeout) {
+		e.lock()
+		return false
+	}
+	return true
+}
+
+func (e *EditSequence) lock() {
+	e.opened = time.Time{}
+	e.staged = nil
+}
+
+// unlock handles a write to the unlock register, it drops the payload staged so far.
+func (e *EditSequence) unlock(value uint16) *Exception {
+	e.lock()
+	if value != e.Unlock.Value {
+		return &IllegalDataValue
+	}
+	e.opened = clock.Now()
+	return &Success
+}
+
+// write handles a write to a payload register, apply writes it.
+func (e *EditSequence) write(apply func() *Exception) *Exception {
+	if !e.isOpen() {
+		return &IllegalDataAddress
+	}
+	if e.Confirm == nil {
+		e.lock()
+		return apply()
+	}
+	e.staged = append(e.staged, apply)
+	return &Success
+}
+
+// confirm handles a write to the confirm register and applies the staged payload.
+func (e *EditSequence) confirm(value uint16) *Exception {
+	if !e.isOpen() || value != e.Confirm.Value {
+		e.lock()
+		return &IllegalDataValue
+	}
+	staged := e.staged
+	e.lock()
+	for _, apply := range staged {
+		if err := apply(); err != &Success {
+			return err
+		}
+	}
+	return &Success
+}
+
+// Apply adds the write-only unlock and confirm registers to the map and puts its payload registers
+// behind the sequence.
+func (e *EditSequence) Apply(registers RegisterMap) {
+	registers[e.Unlock.Address] = &Register{
+		Set:    func(value float64) *Exception { return e.unlock(uint16(value)) },
+		Access: WriteOnly,
+	}
+	if e.Confirm != nil {
+		registers[e.Confirm.Address] = &Register{
+			Set:    func(value float64) *Exception { return e.confirm(uint16(value)) },
+			Access: WriteOnly,
+		}
+	}
+	for _, address := range e.Payload {
+		payload := *registers[address]
+		set := payload.Set
+		payload.Set = func(value float64) *Exception {
+			return e.write(func() *Exception { return set(value) })
+		}
+		registers[address] = &payload
+	}
+}
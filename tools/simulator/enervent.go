@@ -0,0 +1,150 @@
+package main
+
+import (
+	"log"
+	"math"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const (
+	enerventCoilOverpressure = 3
+	enerventCoilBoost        = 10
+
+	enerventBoostFanPercent = 100
+)
+
+type EnerventEAir struct {
+	fanPercent         int
+	temperatureMode    int
+	setpoint           float64
+	boost              bool
+	overpressure       bool
+	supplyTemperature  float64
+	extractTemperature float64
+	outdoorTemperature float64
+}
+
+func NewEnerventEAir() *EnerventEAir {
+	return &EnerventEAir{
+		fanPercent:         45,
+		temperatureMode:    1,
+		setpoint:           20,
+		supplyTemperature:  18.7,
+		extractTemperature: 21.9,
+		outdoorTemperature: 1.2,
+	}
+}
+
+// fans returns the supply and extract fan speeds, boost runs both fans at full speed while
+// overpressure reduces the extract fan so the fireplace can draw.
+func (e *EnerventEAir) fans() (int, int) {
+	supply, extract := e.fanPercent, e.fanPercent
+	if e.boost {
+		supply, extract = enerventBoostFanPercent, enerventBoostFanPercent
+	}
+	if e.overpressure {
+		extract = extract * 6 / 10
+	}
+	return supply, extract
+}
+
+// State implements HRULogic.
+func (e *EnerventEAir) State() DeviceState {
+	return DeviceState{
+		"fanPercent":         &e.fanPercent,
+		"temperatureMode":    &e.temperatureMode,
+		"setpoint":           &e.setpoint,
+		"boost":              &e.boost,
+		"overpressure":       &e.overpressure,
+		"supplyTemperature":  &e.supplyTemperature,
+		"extractTemperature": &e.extractTemperature,
+		"outdoorTemperature": &e.outdoorTemperature,
+	}
+}
+
+func (e *EnerventEAir) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Enervent", ProductCode: "eAir", Revision: "2.3.7"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 49 {
+			return []uint16{uint16(e.fanPercent)}, &Success
+		}
+		if register == 74 {
+			return []uint16{uint16(e.temperatureMode)}, &Success
+		}
+		if register == 135 {
+			return []uint16{uint16(math.Round(e.setpoint * 10))}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 1 {
+			supply, _ := e.fans()
+			return []uint16{uint16(supply)}, &Success
+		}
+		if register == 2 {
+			_, extract := e.fans()
+			return []uint16{uint16(extract)}, &Success
+		}
+		if register == 6 {
+			return []uint16{uint16(int16(math.Round(e.outdoorTemperature * 10)))}, &Success
+		}
+		if register == 8 {
+			return []uint16{uint16(int16(math.Round(e.supplyTemperature * 10)))}, &Success
+		}
+		if register == 10 {
+			return []uint16{uint16(int16(math.Round(e.extractTemperature * 10)))}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 49 {
+			if value < 20 || value > 100 {
+				return &IllegalDataValue
+			}
+			e.fanPercent = int(value)
+			log.Printf(">>> CHANGE: fanPercent=%d\n", e.fanPercent)
+			return &Success
+		}
+		if register == 74 {
+			// 0 = supply air, 1 = room air, 2 = cascade
+			if value > 2 {
+				return &IllegalDataValue
+			}
+			e.temperatureMode = int(value)
+			log.Printf(">>> CHANGE: temperatureMode=%d\n", e.temperatureMode)
+			return &Success
+		}
+		if register == 135 {
+			e.setpoint = float64(value) / 10.0
+			log.Printf(">>> CHANGE: setpoint=%.1f\n", e.setpoint)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnReadCoils(serv, func(address uint16, numCoils int) ([]bool, *Exception) {
+		if address == enerventCoilOverpressure && numCoils == 1 {
+			return []bool{e.overpressure}, &Success
+		}
+		if address == enerventCoilBoost && numCoils == 1 {
+			return []bool{e.boost}, &Success
+		}
+		return []bool{}, &IllegalDataAddress
+	})
+	OnWriteCoil(serv, func(address uint16, value bool) *Exception {
+		if address == enerventCoilOverpressure {
+			e.overpressure = value
+			log.Printf(">>> CHANGE: overpressure=%v\n", e.overpressure)
+			return &Success
+		}
+		if address == enerventCoilBoost {
+			e.boost = value
+			log.Printf(">>> CHANGE: boost=%v\n", e.boost)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+}
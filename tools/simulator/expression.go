@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// compileExpression compiles an arithmetic expression over named values, e.g.
+// "powerRelative / 100 * maxPower". It supports numbers, names, + - * / %, comparisons and
+// && || ! (true is 1, false 0), the conditional c ? a : b, parentheses and the functions abs, ceil,
+// floor, max, min and round. resolve returns the getter of a name, or an error for unknown ones.
+func compileExpression(source string, resolve func(name string) (func() float64, error)) (func() float64, error) {
+	tokens, err := tokenizeExpression(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &expressionParser{tokens: tokens, resolve: resolve}
+	expression, err := p.parse(0)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", source, err)
+	}
+	if p.position < len(p.tokens) {
+		return nil, fmt.Errorf("%q: unexpected %q", source, p.tokens[p.position])
+	}
+	return expression, nil
+}
+
+// expressionNames returns the names an expression refers to, ignoring function names.
+func expressionNames(source string) []string {
+	tokens, _ := tokenizeExpression(source)
+	var names []string
+	for i, token := range tokens {
+		if isExpressionName(token) && (i+1 == len(tokens) || tokens[i+1] != "(") {
+			names = append(names, token)
+		}
+	}
+	return names
+}
+
+func isExpressionName(token string) bool {
+	return unicode.IsLetter(rune(token[0])) || token[0] == '_'
+}
+
+func tokenizeExpression(source string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			start := i
+			for i < len(source) && (unicode.IsDigit(rune(source[i])) || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, source[start:i])
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(source) && (unicode.IsLetter(rune(source[i])) || unicode.IsDigit(rune(source[i])) || source[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, source[start:i])
+		default:
+			operator := ""
+			for _, candidate := range []string{"<=", ">=", "==", "!=", "&&", "||", "+", "-", "*", "/", "%", "<", ">", "!", "?", ":", "(", ")", ","} {
+				if strings.HasPrefix(source[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, operator)
+			i += len(operator)
+		}
+	}
+	return tokens, nil
+}
+
+// expressionPrecedence holds the binding power of the binary operators.
+var expressionPrecedence = map[string]int{
+	"?": 1, "||": 2, "&&": 3,
+	"==": 4, "!=": 4, "<": 5, "<=": 5, ">": 5, ">=": 5,
+	"+": 6, "-": 6, "*": 7, "/": 7, "%": 7,
+}
+
+var expressionFunctions = map[string]func(arguments []float64) (float64, error){
+	"abs":   unaryFunction(math.Abs),
+	"ceil":  unaryFunction(math.Ceil),
+	"floor": unaryFunction(math.Floor),
+	"round": unaryFunction(math.Round),
+	"min": func(arguments []float64) (float64, error) {
+		if len(arguments) == 0 {
+			return 0, fmt.Errorf("min needs arguments")
+		}
+		result := arguments[0]
+		for _, argument := range arguments[1:] {
+			result = math.Min(result, argument)
+		}
+		return result, nil
+	},
+	"max": func(arguments []float64) (float64, error) {
+		if len(arguments) == 0 {
+			return 0, fmt.Errorf("max needs arguments")
+		}
+		result := arguments[0]
+		for _, argument := range arguments[1:] {
+			result = math.Max(result, argument)
+		}
+		return result, nil
+	},
+}
+
+func unaryFunction(function func(float64) float64) func([]float64) (float64, error) {
+	return func(arguments []float64) (float64, error) {
+		if len(arguments) != 1 {
+			return 0, fmt.Errorf("expected 1 argument, got %d", len(arguments))
+		}
+		return function(arguments[0]), nil
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// expressionParser is a precedence climbing parser compiling to closures.
+type expressionParser struct {
+	tokens   []string
+	position int
+	resolve  func(name string) (func() float64, error)
+}
+
+func (p *expressionParser) next() string {
+	if p.position == len(p.tokens) {
+		return ""
+	}
+	token := p.tokens[p.position]
+	p.position++
+	return token
+}
+
+func (p *expressionParser) peek() string {
+	if p.position == len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.position]
+}
+
+func (p *expressionParser) expect(token string) error {
+	if next := p.next(); next != token {
+		return fmt.Errorf("expected %q, got %q", token, next)
+	}
+	return nil
+}
+
+func (p *expressionParser) parse(minPrecedence int) (func() float64, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator := p.peek()
+		precedence, ok := expressionPrecedence[operator]
+		if !ok || precedence <= minPrecedence {
+			return left, nil
+		}
+		p.next()
+		if operator == "?" {
+			whenTrue, err := p.parse(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			// the conditional is right associative
+			whenFalse, err := p.parse(precedence - 1)
+			if err != nil {
+				return nil, err
+			}
+			condition := left
+			left = func() float64 {
+				if condition() != 0 {
+					return whenTrue()
+				}
+				return whenFalse()
+			}
+			continue
+		}
+		right, err := p.parse(precedence)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpression(operator, left, right)
+	}
+}
+
+func binaryExpression(operator string, a, b func() float64) func() float64 {
+	switch operator {
+	case "+":
+		return func() float64 { return a() + b() }
+	case "-":
+		return func() float64 { return a() - b() }
+	case "*":
+		return func() float64 { return a() * b() }
+	case "/":
+		return func() float64 { return a() / b() }
+	case "%":
+		return func() float64 { return math.Mod(a(), b()) }
+	case "<":
+		return func() float64 { return boolValue(a() < b()) }
+	case "<=":
+		return func() float64 { return boolValue(a() <= b()) }
+	case ">":
+		return func() float64 { return boolValue(a() > b()) }
+	case ">=":
+		return func() float64 { return boolValue(a() >= b()) }
+	case "==":
+		return func() float64 { return boolValue(a() == b()) }
+	case "!=":
+		return func() float64 { return boolValue(a() != b()) }
+	case "&&":
+		return func() float64 { return boolValue(a() != 0 && b() != 0) }
+	default: // "||"
+		return func() float64 { return boolValue(a() != 0 || b() != 0) }
+	}
+}
+
+func (p *expressionParser) operand() (func() float64, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end")
+	case token == "(":
+		inner, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	case token == "-":
+		operand, err := p.parse(expressionPrecedence["*"])
+		if err != nil {
+			return nil, err
+		}
+		return func() float64 { return -operand() }, nil
+	case token == "!":
+		operand, err := p.parse(expressionPrecedence["*"])
+		if err != nil {
+			return nil, err
+		}
+		return func() float64 { return boolValue(operand() == 0) }, nil
+	case isExpressionName(token) && p.peek() == "(":
+		return p.call(token)
+	case isExpressionName(token):
+		return p.resolve(token)
+	}
+	value, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected %q", token)
+	}
+	return func() float64 { return value }, nil
+}
+
+func (p *expressionParser) call(name string) (func() float64, error) {
+	function, ok := expressionFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+	p.next()
+	var arguments []func() float64
+	for p.peek() != ")" {
+		argument, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, argument)
+		if p.peek() != "," {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	// check the argument count once, so evaluating cannot fail
+	if _, err := function(make([]float64, len(arguments))); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return func() float64 {
+		values := make([]float64, len(arguments))
+		for i, argument := range arguments {
+			values[i] = argument()
+		}
+		result, _ := function(values)
+		return result
+	}, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// FanRamp makes the reported power of the fans follow the written setpoint at a limited rate, like EC
+// fans accelerating and slowing down over several seconds instead of jumping, so clients see the
+// transitional values. The power is in percent of the nominal one, the ramp runs on the simulated
+// clock.
+type FanRamp struct {
+	// Rate is the change of the power in percent per second.
+	Rate  float64
+	power float64
+	// updated is the simulated time the power has been ramped up to.
+	updated time.Time
+}
+
+// NewFanRamp starts the fans at the power, ramping at 10 % per second, a full start takes 10 seconds.
+func NewFanRamp(power float64) FanRamp {
+	return FanRamp{Rate: 10, power: power, updated: clock.Now()}
+}
+
+// Power returns the current power in percent.
+func (r *FanRamp) Power() float64 {
+	return r.power
+}
+
+// Advance ramps the power toward the setpoint up to now and reports whether it changed.
+func (r *FanRamp) Advance(now time.Time, setpoint float64) bool {
+	elapsed := now.Sub(r.updated)
+	if elapsed <= 0 {
+		return false
+	}
+	r.updated = now
+	if r.power == setpoint {
+		return false
+	}
+	step := r.Rate * elapsed.Seconds()
+	if setpoint > r.power {
+		r.power = math.Min(r.power+step, setpoint)
+	} else {
+		r.power = math.Max(r.power-step, setpoint)
+	}
+	return true
+}
+
+// fanRampState is a FanRamp in snapshots.
+type fanRampState struct {
+	Power float64 `json:"power"`
+}
+
+func (r FanRamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fanRampState{Power: r.power})
+}
+
+// UnmarshalJSON sets the power, the ramp continues from it at its rate.
+func (r *FanRamp) UnmarshalJSON(data []byte) error {
+	state := fanRampState{Power: r.power}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	if state.Power < 0 || state.Power > 100 {
+		return fmt.Errorf("fan power %v out of range 0-100", state.Power)
+	}
+	r.power = state.Power
+	return nil
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// Faults configures the faults injected into the responses, so the error handling of clients can
+// be tested. The zero value injects none.
+type Faults struct {
+	// TransactionIDFraction of the Modbus TCP responses carry a wrong transaction ID: the one of the
+	// previous request when TransactionIDStale is set, a random other one otherwise.
+	TransactionIDFraction float64
+	TransactionIDStale    bool
+	// SilenceFraction of the requests are lost like on a noisy RS485 bus: the device neither handles
+	// nor answers them, so clients run into their timeout.
+	SilenceFraction float64
+	// DropFraction of the requests on TCP connections are handled, but the connection is closed
+	// instead of answering, so clients have to reconnect without knowing whether a write applied.
+	DropFraction float64
+	// CorruptFraction of the responses are sent malformed, with a wrong byte count, truncated or
+	// with a wrong MBAP length as CorruptMode says, a random one that applies when it is empty.
+	CorruptFraction float64
+	CorruptMode     string
+	// CRCFraction of the RTU responses are sent with a wrong CRC, to test the CRC check and retry of
+	// clients.
+	CRCFraction float64
+	// SegmentSize bytes of the responses on TCP connections are written at a time, SegmentDelay
+	// apart, like cheap serial gateways forwarding the bytes as they arrive. Zero writes them at once.
+	SegmentSize  int
+	SegmentDelay time.Duration
+	// DuplicateFraction of the responses are sent twice like by a buggy gateway, so clients have to
+	// discard the unexpected frame instead of pairing it with their next request.
+	DuplicateFraction float64
+}
+
+// byteCountFunctions are the functions whose responses start with a byte count.
+var byteCountFunctions = map[uint8]bool{
+	FnReadCoils:                  true,
+	FnReadDiscreteInputs:         true,
+	FnReadHoldingRegisters:       true,
+	FnReadInputRegisters:         true,
+	FnReadFileRecord:             true,
+	FnReadWriteMultipleRegisters: true,
+}
+
+// encode returns the bytes of the response, malformed for the CorruptFraction of them and with a
+// wrong CRC for the CRCFraction of the other RTU ones.
+func (f *Faults) encode(response Framer) []byte {
+	if f.CorruptFraction > 0 && random.Float64() < f.CorruptFraction {
+		return f.corrupt(response)
+	}
+	packet := response.Bytes()
+	if _, isRTU := response.(*RTUFrame); isRTU && f.CRCFraction > 0 && random.Float64() < f.CRCFraction {
+		crc := binary.LittleEndian.Uint16(packet[len(packet)-2:])
+		binary.LittleEndian.PutUint16(packet[len(packet)-2:], crc^uint16(1+random.Intn(0xFFFF)))
+		log.Printf("!!! FAULT: response to function %d sent with a wrong CRC\n", response.GetFunction())
+	}
+	return packet
+}
+
+// corrupt returns the bytes of the response malformed as CorruptMode says. Modes that don't apply to
+// the response, like mbap-length to RTU frames, truncate it instead.
+func (f *Faults) corrupt(response Framer) []byte {
+	_, isTCP := response.(*TCPFrame)
+	hasByteCount := byteCountFunctions[response.GetFunction()] && len(response.GetData()) > 0
+	mode := f.CorruptMode
+	if mode == "" {
+		modes := []string{"truncate"}
+		if hasByteCount {
+			modes = append(modes, "byte-count")
+		}
+		if isTCP {
+			modes = append(modes, "mbap-length")
+		}
+		mode = modes[random.Intn(len(modes))]
+	}
+	if (mode == "byte-count" && !hasByteCount) || (mode == "mbap-length" && !isTCP) {
+		mode = "truncate"
+	}
+
+	var packet []byte
+	switch mode {
+	case "byte-count":
+		corrupted := response.Copy()
+		data := append([]byte{}, response.GetData()...)
+		data[0] += byte(1 + random.Intn(0xFF))
+		corrupted.SetData(data)
+		packet = corrupted.Bytes()
+		log.Printf("!!! FAULT: response to function %d sent with byte count %d\n", response.GetFunction(), data[0])
+	case "mbap-length":
+		packet = response.Bytes()
+		length := binary.BigEndian.Uint16(packet[4:6]) + uint16(1+random.Intn(0xFFFF))
+		binary.BigEndian.PutUint16(packet[4:6], length)
+		log.Printf("!!! FAULT: response to function %d sent with MBAP length %d\n", response.GetFunction(), length)
+	default:
+		packet = response.Bytes()
+		length := 1 + random.Intn(len(packet)-1)
+		log.Printf("!!! FAULT: response to function %d truncated to %d of %d bytes\n", response.GetFunction(), length, len(packet))
+		packet = packet[:length]
+	}
+	return packet
+}
+
+// write writes the response to the port, twice for the DuplicateFraction of them.
+func (f *Faults) write(port io.Writer, packet []byte) error {
+	if err := f.writeSegments(port, packet); err != nil {
+		return err
+	}
+	if f.duplicate() {
+		return f.writeSegments(port, packet)
+	}
+	return nil
+}
+
+// duplicate reports whether to send the response twice.
+func (f *Faults) duplicate() bool {
+	if f.DuplicateFraction <= 0 || random.Float64() >= f.DuplicateFraction {
+		return false
+	}
+	log.Printf("!!! FAULT: response sent twice\n")
+	return true
+}
+
+// writeSegments writes the packet, in segments on TCP connections if SegmentSize is set.
+func (f *Faults) writeSegments(port io.Writer, packet []byte) error {
+	if _, ok := port.(net.Conn); !ok || f.SegmentSize <= 0 {
+		_, err := port.Write(packet)
+		return err
+	}
+	for len(packet) > 0 {
+		segment := packet[:min(f.SegmentSize, len(packet))]
+		if _, err := port.Write(segment); err != nil {
+			return err
+		}
+		packet = packet[len(segment):]
+		if len(packet) > 0 {
+			time.Sleep(f.SegmentDelay)
+		}
+	}
+	return nil
+}
+
+// silence reports whether the request is lost without a response.
+func (f *Faults) silence(request Framer) bool {
+	if f.SilenceFraction <= 0 || random.Float64() >= f.SilenceFraction {
+		return false
+	}
+	log.Printf("!!! FAULT: function %d not answered\n", request.GetFunction())
+	return true
+}
+
+// dropConnection reports whether to close the connection instead of answering the request.
+func (f *Faults) dropConnection(port io.ReadWriter, request Framer) bool {
+	if _, ok := port.(net.Conn); !ok || f.DropFraction <= 0 || random.Float64() >= f.DropFraction {
+		return false
+	}
+	log.Printf("!!! FAULT: connection closed instead of answering function %d\n", request.GetFunction())
+	return true
+}
+
+// corruptTransactionID applies the transaction ID fault to the response, previous is the
+// transaction ID of the previous request on the connection.
+func (f *Faults) corruptTransactionID(response Framer, previous uint16) {
+	frame, ok := response.(*TCPFrame)
+	if !ok || f.TransactionIDFraction <= 0 || random.Float64() >= f.TransactionIDFraction {
+		return
+	}
+	transactionID := frame.TransactionIdentifier
+	if f.TransactionIDStale {
+		frame.TransactionIdentifier = previous
+	} else {
+		frame.TransactionIdentifier ^= uint16(1 + random.Intn(0xFFFF))
+	}
+	log.Printf("!!! FAULT: transaction ID %d answered as %d\n", transactionID, frame.TransactionIdentifier)
+}
+
+// ExceptionFault answers a Fraction of the requests with the Exception instead of handling them, to
+// test the retry and backoff of clients. Function limits it to one function code, Registers to the
+// requests touching the address range.
+type ExceptionFault struct {
+	Fraction  float64
+	Exception *Exception
+	Function  uint8
+	Registers *[2]uint16
+}
+
+// matches reports whether the fault applies to the request.
+func (e ExceptionFault) matches(request Framer) bool {
+	if e.Function != 0 && request.GetFunction() != e.Function {
+		return false
+	}
+	if e.Registers == nil {
+		return true
+	}
+	for _, span := range requestAddresses(request) {
+		if span[0] <= e.Registers[1] && span[1] >= e.Registers[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// requestAddresses returns the first and last address of every range the request reads or writes,
+// none for functions without addresses. The request has been validated.
+func requestAddresses(request Framer) [][2]uint16 {
+	data := request.GetData()
+	span := func(offset int, quantity uint16) [2]uint16 {
+		address := binary.BigEndian.Uint16(data[offset:])
+		return [2]uint16{address, address + quantity - 1}
+	}
+	switch request.GetFunction() {
+	case FnReadCoils, FnReadDiscreteInputs, FnReadHoldingRegisters, FnReadInputRegisters,
+		FnWriteMultipleCoils, FnWriteHoldingRegisters:
+		return [][2]uint16{span(0, binary.BigEndian.Uint16(data[2:]))}
+	case FnWriteSingleCoil, FnWriteHoldingRegister, FnMaskWriteRegister, FnReadFIFOQueue:
+		return [][2]uint16{span(0, 1)}
+	case FnReadWriteMultipleRegisters:
+		return [][2]uint16{span(0, binary.BigEndian.Uint16(data[2:])), span(4, binary.BigEndian.Uint16(data[6:]))}
+	}
+	return nil
+}
+
+// InjectExceptions answers requests with the exception of the first fault that fires.
+func InjectExceptions(faults []ExceptionFault) Middleware {
+	return func(next functionHandler) functionHandler {
+		return func(s *Server, request Framer) ([]byte, *Exception) {
+			for _, fault := range faults {
+				if fault.matches(request) && random.Float64() < fault.Fraction {
+					log.Printf("!!! FAULT: function %d answered %s\n", request.GetFunction(), fault.Exception.String())
+					return []byte{}, fault.Exception
+				}
+			}
+			return next(s, request)
+		}
+	}
+}
+
+// writeFunctions are the functions changing the device state.
+var writeFunctions = map[uint8]bool{
+	FnWriteSingleCoil:            true,
+	FnWriteHoldingRegister:       true,
+	FnWriteMultipleCoils:         true,
+	FnWriteHoldingRegisters:      true,
+	FnWriteFileRecord:            true,
+	FnMaskWriteRegister:          true,
+	FnReadWriteMultipleRegisters: true,
+}
+
+// BusyAfterWrite answers every request with Slave Device Busy for the duration after a successful
+// write, like Atrea firmwares committing to their EEPROM, to exercise the busy retry of clients.
+func BusyAfterWrite(duration time.Duration) Middleware {
+	var busyUntil time.Time
+	return func(next functionHandler) functionHandler {
+		return func(s *Server, request Framer) ([]byte, *Exception) {
+			if time.Now().Before(busyUntil) {
+				log.Printf("!!! FAULT: function %d answered SlaveDeviceBusy, busy for %v\n", request.GetFunction(), time.Until(busyUntil).Round(time.Millisecond))
+				return []byte{}, &SlaveDeviceBusy
+			}
+			data, exception := next(s, request)
+			if exception == &Success && writeFunctions[request.GetFunction()] {
+				busyUntil = time.Now().Add(duration)
+			}
+			return data, exception
+		}
+	}
+}
+
+// PartialWrites applies only the first registers of a fraction of the FC16 writes of more than one
+// register and answers them with Slave Device Failure, like units failing in the middle of a block
+// write, so the consistency checks of clients after failed writes can be tested.
+func PartialWrites(fraction float64) Middleware {
+	return func(next functionHandler) functionHandler {
+		return func(s *Server, request Framer) ([]byte, *Exception) {
+			if request.GetFunction() != FnWriteHoldingRegisters {
+				return next(s, request)
+			}
+			data := request.GetData()
+			quantity := int(binary.BigEndian.Uint16(data[2:4]))
+			if quantity < 2 || random.Float64() >= fraction {
+				return next(s, request)
+			}
+			applied := 1 + random.Intn(quantity-1)
+			partial := request.Copy()
+			partialData := append(binary.BigEndian.AppendUint16(append([]byte{}, data[0:2]...), uint16(applied)), byte(2*applied))
+			partial.SetData(append(partialData, data[5:5+2*applied]...))
+			if _, exception := next(s, partial); exception != &Success {
+				return []byte{}, exception
+			}
+			log.Printf("!!! FAULT: applied %d of %d registers written at %d, answered SlaveDeviceFailure\n",
+				applied, quantity, binary.BigEndian.Uint16(data[0:2]))
+			return []byte{}, &SlaveDeviceFailure
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"log"
+	"math"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const (
+	flexitModeStop      = 1
+	flexitModeAway      = 2
+	flexitModeHome      = 3
+	flexitModeHigh      = 4
+	flexitModeFireplace = 5
+)
+
+type FlexitNordic struct {
+	ventilationMode     int
+	homeSetpoint        float64
+	awaySetpoint        float64
+	heaterEnabled       bool
+	heaterActive        bool
+	supplyTemperature   float64
+	extractTemperature  float64
+	outdoorTemperature  float64
+	exhaustTemperature  float64
+	heaterOutputPercent int
+}
+
+func NewFlexitNordic() *FlexitNordic {
+	return &FlexitNordic{
+		ventilationMode:     flexitModeHome,
+		homeSetpoint:        20,
+		awaySetpoint:        18,
+		heaterEnabled:       true,
+		heaterActive:        true,
+		supplyTemperature:   19.5,
+		extractTemperature:  21.8,
+		outdoorTemperature:  4.2,
+		exhaustTemperature:  7.1,
+		heaterOutputPercent: 35,
+	}
+}
+
+// State implements HRULogic.
+func (f *FlexitNordic) State() DeviceState {
+	return DeviceState{
+		"ventilationMode":     &f.ventilationMode,
+		"homeSetpoint":        &f.homeSetpoint,
+		"awaySetpoint":        &f.awaySetpoint,
+		"heaterEnabled":       &f.heaterEnabled,
+		"heaterActive":        &f.heaterActive,
+		"supplyTemperature":   &f.supplyTemperature,
+		"extractTemperature":  &f.extractTemperature,
+		"outdoorTemperature":  &f.outdoorTemperature,
+		"exhaustTemperature":  &f.exhaustTemperature,
+		"heaterOutputPercent": &f.heaterOutputPercent,
+	}
+}
+
+func (f *FlexitNordic) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Flexit", ProductCode: "Nordic S3", Revision: "1.12.0"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 2000 {
+			return []uint16{uint16(f.ventilationMode)}, &Success
+		}
+		if register == 2001 {
+			return []uint16{uint16(math.Round(f.homeSetpoint * 10))}, &Success
+		}
+		if register == 2002 {
+			return []uint16{uint16(math.Round(f.awaySetpoint * 10))}, &Success
+		}
+		if register == 2003 {
+			if f.heaterEnabled {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 1 {
+			return []uint16{uint16(math.Round(f.supplyTemperature * 10))}, &Success
+		}
+		if register == 2 {
+			return []uint16{uint16(math.Round(f.extractTemperature * 10))}, &Success
+		}
+		if register == 3 {
+			return []uint16{uint16(int16(math.Round(f.outdoorTemperature * 10)))}, &Success
+		}
+		if register == 4 {
+			return []uint16{uint16(int16(math.Round(f.exhaustTemperature * 10)))}, &Success
+		}
+		if register == 10 {
+			if f.heaterEnabled && f.heaterActive {
+				return []uint16{uint16(f.heaterOutputPercent)}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 2000 {
+			if value < flexitModeStop || value > flexitModeFireplace {
+				return &IllegalDataValue
+			}
+			f.ventilationMode = int(value)
+			log.Printf(">>> CHANGE: ventilationMode=%d\n", f.ventilationMode)
+			return &Success
+		}
+		if register == 2001 {
+			f.homeSetpoint = float64(value) / 10.0
+			log.Printf(">>> CHANGE: homeSetpoint=%.1f\n", f.homeSetpoint)
+			return &Success
+		}
+		if register == 2002 {
+			f.awaySetpoint = float64(value) / 10.0
+			log.Printf(">>> CHANGE: awaySetpoint=%.1f\n", f.awaySetpoint)
+			return &Success
+		}
+		if register == 2003 {
+			f.heaterEnabled = value != 0
+			log.Printf(">>> CHANGE: heaterEnabled=%v\n", f.heaterEnabled)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnReadDiscreteInputs(serv, func(address uint16, numInputs int) ([]bool, *Exception) {
+		if address == 1 && numInputs == 1 {
+			return []bool{f.heaterEnabled && f.heaterActive}, &Success
+		}
+		return []bool{}, &IllegalDataAddress
+	})
+}
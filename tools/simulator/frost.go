@@ -0,0 +1,21 @@
+package main
+
+// FrostProtection decides when a unit protects its heat exchanger from freezing: it kicks in below the
+// Threshold outdoor temperature and ends once the outdoor air is Hysteresis warmer again. What the
+// unit does meanwhile, reduce the supply fan or run a preheater, is up to the device.
+type FrostProtection struct {
+	Threshold  float64
+	Hysteresis float64
+}
+
+func NewFrostProtection(threshold float64) FrostProtection {
+	return FrostProtection{Threshold: threshold, Hysteresis: 1}
+}
+
+// active returns whether frost protection runs at the outdoor temperature, given whether it ran before.
+func (f FrostProtection) active(wasActive bool, outdoor float64) bool {
+	if wasActive {
+		return outdoor < f.Threshold+f.Hysteresis
+	}
+	return outdoor < f.Threshold
+}
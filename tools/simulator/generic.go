@@ -0,0 +1,613 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	. "github.com/tbrandon/mbserver"
+	"gopkg.in/yaml.v3"
+)
+
+// GenericRegister describes one register or bit of a generic device. Access is "r", "w" or "rw"
+// and defaults to "rw" for holding registers and coils; input registers and discrete inputs are
+// always read-only.
+//
+// A register with a Name holds the named value instead of the raw Value. Registers sharing a name are
+// linked: a write to one of them changes all of them. The value is stored as Type (uint16 by
+// default, int16, or one of the two-register types uint32, int32 and float32 in the byte Order abcd,
+// cdab, badc or dcba) after multiplying it by Scale. A holding register with Unlock is only writable
+// right after the unlock value has been written to the unlock register, see EditSequence for longer
+// edit sequences.
+type GenericRegister struct {
+	Address uint16     `json:"address" yaml:"address"`
+	Value   uint16     `json:"value" yaml:"value"`
+	Access  string     `json:"access" yaml:"access"`
+	Name    string     `json:"name" yaml:"name"`
+	Type    string     `json:"type" yaml:"type"`
+	Scale   float64    `json:"scale" yaml:"scale"`
+	Order   string     `json:"order" yaml:"order"`
+	Unlock  *EditWrite `json:"unlock" yaml:"unlock"`
+}
+
+// GenericValue is a named value in engineering units, e.g. a temperature in °C. Writes outside of
+// Min and Max are rejected with Illegal Data Value, both zero leave the value unbounded.
+//
+// A value with an Expression is derived from other values, e.g. "powerRelative / 100 * maxPower",
+// and its registers are read-only. Updates keeps linked values consistent the other way round: after
+// a write to the value, every value it names is set to its expression, e.g. powerRelative to
+// "powerAbsolute / maxPower * 100".
+type GenericValue struct {
+	Name       string            `json:"name" yaml:"name"`
+	Value      float64           `json:"value" yaml:"value"`
+	Min        float64           `json:"min" yaml:"min"`
+	Max        float64           `json:"max" yaml:"max"`
+	Expression string            `json:"expression" yaml:"expression"`
+	Updates    map[string]string `json:"updates" yaml:"updates"`
+}
+
+// GenericFile is a file of records accessed with FC20/21, records are numbered from 0.
+type GenericFile struct {
+	File    uint16   `json:"file" yaml:"file"`
+	Records []uint16 `json:"records" yaml:"records"`
+}
+
+type GenericConfig struct {
+	Identification   DeviceIdentification `json:"identification" yaml:"identification"`
+	Values           []GenericValue       `json:"values" yaml:"values"`
+	EditSequences    []EditSequence       `json:"editSequences" yaml:"editSequences"`
+	HoldingRegisters []GenericRegister    `json:"holdingRegisters" yaml:"holdingRegisters"`
+	InputRegisters   []GenericRegister    `json:"inputRegisters" yaml:"inputRegisters"`
+	Coils            []GenericRegister    `json:"coils" yaml:"coils"`
+	DiscreteInputs   []GenericRegister    `json:"discreteInputs" yaml:"discreteInputs"`
+	Files            []GenericFile        `json:"files" yaml:"files"`
+}
+
+type genericRegister struct {
+	value    uint16
+	readable bool
+	writable bool
+	// binding is the named value the register holds a word of, nil for a plain register.
+	binding *genericBinding
+	word    int
+	// sequence is the edit sequence the register unlocks, confirms or is a payload of.
+	sequence *EditSequence
+}
+
+type genericValue struct {
+	name     string
+	value    float64
+	min, max float64
+	// expression computes a derived value, updates are applied after a write.
+	expression func() float64
+	updates    []genericUpdate
+}
+
+type genericUpdate struct {
+	value      *genericValue
+	expression func() float64
+}
+
+// get returns the stored or derived value.
+func (v *genericValue) get() float64 {
+	if v.expression != nil {
+		return v.expression()
+	}
+	return v.value
+}
+
+// genericBinding stores a named value in one or two registers.
+type genericBinding struct {
+	value *genericValue
+	kind  string
+	scale float64
+	codec RegisterCodec
+}
+
+func (b *genericBinding) words() int {
+	switch b.kind {
+	case "uint32", "int32", "float32":
+		return 2
+	}
+	return 1
+}
+
+func (b *genericBinding) encode() []uint16 {
+	value := b.value.get() * b.scale
+	switch b.kind {
+	case "int16":
+		return b.codec.Int16(int16(math.Round(value)))
+	case "uint32":
+		return b.codec.Uint32(uint32(math.Round(value)))
+	case "int32":
+		return b.codec.Int32(int32(math.Round(value)))
+	case "float32":
+		return b.codec.Float32(value)
+	}
+	return b.codec.Uint16(uint16(math.Round(value)))
+}
+
+func (b *genericBinding) decode(registers []uint16) float64 {
+	var value float64
+	switch b.kind {
+	case "int16":
+		value = float64(b.codec.DecodeInt16(registers))
+	case "uint32":
+		value = float64(b.codec.DecodeUint32(registers))
+	case "int32":
+		value = float64(b.codec.DecodeInt32(registers))
+	case "float32":
+		value = b.codec.DecodeFloat32(registers)
+	default:
+		value = float64(b.codec.DecodeUint16(registers))
+	}
+	return value / b.scale
+}
+
+// Generic is a device whose register map is loaded from a JSON or YAML file, so vendors that are not
+// implemented in Go yet can still be simulated.
+type Generic struct {
+	identification   DeviceIdentification
+	holdingRegisters map[uint16]*genericRegister
+	inputRegisters   map[uint16]*genericRegister
+	coils            map[uint16]*genericRegister
+	discreteInputs   map[uint16]*genericRegister
+	files            map[uint16][]uint16
+	// values are the named values by name
+	values map[string]*genericValue
+	// sequences are the edit sequences of the holding registers, including the ones of their Unlock.
+	sequences []*EditSequence
+}
+
+func NewGeneric(path string) (*Generic, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config GenericConfig
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &config)
+	default:
+		err = json.Unmarshal(data, &config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	g := &Generic{
+		identification: config.Identification,
+	}
+	if g.identification == (DeviceIdentification{}) {
+		g.identification = DeviceIdentification{VendorName: "Luftuj", ProductCode: "Generic", Revision: "1.0.0"}
+	}
+	values := map[string]*genericValue{}
+	for _, value := range config.Values {
+		if _, ok := values[value.Name]; ok || value.Name == "" {
+			return nil, fmt.Errorf("values: duplicate or missing name %q", value.Name)
+		}
+		values[value.Name] = &genericValue{name: value.Name, value: value.Value, min: value.Min, max: value.Max}
+	}
+	if err := compileGenericValues(config.Values, values); err != nil {
+		return nil, fmt.Errorf("values: %w", err)
+	}
+	g.values = values
+	if g.holdingRegisters, err = g.registers(config.HoldingRegisters, true, true, values); err != nil {
+		return nil, fmt.Errorf("holding registers: %w", err)
+	}
+	if g.inputRegisters, err = g.registers(config.InputRegisters, false, true, values); err != nil {
+		return nil, fmt.Errorf("input registers: %w", err)
+	}
+	if g.coils, err = g.registers(config.Coils, true, false, values); err != nil {
+		return nil, fmt.Errorf("coils: %w", err)
+	}
+	if g.discreteInputs, err = g.registers(config.DiscreteInputs, false, false, values); err != nil {
+		return nil, fmt.Errorf("discrete inputs: %w", err)
+	}
+	for i := range config.EditSequences {
+		g.sequences = append(g.sequences, &config.EditSequences[i])
+	}
+	for _, sequence := range g.sequences {
+		if err := g.addSequence(sequence); err != nil {
+			return nil, fmt.Errorf("edit sequences: %w", err)
+		}
+	}
+	g.files = map[uint16][]uint16{}
+	for _, file := range config.Files {
+		if _, ok := g.files[file.File]; ok {
+			return nil, fmt.Errorf("files: duplicate file %d", file.File)
+		}
+		g.files[file.File] = file.Records
+	}
+	return g, nil
+}
+
+// registers builds the registers or bits of one table, wide allows the two-register types.
+func (g *Generic) registers(definitions []GenericRegister, writable bool, wide bool, values map[string]*genericValue) (map[uint16]*genericRegister, error) {
+	registers := map[uint16]*genericRegister{}
+	for _, definition := range definitions {
+		register := genericRegister{value: definition.Value}
+		switch definition.Access {
+		case "", "rw":
+			register.readable = true
+			register.writable = writable
+		case "r":
+			register.readable = true
+		case "w":
+			if !writable {
+				return nil, fmt.Errorf("address %d cannot be write-only", definition.Address)
+			}
+			register.writable = true
+		default:
+			return nil, fmt.Errorf("address %d has unknown access %q", definition.Address, definition.Access)
+		}
+		words := 1
+		if definition.Name != "" {
+			binding, err := genericRegisterBinding(definition, values)
+			if err != nil {
+				return nil, err
+			}
+			if words = binding.words(); words > 1 && !wide {
+				return nil, fmt.Errorf("address %d cannot hold a %s", definition.Address, binding.kind)
+			}
+			if binding.value.expression != nil {
+				// derived values are computed, not written
+				register.writable = false
+			}
+			register.binding = binding
+		}
+		if definition.Unlock != nil {
+			if !writable || !wide {
+				return nil, fmt.Errorf("address %d cannot be unlocked", definition.Address)
+			}
+			sequence := g.unlockSequence(*definition.Unlock)
+			sequence.Payload = append(sequence.Payload, definition.Address)
+		}
+		for word := 0; word < words; word++ {
+			address := definition.Address + uint16(word)
+			if _, ok := registers[address]; ok {
+				return nil, fmt.Errorf("duplicate address %d", address)
+			}
+			wordRegister := register
+			wordRegister.word = word
+			registers[address] = &wordRegister
+		}
+	}
+	return registers, nil
+}
+
+// compileGenericValues compiles the expressions and updates of the values, derived values must not
+// depend on themselves.
+func compileGenericValues(definitions []GenericValue, values map[string]*genericValue) error {
+	resolve := func(name string) (func() float64, error) {
+		value, ok := values[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown value %q", name)
+		}
+		return value.get, nil
+	}
+	expressions := map[string]string{}
+	for _, definition := range definitions {
+		value := values[definition.Name]
+		if definition.Expression != "" {
+			expression, err := compileExpression(definition.Expression, resolve)
+			if err != nil {
+				return fmt.Errorf("%s: %w", definition.Name, err)
+			}
+			value.expression = expression
+			expressions[definition.Name] = definition.Expression
+		}
+		targets := make([]string, 0, len(definition.Updates))
+		for target := range definition.Updates {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+		for _, target := range targets {
+			updated, ok := values[target]
+			if !ok {
+				return fmt.Errorf("%s updates unknown value %q", definition.Name, target)
+			}
+			expression, err := compileExpression(definition.Updates[target], resolve)
+			if err != nil {
+				return fmt.Errorf("%s updates %s: %w", definition.Name, target, err)
+			}
+			value.updates = append(value.updates, genericUpdate{value: updated, expression: expression})
+		}
+	}
+	for _, definition := range definitions {
+		if definition.Expression != "" && definition.Updates != nil {
+			return fmt.Errorf("%s is derived and cannot update other values", definition.Name)
+		}
+		for target := range definition.Updates {
+			if _, ok := expressions[target]; ok {
+				return fmt.Errorf("%s updates the derived value %s", definition.Name, target)
+			}
+		}
+	}
+
+	// depth first search for cycles of derived values
+	state := map[string]int{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("%s depends on itself", name)
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, dependency := range expressionNames(expressions[name]) {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		return nil
+	}
+	for name := range expressions {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unlockSequence returns the sequence of the registers unlocked by the write, registers sharing an
+// unlock write are unlocked together.
+func (g *Generic) unlockSequence(unlock EditWrite) *EditSequence {
+	for _, sequence := range g.sequences {
+		if sequence.Unlock == unlock {
+			return sequence
+		}
+	}
+	sequence := &EditSequence{Unlock: unlock}
+	g.sequences = append(g.sequences, sequence)
+	return sequence
+}
+
+// addSequence puts the payload holding registers behind the sequence, the unlock and confirm
+// registers are added as write-only registers unless they are declared.
+func (g *Generic) addSequence(sequence *EditSequence) error {
+	addresses := []uint16{sequence.Unlock.Address}
+	if sequence.Confirm != nil {
+		addresses = append(addresses, sequence.Confirm.Address)
+	}
+	for _, address := range addresses {
+		if _, ok := g.holdingRegisters[address]; !ok {
+			g.holdingRegisters[address] = &genericRegister{writable: true}
+		}
+	}
+	for _, address := range append(addresses, sequence.Payload...) {
+		register, ok := g.holdingRegisters[address]
+		if !ok || !register.writable {
+			return fmt.Errorf("address %d is not a writable holding register", address)
+		}
+		if register.sequence != nil {
+			return fmt.Errorf("address %d is part of two edit sequences", address)
+		}
+		register.sequence = sequence
+	}
+	return nil
+}
+
+func genericRegisterBinding(definition GenericRegister, values map[string]*genericValue) (*genericBinding, error) {
+	value, ok := values[definition.Name]
+	if !ok {
+		return nil, fmt.Errorf("address %d has unknown value %q", definition.Address, definition.Name)
+	}
+	binding := &genericBinding{value: value, kind: definition.Type, scale: definition.Scale}
+	switch definition.Type {
+	case "", "uint16", "int16", "uint32", "int32", "float32":
+	default:
+		return nil, fmt.Errorf("address %d has unknown type %q", definition.Address, definition.Type)
+	}
+	if binding.scale == 0 {
+		binding.scale = 1
+	}
+	switch definition.Order {
+	case "", "abcd":
+		binding.codec.Order = OrderABCD
+	case "cdab":
+		binding.codec.Order = OrderCDAB
+	case "badc":
+		binding.codec.Order = OrderBADC
+	case "dcba":
+		binding.codec.Order = OrderDCBA
+	default:
+		return nil, fmt.Errorf("address %d has unknown order %q", definition.Address, definition.Order)
+	}
+	return binding, nil
+}
+
+// read returns count consecutive values starting at address, every one of them has to be defined and readable.
+func (g *Generic) read(registers map[uint16]*genericRegister, address uint16, count int) ([]uint16, *Exception) {
+	values := make([]uint16, count)
+	for i := range values {
+		register, ok := registers[address+uint16(i)]
+		if !ok || !register.readable {
+			return []uint16{}, &IllegalDataAddress
+		}
+		if register.binding != nil {
+			values[i] = register.binding.encode()[register.word]
+		} else {
+			values[i] = register.value
+		}
+	}
+	return values, &Success
+}
+
+func (g *Generic) readBits(registers map[uint16]*genericRegister, address uint16, count int) ([]bool, *Exception) {
+	values, err := g.read(registers, address, count)
+	if err != &Success {
+		return []bool{}, err
+	}
+	bits := make([]bool, count)
+	for i, value := range values {
+		bits[i] = value != 0
+	}
+	return bits, &Success
+}
+
+// write writes consecutive registers. All of them have to be writable, named values have to be
+// written completely, unlocked if needed and within their limits, otherwise nothing is written.
+func (g *Generic) write(registers map[uint16]*genericRegister, address uint16, values []uint16) *Exception {
+	for i := 0; i < len(values); {
+		register, ok := registers[address+uint16(i)]
+		if !ok || !register.writable {
+			return &IllegalDataAddress
+		}
+		if sequence := register.sequence; sequence != nil {
+			switch {
+			case address+uint16(i) == sequence.Unlock.Address:
+				if values[i] != sequence.Unlock.Value {
+					return &IllegalDataValue
+				}
+			case sequence.Confirm != nil && address+uint16(i) == sequence.Confirm.Address:
+				if values[i] != sequence.Confirm.Value || !sequence.isOpen() {
+					return &IllegalDataValue
+				}
+			case !sequence.isOpen():
+				return &IllegalDataAddress
+			}
+		}
+		binding := register.binding
+		if binding == nil {
+			i++
+			continue
+		}
+		words := binding.words()
+		if register.word != 0 || i+words > len(values) {
+			return &IllegalDataAddress
+		}
+		value := binding.decode(values[i : i+words])
+		if (binding.value.min != 0 || binding.value.max != 0) && (value < binding.value.min || value > binding.value.max) {
+			return &IllegalDataValue
+		}
+		i += words
+	}
+
+	for i := 0; i < len(values); {
+		register := registers[address+uint16(i)]
+		words := 1
+		if register.binding != nil {
+			words = register.binding.words()
+		}
+		apply := writeGenericRegister(register, address+uint16(i), values[i:i+words])
+		switch sequence := register.sequence; {
+		case sequence == nil:
+			apply()
+		case address+uint16(i) == sequence.Unlock.Address:
+			apply()
+			sequence.unlock(values[i])
+		case sequence.Confirm != nil && address+uint16(i) == sequence.Confirm.Address:
+			apply()
+			sequence.confirm(values[i])
+		default:
+			sequence.write(apply)
+		}
+		i += words
+	}
+	return &Success
+}
+
+// writeGenericRegister returns the write of values to a plain register or to the named value whose
+// first word the register holds.
+func writeGenericRegister(register *genericRegister, address uint16, values []uint16) func() *Exception {
+	return func() *Exception {
+		binding := register.binding
+		if binding == nil {
+			register.value = values[0]
+			log.Printf(">>> CHANGE: address=%d, value=%d\n", address, values[0])
+			return &Success
+		}
+		binding.value.value = binding.decode(values)
+		log.Printf(">>> CHANGE: %s=%g\n", binding.value.name, binding.value.value)
+		for _, update := range binding.value.updates {
+			update.value.value = update.expression()
+			log.Printf(">>> CHANGE: %s=%g\n", update.value.name, update.value.value)
+		}
+		return &Success
+	}
+}
+
+// State implements HRULogic with the named values that are not derived, the holding registers and
+// coils without a name by their address, e.g. holdingRegisters.100, and the file records.
+func (g *Generic) State() DeviceState {
+	state := DeviceState{"files": &g.files}
+	for name, value := range g.values {
+		if value.expression == nil {
+			state[name] = &value.value
+		}
+	}
+	for table, registers := range map[string]map[uint16]*genericRegister{"holdingRegisters": g.holdingRegisters, "coils": g.coils} {
+		for address, register := range registers {
+			if register.binding == nil {
+				state[fmt.Sprintf("%s.%d", table, address)] = &register.value
+			}
+		}
+	}
+	return state
+}
+
+func (g *Generic) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, g.identification)
+	OnReadHoldingRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
+		return g.read(g.holdingRegisters, register, numRegs)
+	})
+	OnReadInputRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
+		return g.read(g.inputRegisters, register, numRegs)
+	})
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		return g.write(g.holdingRegisters, register, []uint16{value})
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return g.write(g.holdingRegisters, register, values)
+	})
+	OnReadWriteMultipleRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
+		return g.read(g.holdingRegisters, register, numRegs)
+	}, func(register uint16, values []uint16) *Exception {
+		return g.write(g.holdingRegisters, register, values)
+	})
+	OnReadCoils(serv, func(address uint16, numCoils int) ([]bool, *Exception) {
+		return g.readBits(g.coils, address, numCoils)
+	})
+	OnReadDiscreteInputs(serv, func(address uint16, numInputs int) ([]bool, *Exception) {
+		return g.readBits(g.discreteInputs, address, numInputs)
+	})
+	OnWriteCoil(serv, func(address uint16, value bool) *Exception {
+		if value {
+			return g.write(g.coils, address, []uint16{1})
+		}
+		return g.write(g.coils, address, []uint16{0})
+	})
+	OnWriteMultipleCoils(serv, func(address uint16, values []bool) *Exception {
+		words := make([]uint16, len(values))
+		for i, value := range values {
+			if value {
+				words[i] = 1
+			}
+		}
+		return g.write(g.coils, address, words)
+	})
+	OnReadFileRecord(serv, func(file uint16, record uint16, length int) ([]uint16, *Exception) {
+		records, ok := g.files[file]
+		if !ok || int(record)+length > len(records) {
+			return []uint16{}, &IllegalDataAddress
+		}
+		return records[record : int(record)+length], &Success
+	})
+	OnWriteFileRecord(serv, func(file uint16, record uint16, values []uint16) *Exception {
+		records, ok := g.files[file]
+		if !ok || int(record)+len(values) > len(records) {
+			return &IllegalDataAddress
+		}
+		copy(records[record:], values)
+		log.Printf(">>> CHANGE: file=%d, record=%d, values=%v\n", file, record, values)
+		return &Success
+	})
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"reflect"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "luftuj-cz/hru-simulator/simulatorpb"
+)
+
+// grpcServer serves the API as the gRPC service defined in simulatorpb/simulator.proto, for test
+// harnesses preferring typed clients.
+type grpcServer struct {
+	pb.UnimplementedSimulatorServer
+	api *API
+}
+
+// ListenGRPC serves the gRPC API on the address in the background.
+func (a *API) ListenGRPC(address string) error {
+	listen, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer()
+	pb.RegisterSimulatorServer(server, &grpcServer{api: a})
+	go func() {
+		if err := server.Serve(listen); err != nil {
+			log.Printf("gRPC server stopped: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+func (g *grpcServer) device(unit uint32) (apiDevice, error) {
+	device, ok := g.api.lookup(uint8(unit))
+	if unit > 0xFF || !ok {
+		return apiDevice{}, status.Errorf(codes.NotFound, "no device with unit ID %d", unit)
+	}
+	return device, nil
+}
+
+func (g *grpcServer) ListDevices(ctx context.Context, request *pb.ListDevicesRequest) (*pb.ListDevicesResponse, error) {
+	response := &pb.ListDevicesResponse{}
+	for _, unit := range g.api.units() {
+		if device, ok := g.api.lookup(unit); ok {
+			response.Devices = append(response.Devices, grpcDevice(device))
+		}
+	}
+	return response, nil
+}
+
+func (g *grpcServer) GetState(ctx context.Context, request *pb.GetStateRequest) (*pb.DeviceState, error) {
+	device, err := g.device(request.Unit)
+	if err != nil {
+		return nil, err
+	}
+	simulation.Lock()
+	state := deviceState(device.logic)
+	simulation.Unlock()
+	return grpcState(device, state), nil
+}
+
+func (g *grpcServer) SetState(ctx context.Context, request *pb.SetStateRequest) (*pb.DeviceState, error) {
+	device, err := g.device(request.Unit)
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]json.RawMessage{}
+	for name, value := range request.Fields {
+		raw, err := grpcJSON(value)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid value for %s: %v", name, err)
+		}
+		changes[name] = raw
+	}
+	state, err := g.api.setState(device, changes)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return grpcState(device, state), nil
+}
+
+func (g *grpcServer) WatchState(request *pb.WatchStateRequest, stream grpc.ServerStreamingServer[pb.StateEvent]) error {
+	events := g.api.subscribe()
+	defer g.api.unsubscribe(events)
+	for {
+		select {
+		case event := <-events:
+			err := stream.Send(&pb.StateEvent{
+				Device: &pb.Device{Unit: uint32(event.Unit), Type: event.Type},
+				Field:  event.Field,
+				Value:  grpcValue(event.Value),
+				Time:   timestamppb.New(event.Time),
+			})
+			if err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (g *grpcServer) ListTransactions(ctx context.Context, request *pb.ListTransactionsRequest) (*pb.ListTransactionsResponse, error) {
+	simulation.Lock()
+	defer simulation.Unlock()
+	response := &pb.ListTransactionsResponse{}
+	for _, transaction := range g.api.transactions {
+		response.Transactions = append(response.Transactions, &pb.Transaction{
+			Unit:      uint32(transaction.Unit),
+			Function:  uint32(transaction.Function),
+			Request:   transaction.Request,
+			Response:  transaction.Response,
+			Exception: transaction.Exception,
+			Time:      timestamppb.New(transaction.Time),
+		})
+	}
+	return response, nil
+}
+
+func (g *grpcServer) GetSnapshot(ctx context.Context, request *pb.GetSnapshotRequest) (*pb.Snapshot, error) {
+	return grpcSnapshot(g.api.Snapshot())
+}
+
+func (g *grpcServer) RestoreSnapshot(ctx context.Context, request *pb.Snapshot) (*pb.Snapshot, error) {
+	snapshot := Snapshot{}
+	for _, device := range request.Devices {
+		unit := device.GetDevice().GetUnit()
+		if unit > 0xFF {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid unit ID %d", unit)
+		}
+		state := map[string]json.RawMessage{}
+		for name, value := range device.State {
+			raw, err := value.MarshalJSON()
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid value for %s: %v", name, err)
+			}
+			state[name] = raw
+		}
+		snapshot.Devices = append(snapshot.Devices, DeviceSnapshot{Unit: uint8(unit), Type: device.GetDevice().GetType(), State: state})
+	}
+	if err := g.api.Restore(snapshot); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return grpcSnapshot(g.api.Snapshot())
+}
+
+func (g *grpcServer) GetClock(ctx context.Context, request *pb.GetClockRequest) (*pb.ClockState, error) {
+	return grpcClock(clock.State()), nil
+}
+
+func (g *grpcServer) SetClock(ctx context.Context, request *pb.SetClockRequest) (*pb.ClockState, error) {
+	if request.TimeScale != nil {
+		if err := clock.SetScale(request.GetTimeScale()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	if request.Paused != nil {
+		clock.SetPaused(request.GetPaused())
+	}
+	return grpcClock(clock.State()), nil
+}
+
+func (g *grpcServer) RebootDevice(ctx context.Context, request *pb.RebootDeviceRequest) (*pb.RebootDeviceResponse, error) {
+	device, err := g.device(request.Unit)
+	if err != nil {
+		return nil, err
+	}
+	downtime := defaultRebootDowntime
+	if request.Downtime != nil {
+		downtime = request.Downtime.AsDuration()
+	}
+	if downtime < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid downtime %s", downtime)
+	}
+	if err := g.api.Reboot(device.unitID, downtime); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &pb.RebootDeviceResponse{Downtime: durationpb.New(downtime)}, nil
+}
+
+func (g *grpcServer) ListAlarms(ctx context.Context, request *pb.ListAlarmsRequest) (*pb.Alarms, error) {
+	device, err := g.device(request.Unit)
+	if err != nil {
+		return nil, err
+	}
+	alarms, err := alarmed(device)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	simulation.Lock()
+	active := activeAlarms(alarms)
+	simulation.Unlock()
+	return &pb.Alarms{Device: grpcDevice(device), Active: active}, nil
+}
+
+func (g *grpcServer) RaiseAlarm(ctx context.Context, request *pb.AlarmRequest) (*pb.Alarms, error) {
+	return g.setAlarm(request, true)
+}
+
+func (g *grpcServer) ClearAlarm(ctx context.Context, request *pb.AlarmRequest) (*pb.Alarms, error) {
+	return g.setAlarm(request, false)
+}
+
+// setAlarm raises or clears the code and returns the active codes.
+func (g *grpcServer) setAlarm(request *pb.AlarmRequest, raise bool) (*pb.Alarms, error) {
+	device, err := g.device(request.Unit)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := alarmed(device); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	active, err := g.api.SetAlarm(device.unitID, request.Code, raise)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &pb.Alarms{Device: grpcDevice(device), Active: active}, nil
+}
+
+func grpcDevice(device apiDevice) *pb.Device {
+	return &pb.Device{Unit: uint32(device.unitID), Type: device.deviceType}
+}
+
+func grpcState(device apiDevice, state map[string]any) *pb.DeviceState {
+	fields := map[string]*pb.Value{}
+	for name, value := range state {
+		fields[name] = grpcValue(value)
+	}
+	return &pb.DeviceState{Device: grpcDevice(device), Fields: fields}
+}
+
+// grpcSnapshot converts the snapshot, its JSON values map to google.protobuf.Value.
+func grpcSnapshot(snapshot Snapshot) (*pb.Snapshot, error) {
+	response := &pb.Snapshot{}
+	for _, device := range snapshot.Devices {
+		state := map[string]*structpb.Value{}
+		for name, raw := range device.State {
+			value := &structpb.Value{}
+			if err := value.UnmarshalJSON(raw); err != nil {
+				return nil, status.Errorf(codes.Internal, "unit %d: %s: %v", device.Unit, name, err)
+			}
+			state[name] = value
+		}
+		response.Devices = append(response.Devices, &pb.DeviceSnapshot{
+			Device: &pb.Device{Unit: uint32(device.Unit), Type: device.Type},
+			State:  state,
+		})
+	}
+	return response, nil
+}
+
+func grpcClock(state ClockState) *pb.ClockState {
+	return &pb.ClockState{Time: timestamppb.New(state.Time), Paused: state.Paused, TimeScale: state.TimeScale}
+}
+
+// grpcValue converts a state field value, integers become numbers.
+func grpcValue(value any) *pb.Value {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Bool:
+		return &pb.Value{Kind: &pb.Value_BoolValue{BoolValue: v.Bool()}}
+	case reflect.String:
+		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: v.String()}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &pb.Value{Kind: &pb.Value_NumberValue{NumberValue: float64(v.Int())}}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &pb.Value{Kind: &pb.Value_NumberValue{NumberValue: float64(v.Uint())}}
+	default:
+		return &pb.Value{Kind: &pb.Value_NumberValue{NumberValue: v.Float()}}
+	}
+}
+
+// grpcJSON converts a value to JSON for setDeviceState.
+func grpcJSON(value *pb.Value) (json.RawMessage, error) {
+	switch kind := value.GetKind().(type) {
+	case *pb.Value_BoolValue:
+		return json.RawMessage(strconv.FormatBool(kind.BoolValue)), nil
+	case *pb.Value_NumberValue:
+		return json.RawMessage(strconv.FormatFloat(kind.NumberValue, 'f', -1, 64)), nil
+	case *pb.Value_StringValue:
+		return json.Marshal(kind.StringValue)
+	}
+	return nil, fmt.Errorf("missing value")
+}
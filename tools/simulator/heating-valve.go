@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log"
+	"math"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// HeatingValve simulates the mixing valve controller of a water heating coil. The return water
+// temperature follows the valve: a closed valve lets the coil water cool down towards the air
+// temperature, a fully open one keeps it close to the flow temperature.
+type HeatingValve struct {
+	position        int
+	flowTemperature float64
+	airTemperature  float64
+}
+
+func NewHeatingValve() *HeatingValve {
+	return &HeatingValve{
+		position:        20,
+		flowTemperature: 45,
+		airTemperature:  5,
+	}
+}
+
+func (h *HeatingValve) returnTemperature() float64 {
+	return h.airTemperature + (h.flowTemperature-h.airTemperature)*(0.2+0.6*float64(h.position)/100)
+}
+
+// State implements HRULogic.
+func (h *HeatingValve) State() DeviceState {
+	return DeviceState{
+		"position":        &h.position,
+		"flowTemperature": &h.flowTemperature,
+		"airTemperature":  &h.airTemperature,
+	}
+}
+
+func (h *HeatingValve) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Luftuj", ProductCode: "Heating valve", Revision: "1.0.0"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(h.position)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(int16(math.Round(h.returnTemperature() * 10)))}, &Success
+		}
+		if register == 1 {
+			return []uint16{uint16(int16(math.Round(h.flowTemperature * 10)))}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 0 {
+			if value > 100 {
+				return &IllegalDataValue
+			}
+			h.position = int(value)
+			log.Printf(">>> CHANGE: position=%d, returnTemperature=%.1f\n", h.position, h.returnTemperature())
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+}
@@ -14,12 +14,60 @@ const (
 	FnReadInputRegisters    = 4
 	FnWriteSingleCoil       = 5
 	FnWriteHoldingRegister  = 6
+	FnReadExceptionStatus   = 7
+	FnDiagnostics           = 8
 	FnWriteMultipleCoils    = 15
 	FnWriteHoldingRegisters = 16
+	FnReadFileRecord        = 20
+	FnWriteFileRecord       = 21
+
+	FnMaskWriteRegister          = 22
+	FnReadWriteMultipleRegisters = 23
+	FnReadFIFOQueue              = 24
+	FnEncapsulatedInterface      = 43
+
+	meiReadDeviceIdentification = 14
+	fileRecordReferenceType     = 6
+	fifoMaxCount                = 31
+)
+
+// Bits of the FC7 exception status byte shared by the simulated devices: a fault stopping the unit
+// and a pending service request such as a filter change.
+const (
+	exceptionStatusFault   = 0x01
+	exceptionStatusService = 0x02
 )
 
+// ReadValues turns the values of a device into the read of a contiguous range OnReadHoldingRegisters
+// and OnReadInputRegisters expect: value returns the registers of the value at the register, two for
+// 32-bit values, or IllegalDataAddress for an unused register. A range may start or end within a
+// 32-bit value, a gap fails the read with IllegalDataAddress like on a real device.
+func ReadValues(value func(register uint16) ([]uint16, *Exception)) func(register uint16, numRegs int) ([]uint16, *Exception) {
+	return func(register uint16, numRegs int) ([]uint16, *Exception) {
+		values, err := value(register)
+		if err == &IllegalDataAddress && register > 0 {
+			// the second register of a 32-bit value
+			if previous, previousErr := value(register - 1); previousErr == &Success && len(previous) == 2 {
+				values, err = previous[1:], &Success
+			}
+		}
+		for err == &Success && len(values) < numRegs {
+			var next []uint16
+			next, err = value(register + uint16(len(values)))
+			if err == &Success && len(next) == 0 {
+				err = &IllegalDataAddress
+			}
+			values = append(values, next...)
+		}
+		if err != &Success {
+			return []uint16{}, err
+		}
+		return values[:numRegs], &Success
+	}
+}
+
 func OnReadHoldingRegisters(s *Server, function func(register uint16, numRegs int) ([]uint16, *Exception)) {
-	s.RegisterFunctionHandler(FnReadHoldingRegisters, func(s *Server, frame Framer) ([]byte, *Exception) {
+	registerFunctionHandler(s, FnReadHoldingRegisters, func(s *Server, frame Framer) ([]byte, *Exception) {
 		data := frame.GetData()
 		register := binary.BigEndian.Uint16(data[0:2])
 		numRegs := int(binary.BigEndian.Uint16(data[2:4]))
@@ -30,7 +78,7 @@ func OnReadHoldingRegisters(s *Server, function func(register uint16, numRegs in
 }
 
 func OnWriteHoldingRegisters(s *Server, function func(register uint16, data []uint16) *Exception) {
-	s.RegisterFunctionHandler(FnWriteHoldingRegisters, func(s *Server, frame Framer) ([]byte, *Exception) {
+	registerFunctionHandler(s, FnWriteHoldingRegisters, func(s *Server, frame Framer) ([]byte, *Exception) {
 		data := frame.GetData()
 		register := binary.BigEndian.Uint16(data[0:2])
 		valueBytes := frame.GetData()[5:]
@@ -41,7 +89,7 @@ func OnWriteHoldingRegisters(s *Server, function func(register uint16, data []ui
 }
 
 func OnWriteHoldingRegister(s *Server, function func(register uint16, value uint16) *Exception) {
-	s.RegisterFunctionHandler(FnWriteHoldingRegister, func(s *Server, frame Framer) ([]byte, *Exception) {
+	registerFunctionHandler(s, FnWriteHoldingRegister, func(s *Server, frame Framer) ([]byte, *Exception) {
 		data := frame.GetData()
 		register := binary.BigEndian.Uint16(data[0:2])
 		value := binary.BigEndian.Uint16(data[2:4])
@@ -51,7 +99,7 @@ func OnWriteHoldingRegister(s *Server, function func(register uint16, value uint
 }
 
 func OnReadInputRegisters(s *Server, function func(register uint16, numRegs int) ([]uint16, *Exception)) {
-	s.RegisterFunctionHandler(FnReadInputRegisters, func(s *Server, frame Framer) ([]byte, *Exception) {
+	registerFunctionHandler(s, FnReadInputRegisters, func(s *Server, frame Framer) ([]byte, *Exception) {
 		data := frame.GetData()
 		register := binary.BigEndian.Uint16(data[0:2])
 		numRegs := int(binary.BigEndian.Uint16(data[2:4]))
@@ -62,7 +110,7 @@ func OnReadInputRegisters(s *Server, function func(register uint16, numRegs int)
 }
 
 func OnWriteCoil(s *Server, function func(address uint16, value bool) *Exception) {
-	s.RegisterFunctionHandler(FnWriteSingleCoil, func(s *Server, frame Framer) ([]byte, *Exception) {
+	registerFunctionHandler(s, FnWriteSingleCoil, func(s *Server, frame Framer) ([]byte, *Exception) {
 		data := frame.GetData()
 		address := binary.BigEndian.Uint16(data[0:2])
 		value := binary.BigEndian.Uint16(data[2:4]) != 0
@@ -72,7 +120,7 @@ func OnWriteCoil(s *Server, function func(address uint16, value bool) *Exception
 }
 
 func OnReadCoils(s *Server, function func(address uint16, numCoils int) ([]bool, *Exception)) {
-	s.RegisterFunctionHandler(FnReadCoils, func(s *Server, frame Framer) ([]byte, *Exception) {
+	registerFunctionHandler(s, FnReadCoils, func(s *Server, frame Framer) ([]byte, *Exception) {
 		data := frame.GetData()
 		address := binary.BigEndian.Uint16(data[0:2])
 		numCoils := int(binary.BigEndian.Uint16(data[2:4]))
@@ -96,7 +144,7 @@ func OnReadCoils(s *Server, function func(address uint16, numCoils int) ([]bool,
 }
 
 func OnReadDiscreteInputs(s *Server, function func(address uint16, numInputs int) ([]bool, *Exception)) {
-	s.RegisterFunctionHandler(FnReadDiscreteInputs, func(s *Server, frame Framer) ([]byte, *Exception) {
+	registerFunctionHandler(s, FnReadDiscreteInputs, func(s *Server, frame Framer) ([]byte, *Exception) {
 		data := frame.GetData()
 		address := binary.BigEndian.Uint16(data[0:2])
 		numInputs := int(binary.BigEndian.Uint16(data[2:4]))
@@ -118,3 +166,191 @@ func OnReadDiscreteInputs(s *Server, function func(address uint16, numInputs int
 		return res, err
 	})
 }
+
+// OnWriteMultipleCoils registers FC15, the packed coil bits are unpacked to one value per coil.
+func OnWriteMultipleCoils(s *Server, function func(address uint16, values []bool) *Exception) {
+	registerFunctionHandler(s, FnWriteMultipleCoils, func(s *Server, frame Framer) ([]byte, *Exception) {
+		data := frame.GetData()
+		address := binary.BigEndian.Uint16(data[0:2])
+		numCoils := int(binary.BigEndian.Uint16(data[2:4]))
+		values := make([]bool, numCoils)
+		for i := range values {
+			values[i] = data[5+i/8]&(1<<(uint(i)%8)) != 0
+		}
+		log.Printf("modbus_write_multiple_coils: address=%d, values=%v\n", address, values)
+		return frame.GetData()[0:4], function(address, values)
+	})
+}
+
+// OnReadExceptionStatus registers FC7, function returns the 8 exception status outputs of the device.
+func OnReadExceptionStatus(s *Server, function func() uint8) {
+	registerFunctionHandler(s, FnReadExceptionStatus, func(s *Server, frame Framer) ([]byte, *Exception) {
+		status := function()
+		log.Printf("modbus_read_exception_status: status=0x%02X\n", status)
+		return []byte{status}, &Success
+	})
+}
+
+// OnReadFileRecord registers FC20, function is called for every sub-request of the frame with the
+// file number, the starting record and the number of records.
+func OnReadFileRecord(s *Server, function func(file uint16, record uint16, length int) ([]uint16, *Exception)) {
+	registerFunctionHandler(s, FnReadFileRecord, func(s *Server, frame Framer) ([]byte, *Exception) {
+		data := frame.GetData()
+		if len(data) < 1 || int(data[0]) != len(data)-1 || data[0]%7 != 0 {
+			return []byte{}, &IllegalDataValue
+		}
+		res := []byte{0}
+		for request := data[1:]; len(request) > 0; request = request[7:] {
+			if request[0] != fileRecordReferenceType {
+				return []byte{}, &IllegalDataAddress
+			}
+			file := binary.BigEndian.Uint16(request[1:3])
+			record := binary.BigEndian.Uint16(request[3:5])
+			length := int(binary.BigEndian.Uint16(request[5:7]))
+			log.Printf("modbus_read_file_record: file=%d, record=%d, length=%d\n", file, record, length)
+			values, err := function(file, record, length)
+			if err != &Success {
+				return []byte{}, err
+			}
+			res = append(res, byte(1+2*len(values)), fileRecordReferenceType)
+			res = append(res, Uint16ToBytes(values)...)
+		}
+		res[0] = byte(len(res) - 1)
+		return res, &Success
+	})
+}
+
+// OnWriteFileRecord registers FC21, function is called for every sub-request of the frame with the
+// file number, the starting record and the record values. The response echoes the request.
+func OnWriteFileRecord(s *Server, function func(file uint16, record uint16, values []uint16) *Exception) {
+	registerFunctionHandler(s, FnWriteFileRecord, func(s *Server, frame Framer) ([]byte, *Exception) {
+		data := frame.GetData()
+		if len(data) < 1 || int(data[0]) != len(data)-1 {
+			return []byte{}, &IllegalDataValue
+		}
+		for request := data[1:]; len(request) > 0; {
+			if len(request) < 7 {
+				return []byte{}, &IllegalDataValue
+			}
+			if request[0] != fileRecordReferenceType {
+				return []byte{}, &IllegalDataAddress
+			}
+			file := binary.BigEndian.Uint16(request[1:3])
+			record := binary.BigEndian.Uint16(request[3:5])
+			length := int(binary.BigEndian.Uint16(request[5:7]))
+			if len(request) < 7+2*length {
+				return []byte{}, &IllegalDataValue
+			}
+			values := BytesToUint16(request[7 : 7+2*length])
+			log.Printf("modbus_write_file_record: file=%d, record=%d, values=%v\n", file, record, values)
+			if err := function(file, record, values); err != &Success {
+				return []byte{}, err
+			}
+			request = request[7+2*length:]
+		}
+		return data, &Success
+	})
+}
+
+// OnReadFIFOQueue registers FC24, function returns the queued values of the FIFO at address. At most
+// 31 values may be queued.
+func OnReadFIFOQueue(s *Server, function func(address uint16) ([]uint16, *Exception)) {
+	registerFunctionHandler(s, FnReadFIFOQueue, func(s *Server, frame Framer) ([]byte, *Exception) {
+		data := frame.GetData()
+		address := binary.BigEndian.Uint16(data[0:2])
+		log.Printf("modbus_read_fifo_queue: address=%d\n", address)
+		values, err := function(address)
+		if err != &Success {
+			return []byte{}, err
+		}
+		if len(values) > fifoMaxCount {
+			return []byte{}, &IllegalDataValue
+		}
+		res := Uint16ToBytes([]uint16{uint16(2 + 2*len(values)), uint16(len(values))})
+		return append(res, Uint16ToBytes(values)...), &Success
+	})
+}
+
+// OnMaskWriteRegister registers FC22. The device sets the register to (current AND andMask) OR
+// (orMask AND NOT andMask), see maskRegister.
+func OnMaskWriteRegister(s *Server, function func(register uint16, andMask uint16, orMask uint16) *Exception) {
+	registerFunctionHandler(s, FnMaskWriteRegister, func(s *Server, frame Framer) ([]byte, *Exception) {
+		data := frame.GetData()
+		register := binary.BigEndian.Uint16(data[0:2])
+		andMask := binary.BigEndian.Uint16(data[2:4])
+		orMask := binary.BigEndian.Uint16(data[4:6])
+		log.Printf("modbus_mask_write_register: register=%d, and=0x%04X, or=0x%04X\n", register, andMask, orMask)
+		return frame.GetData()[0:6], function(register, andMask, orMask)
+	})
+}
+
+// maskRegister applies a FC22 mask write to the current register value.
+func maskRegister(current uint16, andMask uint16, orMask uint16) uint16 {
+	return (current & andMask) | (orMask &^ andMask)
+}
+
+// OnReadWriteMultipleRegisters registers FC23, which writes the holding registers first and then reads
+// them back in a single transaction.
+func OnReadWriteMultipleRegisters(s *Server, read func(register uint16, numRegs int) ([]uint16, *Exception), write func(register uint16, values []uint16) *Exception) {
+	registerFunctionHandler(s, FnReadWriteMultipleRegisters, func(s *Server, frame Framer) ([]byte, *Exception) {
+		data := frame.GetData()
+		readRegister := binary.BigEndian.Uint16(data[0:2])
+		numRegs := int(binary.BigEndian.Uint16(data[2:4]))
+		writeRegister := binary.BigEndian.Uint16(data[4:6])
+		values := BytesToUint16(data[9:])
+		log.Printf("modbus_read_write_multiple_registers: read=%d, number=%v, write=%d, values=%v\n", readRegister, numRegs, writeRegister, values)
+		if err := write(writeRegister, values); err != &Success {
+			return []byte{}, err
+		}
+		readValues, err := read(readRegister, numRegs)
+		return append([]byte{byte(numRegs * 2)}, Uint16ToBytes(readValues)...), err
+	})
+}
+
+// DeviceIdentification holds the basic objects (0x00-0x02) returned by FC43/14.
+type DeviceIdentification struct {
+	VendorName  string `json:"vendorName" yaml:"vendorName"`
+	ProductCode string `json:"productCode" yaml:"productCode"`
+	Revision    string `json:"revision" yaml:"revision"`
+}
+
+// OnReadDeviceIdentification registers FC43/14 with the basic identification objects. Stream access
+// (read codes 1-3) returns all of them, as they always fit into one response, individual access
+// (read code 4) returns the requested one.
+func OnReadDeviceIdentification(s *Server, identification DeviceIdentification) {
+	objects := [][]byte{
+		[]byte(identification.VendorName),
+		[]byte(identification.ProductCode),
+		[]byte(identification.Revision),
+	}
+	registerFunctionHandler(s, FnEncapsulatedInterface, func(s *Server, frame Framer) ([]byte, *Exception) {
+		data := frame.GetData()
+		if len(data) < 3 || data[0] != meiReadDeviceIdentification {
+			return []byte{}, &IllegalFunction
+		}
+		readCode, objectID := data[1], int(data[2])
+		log.Printf("modbus_read_device_identification: code=%d, object=%d\n", readCode, objectID)
+		first, last := objectID, len(objects)-1
+		switch readCode {
+		case 1, 2, 3:
+			if objectID > last {
+				first = 0
+			}
+		case 4:
+			if objectID > last {
+				return []byte{}, &IllegalDataAddress
+			}
+			last = objectID
+		default:
+			return []byte{}, &IllegalDataValue
+		}
+
+		// conformity level 0x81: basic identification, stream and individual access
+		res := []byte{meiReadDeviceIdentification, readCode, 0x81, 0, 0, byte(last - first + 1)}
+		for id := first; id <= last; id++ {
+			res = append(res, byte(id), byte(len(objects[id])))
+			res = append(res, objects[id]...)
+		}
+		return res, &Success
+	})
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"crypto/tls"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/tbrandon/mbserver"
@@ -10,45 +16,959 @@ import (
 
 type HRULogic interface {
 	Configure(serv *mbserver.Server)
+	// State returns the variables making up the full state of the device: the scalar ones are the
+	// state fields of the API, snapshots capture and restore all of them.
+	State() DeviceState
 }
 
-func main() {
-	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, "Error: missing argument. Usage: hru_simulator <port> <xvent|meltem|atrea-rd5|atrea-am|korado>")
-		os.Exit(1)
+var (
+	port                 = flag.String("port", "", "serve Modbus TCP on the `port`, it can be given as first argument as well")
+	bind                 = flag.String("bind", "", "listen on the `address` of one interface instead of all, e.g. 127.0.0.1")
+	rtuDevice            = flag.String("rtu", "", "serve Modbus RTU on the serial `device` instead of Modbus TCP")
+	pty                  = flag.Bool("pty", false, "serve Modbus RTU on a new pseudo terminal instead of Modbus TCP")
+	ptyLink              = flag.String("pty-link", "", "create a symlink at `path` pointing to the pseudo terminal")
+	rtuBaudRate          = flag.Int("baud", 19200, "serial baud rate")
+	rtuDataBits          = flag.Int("data-bits", 8, "serial data bits")
+	rtuParity            = flag.String("parity", "E", "serial parity: N, E or O")
+	rtuStopBits          = flag.Int("stop-bits", 1, "serial stop bits")
+	udp                  = flag.Bool("udp", false, "also serve Modbus UDP on the port")
+	singleConnection     = flag.String("single-connection", "", "accept one TCP client at a time like embedded Modbus TCP stacks, `reject|drop` others: reset them or accept and close them")
+	deviceUnitID         = flag.Uint("unit-id", 0, "unit ID of the device given as argument, 0 answers every unit ID without a device of its own")
+	devices              deviceFlags
+	unsupportedFunctions unsupportedFunctionFlags
+	latencies            latencyFlags
+	exceptionFaults      exceptionFaultFlags
+	busyAfterWrite       durationFlags
+	revertWrites         revertFlags
+	broadcast            = flag.String("broadcast", "auto", "apply writes to unit ID 0 to every device without a response: on, off or auto (serial framings only)")
+	gatewayFault         = flag.String("gateway-fault", "", "answer Modbus TCP requests like a gateway with a broken downstream bus as `path|target[:unit,...]`: Gateway Path Unavailable or Gateway Target Device Failed to Respond, for all or the listed unit IDs")
+	transactionIDFault   = flag.String("transaction-id-fault", "", "answer a fraction of the Modbus TCP requests with a wrong transaction ID as `[stale:|mismatch:]fraction`: the previous request's or a random one (default)")
+	corruptResponses     = flag.String("corrupt-responses", "", "send a fraction of the responses malformed as `[byte-count|truncate|mbap-length:]fraction`: with a wrong byte count, truncated or with a wrong MBAP length, a random one by default")
+	slowResponses        = flag.String("slow-responses", "", "write the responses on TCP connections in segments of `bytes:delay`, e.g. 1:20ms, like cheap serial gateways")
+	duplicateResponses   = flag.Float64("duplicate-responses", 0, "send a `fraction` of the responses twice like a buggy gateway, e.g. 0.05")
+	chaos                = flag.String("chaos", "", "inject latency, dropped connections, exceptions and malformed responses at the rates of a `low|medium|high` preset, options given explicitly win")
+	crcErrors            = flag.Float64("crc-errors", 0, "send a `fraction` of the RTU responses with a wrong CRC, e.g. 0.05")
+	noResponse           = flag.Float64("no-response", 0, "lose a `fraction` of the requests without handling or answering them, so clients time out, e.g. 0.05")
+	partialWrites        = flag.Float64("partial-writes", 0, "apply only the first registers of a `fraction` of the FC16 writes and answer Slave Device Failure, e.g. 0.1")
+	dropConnections      = flag.Float64("drop-connections", 0, "close the TCP connection instead of answering a `fraction` of the requests, e.g. 0.05")
+	tlsCert              = flag.String("tls-cert", "", "serve Modbus/TCP Security with the certificate `file` (PEM)")
+	tlsKey               = flag.String("tls-key", "", "private key `file` (PEM) of the TLS certificate")
+	tlsCA                = flag.String("tls-ca", "", "require client certificates signed by the CA `file` (PEM)")
+	apiAddress           = flag.String("api", "", "serve the HTTP API to read and change the device state on `address`, e.g. :8080")
+	configFile           = flag.String("config", "", "load the devices and listeners from a YAML or JSON `file` instead of the arguments")
+	scenarioFile         = flag.String("scenario", "", "run the timed state changes of the YAML or JSON scenario `file`")
+	watch                = flag.Bool("watch", false, "apply changes of the config, scenario and device files while running")
+	restoreFile          = flag.String("restore", "", "restore the device state from the snapshot `file` at startup")
+	snapshotFile         = flag.String("snapshot", "", "write a snapshot of the device state to `file` on exit")
+	stateFile            = flag.String("state-file", "", "keep the device state in `file` across restarts: restore it at startup and write every change")
+	grpcAddress          = flag.String("grpc", "", "serve the gRPC API to read and change the device state on `address`, e.g. :50051")
+	repl                 = flag.Bool("repl", false, "read commands changing the device state from stdin, see help")
+	tui                  = flag.Bool("tui", false, "show the device state in the terminal and change it with the keyboard")
+	seed                 = flag.Int64("seed", 0, "seed the sensor noise, fault probabilities and math.random of Lua devices with `n` to reproduce a run, the random seed of every run is logged")
+	timeScale            = flag.Float64("time-scale", 1, "run the simulated time `n` times faster, e.g. 3600 for an hour per second: timers, filter counters, watchdogs and scenarios")
+	dumpFile             = flag.String("dump-file", "", "append the dump of the device state and recent transactions written on SIGUSR1 to `file` instead of stderr")
+	logRequests          = flag.Bool("log-requests", false, "log every request with its response")
+	framing              = flag.String("framing", "", "framing: tcp, rtu-over-tcp or ascii-over-tcp on the TCP port (default tcp), rtu or ascii on serial ports (default rtu)")
+)
+
+func init() {
+	flag.Var(&devices, "device", "add a device to the bus as `unit=type[:file]`, can be repeated")
+	flag.Var(&unsupportedFunctions, "unsupported-function", "answer to unimplemented functions as `[unit=]policy`: illegal-function (default), illegal-data-address or silence, for all devices or the unit, can be repeated")
+	flag.Var(&exceptionFaults, "exception-fault", "answer a fraction of the requests with an exception as `[unit=][busy|failure:]fraction[,function=code][,registers=first-last]`: Slave Device Busy (default) or Slave Device Failure, for all requests or the function and address range, for all devices or the unit, can be repeated")
+	flag.Var(&busyAfterWrite, "busy-after-write", "answer Slave Device Busy for `[unit=]duration` after every write, e.g. 2s, for all devices or the unit, can be repeated")
+	flag.Var(&revertWrites, "revert-writes", "restore holding registers to their previous value some time after a write as `[unit=]first[-last]:after`, e.g. 12001:30s, like firmwares reverting setpoints, for all devices or the unit, can be repeated")
+	flag.Var(&latencies, "latency", "delay the responses by `[unit=]base[~jitter[:distribution]]`, e.g. 150ms or 100ms~40ms:pareto, for all devices or the unit, the jitter is uniform (default), normal or pareto, can be repeated")
+}
+
+// deviceFlags holds the devices added with --device.
+type deviceFlags []DeviceConfig
+
+func (d *deviceFlags) String() string {
+	return ""
+}
+
+func (d *deviceFlags) Set(value string) error {
+	unit, device, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected unit=type[:file]")
+	}
+	id, err := strconv.ParseUint(unit, 10, 8)
+	if err != nil || id < 1 || id > 247 {
+		return fmt.Errorf("invalid unit ID '%s', expected 1-247", unit)
+	}
+	deviceType, file, _ := strings.Cut(device, ":")
+	*d = append(*d, DeviceConfig{Unit: uint8(id), Type: deviceType, File: file})
+	return nil
+}
+
+// parseGatewayFault parses the --gateway-fault value.
+func parseGatewayFault(value string) (*mbserver.Exception, []uint8, error) {
+	mode, units, _ := strings.Cut(value, ":")
+	var exception *mbserver.Exception
+	switch mode {
+	case "path":
+		exception = &mbserver.GatewayPathUnavailable
+	case "target":
+		exception = &mbserver.GatewayTargetDeviceFailedtoRespond
+	default:
+		return nil, nil, fmt.Errorf("unknown gateway fault '%s'. Valid options: path, target", mode)
+	}
+	var unitIDs []uint8
+	if units != "" {
+		for _, unit := range strings.Split(units, ",") {
+			id, err := strconv.ParseUint(unit, 10, 8)
+			if err != nil || id > 247 {
+				return nil, nil, fmt.Errorf("invalid unit ID '%s', expected 0-247", unit)
+			}
+			unitIDs = append(unitIDs, uint8(id))
+		}
+	}
+	return exception, unitIDs, nil
+}
+
+// unsupportedFunctionFlags holds the policies set with --unsupported-function.
+type unsupportedFunctionFlags struct {
+	policy UnsupportedFunctionPolicy
+	units  map[uint8]UnsupportedFunctionPolicy
+}
+
+func (u *unsupportedFunctionFlags) String() string {
+	return ""
+}
+
+func (u *unsupportedFunctionFlags) Set(value string) error {
+	unit, name, ok := strings.Cut(value, "=")
+	if !ok {
+		unit, name = "", value
+	}
+	var policy UnsupportedFunctionPolicy
+	switch name {
+	case "illegal-function":
+		policy = UnsupportedIllegalFunction
+	case "illegal-data-address":
+		policy = UnsupportedIllegalDataAddress
+	case "silence":
+		policy = UnsupportedSilence
+	default:
+		return fmt.Errorf("unknown policy '%s'. Valid options: illegal-function, illegal-data-address, silence", name)
+	}
+	if unit == "" {
+		u.policy = policy
+		return nil
 	}
+	id, err := strconv.ParseUint(unit, 10, 8)
+	if err != nil || id > 247 {
+		return fmt.Errorf("invalid unit ID '%s', expected 0-247", unit)
+	}
+	if u.units == nil {
+		u.units = map[uint8]UnsupportedFunctionPolicy{}
+	}
+	u.units[uint8(id)] = policy
+	return nil
+}
+
+// forUnit returns the policy of the device with the unit ID.
+func (u *unsupportedFunctionFlags) forUnit(unitID uint8) UnsupportedFunctionPolicy {
+	if policy, ok := u.units[unitID]; ok {
+		return policy
+	}
+	return u.policy
+}
+
+// latencyFlags holds the response latencies set with --latency.
+type latencyFlags struct {
+	latency Latency
+	units   map[uint8]Latency
+}
+
+func (l *latencyFlags) String() string {
+	return ""
+}
+
+func (l *latencyFlags) Set(value string) error {
+	unit, spec, ok := strings.Cut(value, "=")
+	if !ok {
+		unit, spec = "", value
+	}
+	latency, err := ParseLatency(spec)
+	if err != nil {
+		return err
+	}
+	if unit == "" {
+		l.latency = latency
+		return nil
+	}
+	id, err := strconv.ParseUint(unit, 10, 8)
+	if err != nil || id > 247 {
+		return fmt.Errorf("invalid unit ID '%s', expected 0-247", unit)
+	}
+	if l.units == nil {
+		l.units = map[uint8]Latency{}
+	}
+	l.units[uint8(id)] = latency
+	return nil
+}
+
+// forUnit returns the latency of the device with the unit ID.
+func (l *latencyFlags) forUnit(unitID uint8) Latency {
+	if latency, ok := l.units[unitID]; ok {
+		return latency
+	}
+	return l.latency
+}
+
+// durationFlags holds durations set as [unit=]duration, for all devices or single unit IDs.
+type durationFlags struct {
+	duration time.Duration
+	units    map[uint8]time.Duration
+}
+
+func (d *durationFlags) String() string {
+	return ""
+}
 
-	var logic HRULogic
-	switch os.Args[2] {
+func (d *durationFlags) Set(value string) error {
+	unit, spec, ok := strings.Cut(value, "=")
+	if !ok {
+		unit, spec = "", value
+	}
+	duration, err := time.ParseDuration(spec)
+	if err != nil || duration < 0 {
+		return fmt.Errorf("invalid duration '%s', expected e.g. 2s or 500ms", spec)
+	}
+	if unit == "" {
+		d.duration = duration
+		return nil
+	}
+	id, err := strconv.ParseUint(unit, 10, 8)
+	if err != nil || id > 247 {
+		return fmt.Errorf("invalid unit ID '%s', expected 0-247", unit)
+	}
+	if d.units == nil {
+		d.units = map[uint8]time.Duration{}
+	}
+	d.units[uint8(id)] = duration
+	return nil
+}
+
+// forUnit returns the duration of the device with the unit ID.
+func (d *durationFlags) forUnit(unitID uint8) time.Duration {
+	if duration, ok := d.units[unitID]; ok {
+		return duration
+	}
+	return d.duration
+}
+
+// exceptionFaultFlags holds the faults set with --exception-fault.
+type exceptionFaultFlags struct {
+	faults []ExceptionFault
+	units  map[uint8][]ExceptionFault
+}
+
+func (e *exceptionFaultFlags) String() string {
+	return ""
+}
+
+func (e *exceptionFaultFlags) Set(value string) error {
+	unit, spec, ok := strings.Cut(value, "=")
+	if !ok || strings.Contains(unit, ",") {
+		unit, spec = "", value
+	}
+	filters := strings.Split(spec, ",")
+	mode, fraction, ok := strings.Cut(filters[0], ":")
+	if !ok {
+		mode, fraction = "busy", filters[0]
+	}
+	var fault ExceptionFault
+	switch mode {
+	case "busy":
+		fault.Exception = &mbserver.SlaveDeviceBusy
+	case "failure":
+		fault.Exception = &mbserver.SlaveDeviceFailure
+	default:
+		return fmt.Errorf("unknown exception '%s'. Valid options: busy, failure", mode)
+	}
+	var err error
+	fault.Fraction, err = strconv.ParseFloat(fraction, 64)
+	if err != nil || fault.Fraction < 0 || fault.Fraction > 1 {
+		return fmt.Errorf("invalid fraction '%s', expected a number between 0 and 1", fraction)
+	}
+	for _, filter := range filters[1:] {
+		name, value, _ := strings.Cut(filter, "=")
+		switch name {
+		case "function":
+			code, err := strconv.ParseUint(value, 10, 8)
+			if err != nil || code == 0 {
+				return fmt.Errorf("invalid function code '%s', expected 1-255", value)
+			}
+			fault.Function = uint8(code)
+		case "registers":
+			registers, err := parseRegisterRange(value)
+			if err != nil {
+				return err
+			}
+			fault.Registers = &registers
+		default:
+			return fmt.Errorf("unknown filter '%s'. Valid options: function, registers", name)
+		}
+	}
+	if unit == "" {
+		e.faults = append(e.faults, fault)
+		return nil
+	}
+	id, err := strconv.ParseUint(unit, 10, 8)
+	if err != nil || id > 247 {
+		return fmt.Errorf("invalid unit ID '%s', expected 0-247", unit)
+	}
+	if e.units == nil {
+		e.units = map[uint8][]ExceptionFault{}
+	}
+	e.units[uint8(id)] = append(e.units[uint8(id)], fault)
+	return nil
+}
+
+// forUnit returns the faults of the device with the unit ID, its own ones first.
+func (e *exceptionFaultFlags) forUnit(unitID uint8) []ExceptionFault {
+	return append(append([]ExceptionFault{}, e.units[unitID]...), e.faults...)
+}
+
+// revertFlags holds the register reverts set with --revert-writes.
+type revertFlags struct {
+	reverts []RegisterRevert
+	units   map[uint8][]RegisterRevert
+}
+
+func (r *revertFlags) String() string {
+	return ""
+}
+
+func (r *revertFlags) Set(value string) error {
+	unit, spec, ok := strings.Cut(value, "=")
+	if !ok {
+		unit, spec = "", value
+	}
+	revert, err := ParseRegisterRevert(spec)
+	if err != nil {
+		return err
+	}
+	if unit == "" {
+		r.reverts = append(r.reverts, revert)
+		return nil
+	}
+	id, err := strconv.ParseUint(unit, 10, 8)
+	if err != nil || id > 247 {
+		return fmt.Errorf("invalid unit ID '%s', expected 0-247", unit)
+	}
+	if r.units == nil {
+		r.units = map[uint8][]RegisterRevert{}
+	}
+	r.units[uint8(id)] = append(r.units[uint8(id)], revert)
+	return nil
+}
+
+// forUnit returns the reverts of the device with the unit ID, its own ones first.
+func (r *revertFlags) forUnit(unitID uint8) []RegisterRevert {
+	return append(append([]RegisterRevert{}, r.units[unitID]...), r.reverts...)
+}
+
+// parseCorruptResponses parses the --corrupt-responses value into faults.
+func parseCorruptResponses(value string, faults *Faults) error {
+	mode, fraction, ok := strings.Cut(value, ":")
+	if !ok {
+		mode, fraction = "", value
+	}
+	switch mode {
+	case "", "byte-count", "truncate", "mbap-length":
+	default:
+		return fmt.Errorf("unknown response corruption '%s'. Valid options: byte-count, truncate, mbap-length", mode)
+	}
+	parsed, err := strconv.ParseFloat(fraction, 64)
+	if err != nil || parsed < 0 || parsed > 1 {
+		return fmt.Errorf("invalid fraction '%s', expected a number between 0 and 1", fraction)
+	}
+	faults.CorruptFraction, faults.CorruptMode = parsed, mode
+	return nil
+}
+
+// parseSlowResponses parses the --slow-responses value, bytes:delay, into the size and the delay of
+// the segments TCP responses are written in.
+func parseSlowResponses(value string, faults *Faults) error {
+	size, delay, ok := strings.Cut(value, ":")
+	if !ok {
+		return fmt.Errorf("invalid slow responses '%s', expected bytes:delay, e.g. 1:20ms", value)
+	}
+	parsed, err := strconv.Atoi(size)
+	if err != nil || parsed < 1 {
+		return fmt.Errorf("invalid segment size '%s', expected a positive number of bytes", size)
+	}
+	faults.SegmentSize = parsed
+	if faults.SegmentDelay, err = time.ParseDuration(delay); err != nil || faults.SegmentDelay < 0 {
+		return fmt.Errorf("invalid segment delay '%s', expected a duration like 20ms", delay)
+	}
+	return nil
+}
+
+// parseTransactionIDFault parses the --transaction-id-fault value into faults.
+func parseTransactionIDFault(value string, faults *Faults) error {
+	mode, fraction, ok := strings.Cut(value, ":")
+	if !ok {
+		mode, fraction = "mismatch", value
+	}
+	parsed, err := strconv.ParseFloat(fraction, 64)
+	if err != nil || parsed < 0 || parsed > 1 {
+		return fmt.Errorf("invalid fraction '%s', expected a number between 0 and 1", fraction)
+	}
+	switch mode {
+	case "mismatch":
+		faults.TransactionIDStale = false
+	case "stale":
+		faults.TransactionIDStale = true
+	default:
+		return fmt.Errorf("unknown transaction ID fault '%s'. Valid options: stale, mismatch", mode)
+	}
+	faults.TransactionIDFraction = parsed
+	return nil
+}
+
+// envPrefix prefixes the environment variables setting the options not given on the command line,
+// e.g. HRU_SIMULATOR_PORT for --port.
+const envPrefix = "HRU_SIMULATOR_"
+
+// flagsFromEnvironment sets the options not given on the command line from the environment.
+// Repeatable options take a space separated list.
+func flagsFromEnvironment() error {
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		value, ok := os.LookupEnv(name)
+		if !ok || given[f.Name] || err != nil {
+			return
+		}
+		values := []string{value}
+		switch f.Value.(type) {
+		case *deviceFlags, *unsupportedFunctionFlags, *latencyFlags, *exceptionFaultFlags, *durationFlags, *revertFlags:
+			values = strings.Fields(value)
+		}
+		for _, value := range values {
+			if setErr := flag.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value %q of %s: %w", value, name, setErr)
+				return
+			}
+		}
+	})
+	return err
+}
+
+// chaosPresets are the fault options of the --chaos levels, for robustness smoke tests without
+// tuning every option. The rates grow from a healthy installation to a noisy bus behind a bad gateway.
+var chaosPresets = map[string]map[string]string{
+	"low": {
+		"latency":           "10ms~20ms",
+		"drop-connections":  "0.002",
+		"exception-fault":   "0.01",
+		"corrupt-responses": "0.005",
+	},
+	"medium": {
+		"latency":           "50ms~50ms:normal",
+		"drop-connections":  "0.01",
+		"exception-fault":   "0.03",
+		"corrupt-responses": "0.01",
+	},
+	"high": {
+		"latency":           "100ms~100ms:pareto",
+		"drop-connections":  "0.03",
+		"exception-fault":   "0.08",
+		"corrupt-responses": "0.03",
+	},
+}
+
+// applyChaos sets the options of the --chaos preset that are not given on the command line or in
+// the environment.
+func applyChaos() error {
+	if *chaos == "" {
+		return nil
+	}
+	preset, ok := chaosPresets[*chaos]
+	if !ok {
+		return fmt.Errorf("unknown chaos level '%s'. Valid options: low, medium, high", *chaos)
+	}
+	given := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for name, value := range preset {
+		if given[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: hru_simulator [options] [--port] <port> [<xvent|meltem|atrea-rd5|atrea-am|korado|zehnder|dantherm|flexit-nordic|blauberg-vento|ducobox|vents-twinfresh|paul-novus-300|paul-novus-450|swegon-casa|lossnay|daikin-vam|atrea-ec5|thessla-airpack|enervent-eair|renson-endura|aldes|itho-hru-eco|comfoair350|aereco-dxr|wanas|generic|lua|lunos-pair|co2sensor|rht-sensor|voc-sensor|duct-sensors|pressure-sensor|damper|preheater|heating-valve|brine-pump|weather-station> [file]]")
+	fmt.Fprintln(os.Stderr, "       hru_simulator --rtu <device>|--pty [options] [<device_type> [file]]")
+	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "Options not given on the command line are read from the environment as %s<OPTION>, e.g. %sPORT=502 or %sDEVICE=\"1=korado 2=co2sensor\".\n", envPrefix, envPrefix, envPrefix)
+}
+
+// newDevice creates a simulated device, file is the optional file argument of the type and
+// parameters tune it, see DeviceConfig.
+func newDevice(deviceType string, file string, parameters map[string]float64) (HRULogic, error) {
+	p := &deviceParameters{values: parameters, used: map[string]bool{}}
+	logic, err := createDevice(deviceType, file, p)
+	if err != nil {
+		return nil, err
+	}
+	if unknown := p.unknown(); len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown %s parameters: %s", deviceType, strings.Join(unknown, ", "))
+	}
+	return logic, nil
+}
+
+func createDevice(deviceType string, file string, p *deviceParameters) (HRULogic, error) {
+	switch deviceType {
 	case "xvent":
-		logic = NewXvent()
+		return NewXvent(), nil
 	case "meltem":
-		logic = NewMeltem()
+		return NewMeltem(), nil
 	case "atrea-rd5":
-		logic = NewAtreaRD5()
+		return NewAtreaRD5(), nil
 	case "atrea-am":
-		logic = NewAtreaAM(380)
+		return NewAtreaAM(int(p.get("maxPower", 380))), nil
 	case "korado":
-		logic = NewKorado()
+		return NewKorado(), nil
 	case "zehnder":
-		logic = NewZehnder()
+		return NewZehnder(), nil
+	case "dantherm":
+		return NewDantherm(), nil
+	case "flexit-nordic":
+		return NewFlexitNordic(), nil
+	case "blauberg-vento":
+		return NewBlaubergVento(), nil
+	case "ducobox":
+		return NewDucoBox(int(p.get("zones", 3))), nil
+	case "vents-twinfresh":
+		return NewVentsTwinFresh(), nil
+	case "paul-novus-300":
+		return NewPaulNovus(300), nil
+	case "paul-novus-450":
+		return NewPaulNovus(450), nil
+	case "swegon-casa":
+		return NewSwegonCasa(), nil
+	case "lossnay":
+		return NewLossnay(), nil
+	case "daikin-vam":
+		return NewDaikinVAM(), nil
+	case "atrea-ec5":
+		return NewAtreaEC5(int(p.get("co2Sensor", -1))), nil
+	case "thessla-airpack":
+		return NewThesslaAirPack(), nil
+	case "enervent-eair":
+		return NewEnerventEAir(), nil
+	case "renson-endura":
+		return NewRensonEndura(), nil
+	case "aldes":
+		return NewAldes(), nil
+	case "itho-hru-eco":
+		return NewIthoHRUEco(), nil
+	case "comfoair350":
+		return NewComfoAir350(), nil
+	case "aereco-dxr":
+		return NewAerecoDXR(int(p.get("zones", 4))), nil
+	case "wanas":
+		return NewWanas(), nil
+	case "generic":
+		if file == "" {
+			return nil, fmt.Errorf("missing register file. Usage: generic <registers.json|registers.yaml>")
+		}
+		return NewGeneric(file)
+	case "lua":
+		if file == "" {
+			return nil, fmt.Errorf("missing script file. Usage: lua <device.lua>")
+		}
+		return NewLua(file)
+	case "lunos-pair":
+		return NewLunosPair(), nil
+	case "co2sensor":
+		return NewCO2Sensor(), nil
+	case "rht-sensor":
+		return NewRHTSensor(p.get("humidityNoise", 1.5), p.get("temperatureNoise", 0.2)), nil
+	case "voc-sensor":
+		return NewVOCSensor(), nil
+	case "duct-sensors":
+		return NewDuctSensors(), nil
+	case "pressure-sensor":
+		return NewPressureSensor(), nil
+	case "damper":
+		return NewDamper(), nil
+	case "preheater":
+		return NewPreheater(), nil
+	case "heating-valve":
+		return NewHeatingValve(), nil
+	case "brine-pump":
+		return NewBrinePump(), nil
+	case "weather-station":
+		if file == "" {
+			return NewWeatherStation(), nil
+		}
+		return NewWeatherStationFromCSV(file)
 	default:
-		fmt.Fprintf(os.Stderr, "Error: unknown HRU type '%s'. Valid options: xvent, meltem, atrea-rd5, atrea-am, korado, zehnder\n", os.Args[1])
+		return nil, fmt.Errorf("unknown HRU type '%s'. Valid options: xvent, meltem, atrea-rd5, atrea-am, korado, zehnder, dantherm, flexit-nordic, blauberg-vento, ducobox, vents-twinfresh, paul-novus-300, paul-novus-450, swegon-casa, lossnay, daikin-vam, atrea-ec5, thessla-airpack, enervent-eair, renson-endura, aldes, itho-hru-eco, comfoair350, aereco-dxr, wanas, generic, lua, lunos-pair, co2sensor, rht-sensor, voc-sensor, duct-sensors, pressure-sensor, damper, preheater, heating-valve, brine-pump, weather-station", deviceType)
+	}
+}
+
+// configFromFlags builds the config of a single listener from the command line.
+func configFromFlags() *Config {
+	listener := ListenerConfig{
+		Port:             *port,
+		Bind:             *bind,
+		Serial:           *rtuDevice,
+		PTY:              *pty,
+		PTYLink:          *ptyLink,
+		Framing:          *framing,
+		UDP:              *udp,
+		SingleConnection: *singleConnection,
+		BaudRate:         *rtuBaudRate,
+		DataBits:         *rtuDataBits,
+		Parity:           *rtuParity,
+		StopBits:         *rtuStopBits,
+	}
+	args := flag.Args()
+	if listener.isSerial() {
+		if listener.Port != "" || listener.Bind != "" || listener.SingleConnection != "" {
+			fmt.Fprintln(os.Stderr, "Error: --port, --bind and --single-connection are for Modbus TCP, not with --rtu or --pty.")
+			os.Exit(1)
+		}
+	} else {
+		if listener.Port == "" {
+			if len(args) < 1 {
+				fmt.Fprintf(os.Stderr, "Error: missing port, set it with --port, %sPORT or as first argument.\n", envPrefix)
+				usage()
+				os.Exit(1)
+			}
+			listener.Port, args = args[0], args[1:]
+		}
+		if number, err := strconv.ParseUint(listener.Port, 10, 16); err != nil || number == 0 {
+			fmt.Fprintf(os.Stderr, "Error: invalid port '%s', expected 1-65535. The port comes before the device type.\n", listener.Port)
+			os.Exit(1)
+		}
+	}
+	if len(args) < 1 && len(devices) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: missing device, give its type as argument or add devices with --device.")
+		usage()
 		os.Exit(1)
 	}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			fmt.Fprintf(os.Stderr, "Error: option %s after the arguments, options go before them.\n", arg)
+			os.Exit(1)
+		}
+	}
+	if len(args) > 2 {
+		fmt.Fprintf(os.Stderr, "Error: unexpected arguments %s, expected a device type and an optional file.\n", strings.Join(args[2:], " "))
+		os.Exit(1)
+	}
+	if *deviceUnitID > 247 {
+		fmt.Fprintf(os.Stderr, "Error: invalid unit ID %d, expected 0-247\n", *deviceUnitID)
+		os.Exit(1)
+	}
+	config := &Config{Broadcast: *broadcast, Listeners: []ListenerConfig{listener}, Devices: devices}
+	if len(args) > 0 {
+		file := ""
+		if len(args) > 1 {
+			file = args[1]
+		}
+		config.Devices = append([]DeviceConfig{{Unit: uint8(*deviceUnitID), Type: args[0], File: file}}, devices...)
+	}
+	return config
+}
 
+// newDeviceServer builds the device and the server answering its requests.
+func newDeviceServer(device DeviceConfig, api *API) (HRULogic, *mbserver.Server, error) {
+	logic, err := newDevice(device.Type, device.File, device.Parameters)
+	if err != nil {
+		return nil, nil, err
+	}
 	serv := mbserver.NewServer()
-	err := serv.ListenTCP("0.0.0.0:" + os.Args[1])
+	SetUnsupportedFunctionPolicy(serv, unsupportedFunctions.forUnit(device.Unit))
+	if device.Latency != (Latency{}) {
+		SetResponseLatency(serv, device.Latency)
+	} else {
+		SetResponseLatency(serv, latencies.forUnit(device.Unit))
+	}
+	EnableDiagnostics(serv)
+	if *logRequests {
+		Use(serv, LogRequests)
+	}
+	Use(serv, api.Watch(device.Unit))
+	if timed, ok := logic.(Timed); ok {
+		Use(serv, AdvanceTime(timed))
+	}
+	if faults := exceptionFaults.forUnit(device.Unit); len(faults) > 0 {
+		Use(serv, InjectExceptions(faults))
+	}
+	if busy := busyAfterWrite.forUnit(device.Unit); busy > 0 {
+		Use(serv, BusyAfterWrite(busy))
+	}
+	if *partialWrites > 0 {
+		Use(serv, PartialWrites(*partialWrites))
+	}
+	if reverts := append(append([]RegisterRevert{}, device.Revert...), revertWrites.forUnit(device.Unit)...); len(reverts) > 0 {
+		Use(serv, RevertWrites(reverts))
+	}
+	if linked, ok := logic.(linkedDevice); ok {
+		linked.link(api.logic)
+	}
+	logic.Configure(serv)
+	return logic, serv, nil
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if err := flagsFromEnvironment(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := applyChaos(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *tui && *repl {
+		fmt.Fprintln(os.Stderr, "Error: --tui and --repl both read from the terminal, use one of them.")
+		os.Exit(1)
+	}
+
+	seeded := false
+	flag.Visit(func(f *flag.Flag) { seeded = seeded || f.Name == "seed" })
+	if !seeded {
+		*seed = time.Now().UnixNano()
+	}
+	random.Seed(*seed)
+	fmt.Printf("Random seed %d (reproduce with --seed %d)\n", *seed, *seed)
+	if err := clock.SetScale(*timeScale); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var config *Config
+	if *configFile != "" {
+		if len(flag.Args()) > 0 || len(devices) > 0 || *port != "" || *bind != "" || *singleConnection != "" {
+			fmt.Fprintln(os.Stderr, "Error: --config describes the devices and ports, no arguments, --device, --port, --bind or --single-connection expected.")
+			os.Exit(1)
+		}
+		var err error
+		if config, err = LoadConfig(*configFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		config = configFromFlags()
+	}
+	var scenario *Scenario
+	if *scenarioFile != "" {
+		var err error
+		if scenario, err = LoadScenario(*scenarioFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	broadcastWrites, err := config.broadcastWrites()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	bus := NewBus(broadcastWrites)
+	var faults Faults
+	if *transactionIDFault != "" {
+		if err := parseTransactionIDFault(*transactionIDFault, &faults); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *corruptResponses != "" {
+		if err := parseCorruptResponses(*corruptResponses, &faults); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *slowResponses != "" {
+		if err := parseSlowResponses(*slowResponses, &faults); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *dropConnections < 0 || *dropConnections > 1 {
+		fmt.Fprintf(os.Stderr, "Error: invalid fraction %v of --drop-connections, expected a number between 0 and 1\n", *dropConnections)
 		os.Exit(1)
 	}
-	defer serv.Close()
+	faults.DropFraction = *dropConnections
+	if *duplicateResponses < 0 || *duplicateResponses > 1 {
+		fmt.Fprintf(os.Stderr, "Error: invalid fraction %v of --duplicate-responses, expected a number between 0 and 1\n", *duplicateResponses)
+		os.Exit(1)
+	}
+	faults.DuplicateFraction = *duplicateResponses
+	if *noResponse < 0 || *noResponse > 1 {
+		fmt.Fprintf(os.Stderr, "Error: invalid fraction %v of --no-response, expected a number between 0 and 1\n", *noResponse)
+		os.Exit(1)
+	}
+	faults.SilenceFraction = *noResponse
+	if *partialWrites < 0 || *partialWrites > 1 {
+		fmt.Fprintf(os.Stderr, "Error: invalid fraction %v of --partial-writes, expected a number between 0 and 1\n", *partialWrites)
+		os.Exit(1)
+	}
+	if *crcErrors < 0 || *crcErrors > 1 {
+		fmt.Fprintf(os.Stderr, "Error: invalid fraction %v of --crc-errors, expected a number between 0 and 1\n", *crcErrors)
+		os.Exit(1)
+	}
+	faults.CRCFraction = *crcErrors
+	bus.SetFaults(faults)
+	if *gatewayFault != "" {
+		exception, unitIDs, err := parseGatewayFault(*gatewayFault)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		bus.SetGatewayFault(exception, unitIDs)
+	}
+	api := NewAPI(bus)
+	names := make([]string, 0, len(config.Devices))
+	for _, device := range config.Devices {
+		logic, serv, err := newDeviceServer(device, api)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := bus.Add(device.Unit, serv); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		api.Add(device, logic)
+		if device.Unit == 0 {
+			names = append(names, device.Type)
+		} else {
+			names = append(names, fmt.Sprintf("%s (unit %d)", device.Type, device.Unit))
+		}
+	}
+	name := strings.Join(names, ", ")
+	if *restoreFile != "" {
+		snapshot, err := ReadSnapshot(*restoreFile)
+		if err == nil {
+			err = api.Restore(snapshot)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", *restoreFile, err)
+			os.Exit(1)
+		}
+	}
+	if *stateFile != "" {
+		if err := api.PersistState(*stateFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	logic.Configure(serv)
+	api.DumpOnSignal(*dumpFile)
+
+	if *apiAddress != "" {
+		if err := api.ListenAndServe(*apiAddress); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Serving the API on %s\n", *apiAddress)
+	}
+	if *grpcAddress != "" {
+		if err := api.ListenGRPC(*grpcAddress); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Serving the gRPC API on %s\n", *grpcAddress)
+	}
+	if *apiAddress != "" || *grpcAddress != "" {
+		go api.PublishTimedChanges()
+	}
+
+	var tlsConfig *tls.Config
+	if *tlsCert != "" {
+		if tlsConfig, err = NewTLSConfig(*tlsCert, *tlsKey, *tlsCA); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+	for _, listener := range config.Listeners {
+		where, err := listener.Listen(bus, tlsConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if listener.isSerial() {
+			fmt.Printf("Serving on %s as %s (hit Ctrl+C to stop)\n", where, name)
+		} else {
+			fmt.Printf("Listening on %s as %s (hit Ctrl+C to stop)\n", where, name)
+		}
+	}
+
+	var stopScenario func()
+	if scenario != nil {
+		if stopScenario, err = StartScenario(api, scenario); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", *scenarioFile, err)
+			os.Exit(1)
+		}
+	}
+	if *watch {
+		reloader := &Reloader{
+			bus:          bus,
+			api:          api,
+			config:       config,
+			configPath:   *configFile,
+			scenarioPath: *scenarioFile,
+			stopScenario: stopScenario,
+		}
+		go reloader.Run(time.Second)
+	}
 
-	fmt.Printf("Listening on %s as %s (hit Ctrl+C to stop)\n", os.Args[1], os.Args[2])
+	saveOnExit := func() {
+		if *stateFile != "" {
+			if err := WriteSnapshot(*stateFile, api.Snapshot()); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+			}
+		}
+		if *snapshotFile == "" {
+			return
+		}
+		if err := WriteSnapshot(*snapshotFile, api.Snapshot()); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return
+		}
+		fmt.Printf("Snapshot written to %s\n", *snapshotFile)
+	}
+	if *snapshotFile != "" || *stateFile != "" {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			saveOnExit()
+			os.Exit(0)
+		}()
+	}
 
+	if *tui {
+		if err := RunTUI(api); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		saveOnExit()
+		return
+	}
+	if *repl {
+		if err := RunREPL(api, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+	}
 	for {
 		time.Sleep(1 * time.Second)
 	}
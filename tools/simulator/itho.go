@@ -0,0 +1,131 @@
+package main
+
+import (
+	"log"
+	"math"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// IthoHRUEco simulates an HRU ECO 350. The unit is driven by a single fan setpoint in percent,
+// everything else is read-only status.
+type IthoHRUEco struct {
+	fanSetpoint        int
+	maxFanRPM          int
+	bypassPosition     int
+	errorCode          int
+	supplyTemperature  float64
+	extractTemperature float64
+	operatingHours     int
+}
+
+func NewIthoHRUEco() *IthoHRUEco {
+	return &IthoHRUEco{
+		fanSetpoint:        40,
+		maxFanRPM:          3200,
+		bypassPosition:     0,
+		errorCode:          0,
+		supplyTemperature:  19.6,
+		extractTemperature: 21.7,
+		operatingHours:     4380,
+	}
+}
+
+func (i *IthoHRUEco) fanRPM() int {
+	return i.maxFanRPM * i.fanSetpoint / 100
+}
+
+// status returns the status word: 0 = standby, 1 = running, 2 = fault.
+func (i *IthoHRUEco) status() int {
+	if i.errorCode != 0 {
+		return 2
+	}
+	if i.fanSetpoint == 0 {
+		return 0
+	}
+	return 1
+}
+
+// alarmRegisters reports the number of the active error in input register 3.
+func (i *IthoHRUEco) alarmRegisters() []alarmRegister {
+	return []alarmRegister{{code: &i.errorCode}}
+}
+
+// State implements HRULogic.
+func (i *IthoHRUEco) State() DeviceState {
+	return DeviceState{
+		"fanSetpoint":        &i.fanSetpoint,
+		"maxFanRPM":          &i.maxFanRPM,
+		"bypassPosition":     &i.bypassPosition,
+		"errorCode":          &i.errorCode,
+		"supplyTemperature":  &i.supplyTemperature,
+		"extractTemperature": &i.extractTemperature,
+		"operatingHours":     &i.operatingHours,
+	}
+}
+
+func (i *IthoHRUEco) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Itho Daalderop", ProductCode: "HRU ECO", Revision: "2.7"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(i.fanSetpoint)}, &Success
+		}
+		if register == 1 {
+			return []uint16{uint16(i.bypassPosition)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(i.status())}, &Success
+		}
+		if register == 1 {
+			return []uint16{uint16(i.fanRPM())}, &Success
+		}
+		if register == 2 {
+			return []uint16{uint16(i.fanRPM())}, &Success
+		}
+		if register == 3 {
+			return []uint16{uint16(i.errorCode)}, &Success
+		}
+		if register == 4 {
+			return []uint16{uint16(int16(math.Round(i.supplyTemperature * 100)))}, &Success
+		}
+		if register == 5 {
+			return []uint16{uint16(int16(math.Round(i.extractTemperature * 100)))}, &Success
+		}
+		if register == 6 {
+			return []uint16{uint16(i.operatingHours)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 0 {
+			if value > 100 {
+				return &IllegalDataValue
+			}
+			i.fanSetpoint = int(value)
+			log.Printf(">>> CHANGE: fanSetpoint=%d, fanRPM=%d\n", i.fanSetpoint, i.fanRPM())
+			return &Success
+		}
+		if register == 1 {
+			if value > 100 {
+				return &IllegalDataValue
+			}
+			i.bypassPosition = int(value)
+			log.Printf(">>> CHANGE: bypassPosition=%d\n", i.bypassPosition)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnReadExceptionStatus(serv, func() uint8 {
+		var status uint8
+		if i.errorCode != 0 {
+			status |= exceptionStatusFault
+		}
+		return status
+	})
+}
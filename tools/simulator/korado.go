@@ -15,30 +15,39 @@ type Korado struct {
 func NewKorado() *Korado {
 	return &Korado{
 		power:     20,
-		lastAlive: time.Now(),
+		lastAlive: clock.Now(),
+	}
+}
+
+// State implements HRULogic.
+func (k *Korado) State() DeviceState {
+	return DeviceState{
+		"power":     &k.power,
+		"lastAlive": &k.lastAlive,
 	}
 }
 
 func (k *Korado) Configure(serv *Server) {
-	OnReadInputRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
-		if register == 100 && numRegs == 1 {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Korado", ProductCode: "Ventbox", Revision: "1.3"})
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 100 {
 			return []uint16{uint16(12345)}, &Success
 		}
-		if register == 107 && numRegs == 1 {
+		if register == 107 {
 			return []uint16{uint16(k.power)}, &Success
 		}
-		if (register >= 110 && register <= 114) && numRegs == 1 {
+		if register >= 110 && register <= 114 {
 			return []uint16{uint16(200)}, &Success
 		}
 		return []uint16{}, &IllegalDataAddress
-	})
+	}))
 	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
 		if register == 106 {
-			if time.Since(k.lastAlive) <= 30*time.Second {
+			if clock.Since(k.lastAlive) <= 30*time.Second {
 				k.power = int(value)
 				log.Printf(">>> CHANGE: power=%d\n", k.power)
 			} else {
-				log.Printf("ignored because last alive %v\n", time.Since(k.lastAlive))
+				log.Printf("ignored because last alive %v\n", clock.Since(k.lastAlive))
 			}
 			return &Success
 		}
@@ -47,9 +56,16 @@ func (k *Korado) Configure(serv *Server) {
 	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
 		return &IllegalFunction
 	})
+	OnReadCoils(serv, func(address uint16, numCoils int) ([]bool, *Exception) {
+		// the alive coil reads back as set until the watchdog expires
+		if address == 31 && numCoils == 1 {
+			return []bool{clock.Since(k.lastAlive) <= 30*time.Second}, &Success
+		}
+		return []bool{}, &IllegalDataAddress
+	})
 	OnWriteCoil(serv, func(address uint16, value bool) *Exception {
 		if address == 31 && value {
-			k.lastAlive = time.Now()
+			k.lastAlive = clock.Now()
 			return &Success
 		}
 		return &IllegalDataAddress
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Latency is the response delay of a device: the base delay plus random jitter, written as
+// base[~jitter[:distribution]], e.g. 150ms or 100ms~40ms:pareto. The distribution shapes the
+// jitter like the latency profiles of real serial gateways:
+//
+//	uniform  between 0 and the jitter (default)
+//	normal   with the jitter as standard deviation, never below 0
+//	pareto   heavy-tailed with the jitter as mean, capped at 100 times the jitter
+type Latency struct {
+	Base         time.Duration
+	Jitter       time.Duration
+	Distribution string
+}
+
+// latencyDistributions are the jitter distributions, drawing a multiple of the jitter.
+var latencyDistributions = map[string]func() float64{
+	"uniform": func() float64 { return random.Float64() },
+	"normal":  func() float64 { return math.Max(random.NormFloat64(), 0) },
+	// shape 2, its mean is the scale
+	"pareto": func() float64 { return math.Min(1/math.Sqrt(1-random.Float64())-1, 100) },
+}
+
+func ParseLatency(value string) (Latency, error) {
+	var latency Latency
+	base, jitter, hasJitter := strings.Cut(value, "~")
+	var err error
+	if latency.Base, err = time.ParseDuration(base); err != nil || latency.Base < 0 {
+		return latency, fmt.Errorf("invalid latency '%s', expected a duration like 150ms", base)
+	}
+	if !hasJitter {
+		return latency, nil
+	}
+	jitter, latency.Distribution, _ = strings.Cut(jitter, ":")
+	if latency.Jitter, err = time.ParseDuration(jitter); err != nil || latency.Jitter < 0 {
+		return latency, fmt.Errorf("invalid jitter '%s', expected a duration like 50ms", jitter)
+	}
+	if latency.Distribution == "" {
+		latency.Distribution = "uniform"
+	}
+	if _, ok := latencyDistributions[latency.Distribution]; !ok {
+		return latency, fmt.Errorf("unknown jitter distribution '%s'. Valid options: uniform, normal, pareto", latency.Distribution)
+	}
+	return latency, nil
+}
+
+func (l Latency) String() string {
+	if l.Jitter == 0 {
+		return l.Base.String()
+	}
+	return fmt.Sprintf("%s~%s:%s", l.Base, l.Jitter, l.Distribution)
+}
+
+func (l *Latency) UnmarshalText(text []byte) error {
+	parsed, err := ParseLatency(string(text))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
+
+func (l Latency) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// delay draws the delay of one response.
+func (l Latency) delay() time.Duration {
+	if l.Jitter == 0 {
+		return l.Base
+	}
+	return l.Base + time.Duration(latencyDistributions[l.Distribution]()*float64(l.Jitter))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"log"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const (
+	lossnayModeAuto         = 0
+	lossnayModeHeatExchange = 1
+	lossnayModeBypass       = 2
+)
+
+type Lossnay struct {
+	powerOn            bool
+	fanSpeed           int
+	ventilationMode    int
+	nightPurge         bool
+	outdoorTemperature int
+	roomTemperature    int
+}
+
+func NewLossnay() *Lossnay {
+	return &Lossnay{
+		powerOn:            true,
+		fanSpeed:           2,
+		ventilationMode:    lossnayModeAuto,
+		nightPurge:         false,
+		outdoorTemperature: 18,
+		roomTemperature:    24,
+	}
+}
+
+// effectiveMode resolves the automatic ventilation mode the same way the unit does: bypass when
+// the outdoor air can cool the room, heat exchange otherwise. Night purge always forces bypass.
+func (l *Lossnay) effectiveMode() int {
+	if l.nightPurge {
+		return lossnayModeBypass
+	}
+	if l.ventilationMode != lossnayModeAuto {
+		return l.ventilationMode
+	}
+	if l.outdoorTemperature < l.roomTemperature && l.outdoorTemperature > 15 {
+		return lossnayModeBypass
+	}
+	return lossnayModeHeatExchange
+}
+
+// State implements HRULogic.
+func (l *Lossnay) State() DeviceState {
+	return DeviceState{
+		"powerOn":            &l.powerOn,
+		"fanSpeed":           &l.fanSpeed,
+		"ventilationMode":    &l.ventilationMode,
+		"nightPurge":         &l.nightPurge,
+		"outdoorTemperature": &l.outdoorTemperature,
+		"roomTemperature":    &l.roomTemperature,
+	}
+}
+
+func (l *Lossnay) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Mitsubishi Electric", ProductCode: "Lossnay LGH", Revision: "5.01"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			if l.powerOn {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		if register == 1 {
+			return []uint16{uint16(l.fanSpeed)}, &Success
+		}
+		if register == 2 {
+			return []uint16{uint16(l.ventilationMode)}, &Success
+		}
+		if register == 3 {
+			if l.nightPurge {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(l.effectiveMode())}, &Success
+		}
+		if register == 1 {
+			return []uint16{uint16(int16(l.outdoorTemperature))}, &Success
+		}
+		if register == 2 {
+			return []uint16{uint16(int16(l.roomTemperature))}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 0 {
+			l.powerOn = value != 0
+			log.Printf(">>> CHANGE: powerOn=%v\n", l.powerOn)
+			return &Success
+		}
+		if register == 1 {
+			if value < 1 || value > 4 {
+				return &IllegalDataValue
+			}
+			l.fanSpeed = int(value)
+			log.Printf(">>> CHANGE: fanSpeed=%d\n", l.fanSpeed)
+			return &Success
+		}
+		if register == 2 {
+			if value > lossnayModeBypass {
+				return &IllegalDataValue
+			}
+			l.ventilationMode = int(value)
+			log.Printf(">>> CHANGE: ventilationMode=%d, effectiveMode=%d\n", l.ventilationMode, l.effectiveMode())
+			return &Success
+		}
+		if register == 3 {
+			l.nightPurge = value != 0
+			log.Printf(">>> CHANGE: nightPurge=%v, effectiveMode=%d\n", l.nightPurge, l.effectiveMode())
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	. "github.com/tbrandon/mbserver"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Lua is a device scripted in Lua, for register behavior the generic register maps cannot declare,
+// e.g. conditional responses or counters. The script defines a handler for every function the device
+// supports, the other ones are answered like unimplemented functions:
+//
+//	read_holding_registers(address, count)    returns a table of count values
+//	read_input_registers(address, count)      returns a table of count values
+//	write_holding_registers(address, values)  FC06 and FC16, values is a table
+//	read_coils(address, count)                returns a table of count booleans
+//	read_discrete_inputs(address, count)      returns a table of count booleans
+//	write_coils(address, values)              FC05 and FC15, values is a table of booleans
+//
+// A handler fails the request by returning nil and the exception code, e.g. return nil, 2 for Illegal
+// Data Address, unknown codes fail it with Slave Device Failure. Globals keep their values between
+// requests. The global identification table sets vendorName, productCode and revision, log(...)
+// writes to the simulator log. math.random draws from the simulator's random source, so --seed
+// makes scripts reproducible as well.
+type Lua struct {
+	state          *lua.LState
+	identification DeviceIdentification
+}
+
+func NewLua(path string) (*Lua, error) {
+	state := lua.NewState()
+	state.SetGlobal("log", state.NewFunction(func(L *lua.LState) int {
+		parts := make([]string, L.GetTop())
+		for i := range parts {
+			parts[i] = L.ToStringMeta(L.Get(i + 1)).String()
+		}
+		log.Printf(">>> SCRIPT: %s\n", strings.Join(parts, " "))
+		return 0
+	}))
+	// the same arguments as the built-in math.random
+	state.GetGlobal("math").(*lua.LTable).RawSetString("random", state.NewFunction(func(L *lua.LState) int {
+		switch L.GetTop() {
+		case 0:
+			L.Push(lua.LNumber(random.Float64()))
+		case 1:
+			high := L.CheckInt(1)
+			if high < 1 {
+				L.ArgError(1, "interval is empty")
+			}
+			L.Push(lua.LNumber(random.Intn(high) + 1))
+		default:
+			low, high := L.CheckInt(1), L.CheckInt(2)
+			if high < low {
+				L.ArgError(2, "interval is empty")
+			}
+			L.Push(lua.LNumber(random.Intn(high-low+1) + low))
+		}
+		return 1
+	}))
+	if err := state.DoFile(path); err != nil {
+		state.Close()
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+
+	l := &Lua{
+		state:          state,
+		identification: DeviceIdentification{VendorName: "Luftuj", ProductCode: "Lua", Revision: "1.0.0"},
+	}
+	if identification, ok := state.GetGlobal("identification").(*lua.LTable); ok {
+		field := func(name string, value *string) {
+			if s, ok := identification.RawGetString(name).(lua.LString); ok {
+				*value = string(s)
+			}
+		}
+		field("vendorName", &l.identification.VendorName)
+		field("productCode", &l.identification.ProductCode)
+		field("revision", &l.identification.Revision)
+	}
+	return l, nil
+}
+
+func (l *Lua) has(handler string) bool {
+	_, ok := l.state.GetGlobal(handler).(*lua.LFunction)
+	return ok
+}
+
+// call calls the handler and returns its result, script errors fail the request with Slave Device
+// Failure.
+func (l *Lua) call(handler string, args ...lua.LValue) (lua.LValue, *Exception) {
+	err := l.state.CallByParam(lua.P{Fn: l.state.GetGlobal(handler), NRet: 2, Protect: true}, args...)
+	if err != nil {
+		log.Printf("Script %s failed: %v\n", handler, err)
+		return lua.LNil, &SlaveDeviceFailure
+	}
+	result, code := l.state.Get(-2), l.state.Get(-1)
+	l.state.Pop(2)
+	if result == lua.LNil {
+		if code, ok := code.(lua.LNumber); ok {
+			return lua.LNil, luaException(handler, code)
+		}
+	}
+	return result, &Success
+}
+
+// luaExceptions are the exceptions a handler can return by their code. Exceptions are compared by
+// pointer, so the codes map to the package variables.
+var luaExceptions = map[lua.LNumber]*Exception{
+	1:  &IllegalFunction,
+	2:  &IllegalDataAddress,
+	3:  &IllegalDataValue,
+	4:  &SlaveDeviceFailure,
+	5:  &AcknowledgeSlave,
+	6:  &SlaveDeviceBusy,
+	7:  &NegativeAcknowledge,
+	8:  &MemoryParityError,
+	10: &GatewayPathUnavailable,
+	11: &GatewayTargetDeviceFailedtoRespond,
+}
+
+// luaException returns the exception of the code, unknown codes fail the request with Slave Device
+// Failure.
+func luaException(handler string, code lua.LNumber) *Exception {
+	exception, ok := luaExceptions[code]
+	if !ok {
+		log.Printf("Script %s returned the invalid exception code %v\n", handler, code)
+		return &SlaveDeviceFailure
+	}
+	return exception
+}
+
+// values converts the table returned by a read handler.
+func (l *Lua) values(handler string, result lua.LValue, count int) ([]lua.LValue, *Exception) {
+	table, ok := result.(*lua.LTable)
+	if !ok || table.Len() != count {
+		log.Printf("Script %s returned %s, expected a table of %d values\n", handler, result.Type(), count)
+		return nil, &SlaveDeviceFailure
+	}
+	values := make([]lua.LValue, count)
+	for i := range values {
+		values[i] = table.RawGetInt(i + 1)
+	}
+	return values, &Success
+}
+
+func (l *Lua) readRegisters(handler string, address uint16, count int) ([]uint16, *Exception) {
+	result, err := l.call(handler, lua.LNumber(address), lua.LNumber(count))
+	if err != &Success {
+		return []uint16{}, err
+	}
+	values, err := l.values(handler, result, count)
+	if err != &Success {
+		return []uint16{}, err
+	}
+	registers := make([]uint16, count)
+	for i, value := range values {
+		number, ok := value.(lua.LNumber)
+		if !ok {
+			log.Printf("Script %s returned %s at %d, expected a number\n", handler, value.Type(), i+1)
+			return []uint16{}, &SlaveDeviceFailure
+		}
+		registers[i] = uint16(int64(number))
+	}
+	return registers, &Success
+}
+
+func (l *Lua) readBits(handler string, address uint16, count int) ([]bool, *Exception) {
+	result, err := l.call(handler, lua.LNumber(address), lua.LNumber(count))
+	if err != &Success {
+		return []bool{}, err
+	}
+	values, err := l.values(handler, result, count)
+	if err != &Success {
+		return []bool{}, err
+	}
+	bits := make([]bool, count)
+	for i, value := range values {
+		bits[i] = lua.LVAsBool(value)
+	}
+	return bits, &Success
+}
+
+func (l *Lua) writeRegisters(address uint16, values []uint16) *Exception {
+	table := l.state.NewTable()
+	for _, value := range values {
+		table.Append(lua.LNumber(value))
+	}
+	_, err := l.call("write_holding_registers", lua.LNumber(address), table)
+	return err
+}
+
+func (l *Lua) writeBits(address uint16, values []bool) *Exception {
+	table := l.state.NewTable()
+	for _, value := range values {
+		table.Append(lua.LBool(value))
+	}
+	_, err := l.call("write_coils", lua.LNumber(address), table)
+	return err
+}
+
+// State implements HRULogic. The state of a script lives in its Lua globals, it has no variables the
+// API or snapshots could reach.
+func (l *Lua) State() DeviceState {
+	return DeviceState{}
+}
+
+func (l *Lua) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, l.identification)
+	if l.has("read_holding_registers") {
+		OnReadHoldingRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
+			return l.readRegisters("read_holding_registers", register, numRegs)
+		})
+	}
+	if l.has("read_input_registers") {
+		OnReadInputRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
+			return l.readRegisters("read_input_registers", register, numRegs)
+		})
+	}
+	if l.has("write_holding_registers") {
+		OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+			return l.writeRegisters(register, []uint16{value})
+		})
+		OnWriteHoldingRegisters(serv, l.writeRegisters)
+	}
+	if l.has("read_coils") {
+		OnReadCoils(serv, func(address uint16, numCoils int) ([]bool, *Exception) {
+			return l.readBits("read_coils", address, numCoils)
+		})
+	}
+	if l.has("read_discrete_inputs") {
+		OnReadDiscreteInputs(serv, func(address uint16, numInputs int) ([]bool, *Exception) {
+			return l.readBits("read_discrete_inputs", address, numInputs)
+		})
+	}
+	if l.has("write_coils") {
+		OnWriteCoil(serv, func(address uint16, value bool) *Exception {
+			return l.writeBits(address, []bool{value})
+		})
+		OnWriteMultipleCoils(serv, l.writeBits)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const (
+	lunosDirectionSupply  = 0
+	lunosDirectionExtract = 1
+
+	lunosModeHeatRecovery = 0
+	lunosModeSummer       = 1
+)
+
+// LunosPair simulates a pair of reversing push-pull units (e2 / eGO). In heat recovery mode both
+// units reverse every cycle and always blow in opposite directions; summer mode stops the
+// reversal so one unit keeps supplying and the other keeps extracting.
+type LunosPair struct {
+	speed        int
+	mode         int
+	cycleSeconds int
+	cycleStart   time.Time
+}
+
+func NewLunosPair() *LunosPair {
+	return &LunosPair{
+		speed:        2,
+		mode:         lunosModeHeatRecovery,
+		cycleSeconds: 70,
+		cycleStart:   clock.Now(),
+	}
+}
+
+// cycles returns the number of completed cycles and the seconds left until the next reversal.
+func (l *LunosPair) cycles() (int, int) {
+	elapsed := int(clock.Since(l.cycleStart).Seconds())
+	return elapsed / l.cycleSeconds, l.cycleSeconds - elapsed%l.cycleSeconds
+}
+
+// directions returns the current airflow direction of the first and second unit.
+func (l *LunosPair) directions() (int, int) {
+	if l.mode == lunosModeSummer {
+		return lunosDirectionSupply, lunosDirectionExtract
+	}
+	cycles, _ := l.cycles()
+	if cycles%2 == 0 {
+		return lunosDirectionSupply, lunosDirectionExtract
+	}
+	return lunosDirectionExtract, lunosDirectionSupply
+}
+
+// State implements HRULogic.
+func (l *LunosPair) State() DeviceState {
+	return DeviceState{
+		"speed":        &l.speed,
+		"mode":         &l.mode,
+		"cycleSeconds": &l.cycleSeconds,
+		"cycleStart":   &l.cycleStart,
+	}
+}
+
+func (l *LunosPair) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "LUNOS", ProductCode: "e2 pair", Revision: "1.4"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 1 {
+			return []uint16{uint16(l.speed)}, &Success
+		}
+		if register == 2 {
+			return []uint16{uint16(l.mode)}, &Success
+		}
+		if register == 3 {
+			return []uint16{uint16(l.cycleSeconds)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 1 {
+			first, _ := l.directions()
+			return []uint16{uint16(first)}, &Success
+		}
+		if register == 2 {
+			_, second := l.directions()
+			return []uint16{uint16(second)}, &Success
+		}
+		if register == 3 {
+			if l.mode == lunosModeSummer {
+				return []uint16{0}, &Success
+			}
+			_, remaining := l.cycles()
+			return []uint16{uint16(remaining)}, &Success
+		}
+		if register == 4 {
+			cycles, _ := l.cycles()
+			return []uint16{uint16(cycles)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 1 {
+			if value > 4 {
+				return &IllegalDataValue
+			}
+			l.speed = int(value)
+			log.Printf(">>> CHANGE: speed=%d\n", l.speed)
+			return &Success
+		}
+		if register == 2 {
+			if value > lunosModeSummer {
+				return &IllegalDataValue
+			}
+			l.mode = int(value)
+			l.cycleStart = clock.Now()
+			log.Printf(">>> CHANGE: mode=%d\n", l.mode)
+			return &Success
+		}
+		if register == 3 {
+			if value < 30 || value > 300 {
+				return &IllegalDataValue
+			}
+			l.cycleSeconds = int(value)
+			l.cycleStart = clock.Now()
+			log.Printf(">>> CHANGE: cycleSeconds=%d\n", l.cycleSeconds)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+}
@@ -2,66 +2,134 @@ package main
 
 import (
 	"log"
+	"time"
 
 	. "github.com/tbrandon/mbserver"
 )
 
+// meltemIntensiveFlow is the flow of both fans during intensive ventilation in m³/h.
+const meltemIntensiveFlow = 100
+
 type Meltem struct {
-	inFlow     int
-	outFlow    int
-	editMode   int
-	reqInFlow  int
-	reqOutFlow int
+	inFlow           int
+	outFlow          int
+	intensive        bool
+	intensiveMinutes int
+	// intensiveStarted is the simulated time intensive ventilation was started, zero while it is off.
+	intensiveStarted time.Time
 }
 
 func NewMeltem() *Meltem {
 	return &Meltem{
-		inFlow:   0,
-		outFlow:  0,
-		editMode: 0,
+		inFlow:           0,
+		outFlow:          0,
+		intensiveMinutes: 15,
+	}
+}
+
+// Advance ends intensive ventilation once its minutes elapsed, the fans return to the written flows.
+// Intensive ventilation started over the API runs from the first time it is seen.
+func (m *Meltem) Advance(now time.Time) {
+	if !m.intensive {
+		m.intensiveStarted = time.Time{}
+	} else if m.intensiveStarted.IsZero() {
+		m.intensiveStarted = now
+	} else if now.Sub(m.intensiveStarted) >= time.Duration(m.intensiveMinutes)*time.Minute {
+		m.intensive = false
+		m.intensiveStarted = time.Time{}
+		log.Printf(">>> CHANGE intensive=%v, ended\n", m.intensive)
+	}
+}
+
+// flows returns the current supply and extract flows in m³/h.
+func (m *Meltem) flows() (int, int) {
+	if m.intensive {
+		return meltemIntensiveFlow, meltemIntensiveFlow
+	}
+	return m.inFlow, m.outFlow
+}
+
+// State implements HRULogic.
+func (m *Meltem) State() DeviceState {
+	return DeviceState{
+		"inFlow":           &m.inFlow,
+		"outFlow":          &m.outFlow,
+		"intensive":        &m.intensive,
+		"intensiveMinutes": &m.intensiveMinutes,
+		"intensiveStarted": &m.intensiveStarted,
 	}
 }
 
 func (m *Meltem) Configure(serv *Server) {
-	OnReadHoldingRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
-		return []uint16{}, &IllegalDataAddress
-	})
-	OnReadInputRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
-		if register == 41020 && numRegs == 1 {
-			return []uint16{uint16(m.outFlow)}, &Success
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Meltem", ProductCode: "M-WRG-II", Revision: "2.1.8"})
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		inFlow, outFlow := m.flows()
+		if register == 41020 {
+			return []uint16{uint16(outFlow)}, &Success
 		}
-		if register == 41021 && numRegs == 1 {
-			return []uint16{uint16(m.inFlow)}, &Success
+		if register == 41021 {
+			return []uint16{uint16(inFlow)}, &Success
 		}
 		return []uint16{}, &IllegalDataAddress
-	})
-	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
-		if register == 41120 {
-			m.editMode = int(value)
-			return &Success
-		}
-		if register == 41121 {
-			m.reqInFlow = int(value / 2)
-			return &Success
-		}
-		if register == 41122 {
-			m.reqOutFlow = int(value / 2)
-			return &Success
-		}
-		if register == 41132 {
-			if value == 0 && m.editMode == 4 {
-				m.inFlow = m.reqInFlow
-				m.outFlow = m.reqOutFlow
-				m.editMode = 0
-				log.Printf(">>> CHANGE inFlow=%d, outFlow=%d\n", m.inFlow, m.outFlow)
+	}))
+	// the requested flows are written in twice the m³/h at 41121-41122 after entering edit mode 4
+	// at 41120, and applied by writing 0 to 41132
+	registers := RegisterMap{
+		41121: {
+			Set: func(value float64) *Exception {
+				m.inFlow = int(value)
+				log.Printf(">>> CHANGE inFlow=%d\n", m.inFlow)
 				return &Success
-			}
-			log.Printf("Invalid edit mode: %d, confirm value: %d\n", m.editMode, value)
-			return &IllegalDataValue
-		}
-
-		return &IllegalDataAddress
-	})
+			},
+			Scale: 2,
+		},
+		41122: {
+			Set: func(value float64) *Exception {
+				m.outFlow = int(value)
+				log.Printf(">>> CHANGE outFlow=%d\n", m.outFlow)
+				return &Success
+			},
+			Scale: 2,
+		},
+		// intensive ventilation runs both fans at full flow for the minutes at 41141, writing 1 starts
+		// it over, writing 0 ends it
+		41140: {
+			Get: func() float64 {
+				if m.intensive {
+					return 1
+				}
+				return 0
+			},
+			Set: func(value float64) *Exception {
+				m.intensive = value == 1
+				m.intensiveStarted = time.Time{}
+				if m.intensive {
+					m.intensiveStarted = clock.Now()
+				}
+				log.Printf(">>> CHANGE intensive=%v\n", m.intensive)
+				return &Success
+			},
+			Max: 1,
+		},
+		41141: {
+			Get: func() float64 { return float64(m.intensiveMinutes) },
+			Set: func(value float64) *Exception {
+				m.intensiveMinutes = int(value)
+				log.Printf(">>> CHANGE intensiveMinutes=%d\n", m.intensiveMinutes)
+				return &Success
+			},
+			Min: 1,
+			Max: 240,
+		},
+	}
+	sequence := &EditSequence{
+		Unlock:  EditWrite{Address: 41120, Value: 4},
+		Payload: []uint16{41121, 41122},
+		Confirm: &EditWrite{Address: 41132, Value: 0},
+	}
+	sequence.Apply(registers)
+	OnReadHoldingRegisters(serv, registers.Read)
+	OnWriteHoldingRegister(serv, registers.WriteSingle)
 	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
 		return &IllegalFunction
 	})
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// Middleware wraps the function handlers of a server, so cross-cutting features like logging,
+// fault injection, access control or metrics apply to every device without touching its handlers.
+// It runs after the request has been validated and may answer it itself instead of calling next.
+type Middleware func(next functionHandler) functionHandler
+
+// middlewares holds the middleware chain of each server.
+var middlewares = map[*Server][]Middleware{}
+
+// Use appends middleware to the chain of the server. The first one is the outermost, it applies to
+// handlers registered before and after the call.
+func Use(s *Server, middleware ...Middleware) {
+	middlewares[s] = append(middlewares[s], middleware...)
+}
+
+// withMiddleware wraps the handler in the middleware chain of the server.
+func withMiddleware(s *Server, function functionHandler) functionHandler {
+	chain := middlewares[s]
+	for i := len(chain) - 1; i >= 0; i-- {
+		function = chain[i](function)
+	}
+	return function
+}
+
+// AdvanceTime brings the state of the device up to the simulated time before every request.
+func AdvanceTime(device Timed) Middleware {
+	return func(next functionHandler) functionHandler {
+		return func(s *Server, request Framer) ([]byte, *Exception) {
+			device.Advance(clock.Now())
+			return next(s, request)
+		}
+	}
+}
+
+// LogRequests logs every request with its response data or exception.
+func LogRequests(next functionHandler) functionHandler {
+	return func(s *Server, request Framer) ([]byte, *Exception) {
+		data, exception := next(s, request)
+		if exception == &Success {
+			log.Printf("<<< REQUEST: function %d [% x] answered [% x]\n", request.GetFunction(), request.GetData(), data)
+		} else {
+			log.Printf("<<< REQUEST: function %d [% x] answered %s\n", request.GetFunction(), request.GetData(), exception.String())
+		}
+		return data, exception
+	}
+}
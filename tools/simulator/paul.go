@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// PaulNovus simulates a Paul Novus 300 or 450, the model only differs in the nominal airflow
+// of each fan stage.
+type PaulNovus struct {
+	model              int
+	fanStage           int
+	bypassMode         int
+	bypassOpen         bool
+	bypassMinOutdoor   float64
+	outdoorTemperature float64
+	supplyTemperature  float64
+	extractTemperature float64
+	exhaustTemperature float64
+	fans               FanRamp
+	thermal            ThermalModel
+}
+
+func NewPaulNovus(model int) *PaulNovus {
+	p := &PaulNovus{
+		model:              model,
+		fanStage:           2,
+		bypassMode:         0,
+		bypassMinOutdoor:   13,
+		outdoorTemperature: 16.5,
+		supplyTemperature:  19.8,
+		extractTemperature: 23.1,
+		exhaustTemperature: 19.9,
+		fans:               NewFanRamp(50),
+		thermal:            NewThermalModel(0.9),
+	}
+	p.updateBypass()
+	return p
+}
+
+// airflow returns the nominal airflow of the current fan stage in m3/h.
+func (p *PaulNovus) airflow() int {
+	return p.fanStage * p.model / 4
+}
+
+// currentAirflow returns the airflow the fans deliver while ramping to the fan stage in m3/h.
+func (p *PaulNovus) currentAirflow() int {
+	return int(math.Round(p.fans.Power() * float64(p.model) / 100))
+}
+
+// updateBypass evaluates the summer bypass: 0 = automatic, 1 = always closed, 2 = always open.
+func (p *PaulNovus) updateBypass() {
+	switch p.bypassMode {
+	case 1:
+		p.bypassOpen = false
+	case 2:
+		p.bypassOpen = true
+	default:
+		p.bypassOpen = p.outdoorTemperature >= p.bypassMinOutdoor && p.outdoorTemperature < p.extractTemperature
+	}
+}
+
+// Advance follows the automatic bypass, ramps the fans and moves the temperatures, the fan stages 1-4
+// run at a quarter of the power each.
+func (p *PaulNovus) Advance(now time.Time) {
+	p.updateBypass()
+	p.fans.Advance(now, float64(p.fanStage*25))
+	p.thermal.Advance(now, p.fans.Power(), p.bypassOpen, p.outdoorTemperature, p.extractTemperature, &p.supplyTemperature, &p.exhaustTemperature)
+}
+
+// State implements HRULogic.
+func (p *PaulNovus) State() DeviceState {
+	return DeviceState{
+		"model":              &p.model,
+		"fanStage":           &p.fanStage,
+		"bypassMode":         &p.bypassMode,
+		"bypassOpen":         &p.bypassOpen,
+		"bypassMinOutdoor":   &p.bypassMinOutdoor,
+		"outdoorTemperature": &p.outdoorTemperature,
+		"supplyTemperature":  &p.supplyTemperature,
+		"extractTemperature": &p.extractTemperature,
+		"exhaustTemperature": &p.exhaustTemperature,
+		"fans":               &p.fans,
+	}
+}
+
+func (p *PaulNovus) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Paul", ProductCode: fmt.Sprintf("NOVUS %d", p.model), Revision: "4.06"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 100 {
+			return []uint16{uint16(p.fanStage)}, &Success
+		}
+		if register == 101 {
+			return []uint16{uint16(p.bypassMode)}, &Success
+		}
+		if register == 102 {
+			return []uint16{uint16(math.Round(p.bypassMinOutdoor * 10))}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 200 {
+			return []uint16{uint16(int16(math.Round(p.outdoorTemperature * 10)))}, &Success
+		}
+		if register == 201 {
+			return []uint16{uint16(int16(math.Round(p.supplyTemperature * 10)))}, &Success
+		}
+		if register == 202 {
+			return []uint16{uint16(int16(math.Round(p.extractTemperature * 10)))}, &Success
+		}
+		if register == 203 {
+			return []uint16{uint16(int16(math.Round(p.exhaustTemperature * 10)))}, &Success
+		}
+		if register == 210 {
+			if p.bypassOpen {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		if register == 211 {
+			return []uint16{uint16(p.currentAirflow())}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 100 {
+			if value > 4 {
+				return &IllegalDataValue
+			}
+			p.fanStage = int(value)
+			log.Printf(">>> CHANGE: fanStage=%d, airflow=%d\n", p.fanStage, p.airflow())
+			return &Success
+		}
+		if register == 101 {
+			if value > 2 {
+				return &IllegalDataValue
+			}
+			p.bypassMode = int(value)
+			p.updateBypass()
+			log.Printf(">>> CHANGE: bypassMode=%d, bypassOpen=%v\n", p.bypassMode, p.bypassOpen)
+			return &Success
+		}
+		if register == 102 {
+			p.bypassMinOutdoor = float64(int16(value)) / 10.0
+			p.updateBypass()
+			log.Printf(">>> CHANGE: bypassMinOutdoor=%.1f, bypassOpen=%v\n", p.bypassMinOutdoor, p.bypassOpen)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"log"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const (
+	preheaterCoilEnable     = 0
+	preheaterCoilAirflow    = 1
+	preheaterCoilResetAlarm = 2
+
+	preheaterInputOverheat = 0
+	preheaterInputRunning  = 1
+)
+
+// Preheater simulates a duct preheater controller. The airflow coil stands in for the controller's
+// airflow switch: heating without airflow trips the latching overheat alarm, which cuts the heater
+// until it is reset.
+type Preheater struct {
+	enabled      bool
+	power        int
+	airflow      bool
+	overheat     bool
+	nominalWatts int
+}
+
+func NewPreheater() *Preheater {
+	return &Preheater{
+		enabled:      false,
+		power:        0,
+		airflow:      true,
+		nominalWatts: 1200,
+	}
+}
+
+func (p *Preheater) running() bool {
+	return p.enabled && !p.overheat && p.power > 0
+}
+
+func (p *Preheater) update() {
+	if p.running() && !p.airflow {
+		p.overheat = true
+		log.Printf(">>> CHANGE: overheat=%v\n", p.overheat)
+	}
+}
+
+func (p *Preheater) watts() int {
+	if !p.running() {
+		return 0
+	}
+	return p.nominalWatts * p.power / 100
+}
+
+// State implements HRULogic.
+func (p *Preheater) State() DeviceState {
+	return DeviceState{
+		"enabled":      &p.enabled,
+		"power":        &p.power,
+		"airflow":      &p.airflow,
+		"overheat":     &p.overheat,
+		"nominalWatts": &p.nominalWatts,
+	}
+}
+
+func (p *Preheater) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Luftuj", ProductCode: "Electric preheater", Revision: "1.0.0"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(p.power)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(p.watts())}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 0 {
+			if value > 100 {
+				return &IllegalDataValue
+			}
+			p.power = int(value)
+			p.update()
+			log.Printf(">>> CHANGE: power=%d\n", p.power)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnReadCoils(serv, func(address uint16, numCoils int) ([]bool, *Exception) {
+		if address == preheaterCoilEnable && numCoils == 1 {
+			return []bool{p.enabled}, &Success
+		}
+		if address == preheaterCoilAirflow && numCoils == 1 {
+			return []bool{p.airflow}, &Success
+		}
+		if address == preheaterCoilResetAlarm && numCoils == 1 {
+			return []bool{false}, &Success
+		}
+		return []bool{}, &IllegalDataAddress
+	})
+	OnWriteCoil(serv, func(address uint16, value bool) *Exception {
+		if address == preheaterCoilEnable {
+			p.enabled = value
+			p.update()
+			log.Printf(">>> CHANGE: enabled=%v\n", p.enabled)
+			return &Success
+		}
+		if address == preheaterCoilAirflow {
+			p.airflow = value
+			p.update()
+			log.Printf(">>> CHANGE: airflow=%v\n", p.airflow)
+			return &Success
+		}
+		if address == preheaterCoilResetAlarm {
+			if value && p.airflow {
+				p.overheat = false
+				log.Printf(">>> CHANGE: overheat=%v\n", p.overheat)
+			}
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnReadDiscreteInputs(serv, func(address uint16, numInputs int) ([]bool, *Exception) {
+		if address == preheaterInputOverheat && numInputs == 1 {
+			return []bool{p.overheat}, &Success
+		}
+		if address == preheaterInputRunning && numInputs == 1 {
+			return []bool{p.running()}, &Success
+		}
+		if address == preheaterInputOverheat && numInputs == 2 {
+			return []bool{p.overheat, p.running()}, &Success
+		}
+		return []bool{}, &IllegalDataAddress
+	})
+}
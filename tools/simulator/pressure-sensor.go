@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log"
+	"math"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const pressureSensorCoilZero = 0
+
+// PressureSensor simulates a differential pressure transmitter as used across filters. Triggering
+// the zeroing coil stores the current reading as offset, exactly like pressing the zero button
+// with both ports open.
+type PressureSensor struct {
+	pressure float64
+	offset   float64
+	zeroings int
+}
+
+func NewPressureSensor() *PressureSensor {
+	return &PressureSensor{
+		pressure: 85,
+		offset:   1.5,
+	}
+}
+
+func (p *PressureSensor) reading() float64 {
+	return p.pressure + p.offset
+}
+
+// State implements HRULogic.
+func (p *PressureSensor) State() DeviceState {
+	return DeviceState{
+		"pressure": &p.pressure,
+		"offset":   &p.offset,
+		"zeroings": &p.zeroings,
+	}
+}
+
+func (p *PressureSensor) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Luftuj", ProductCode: "Pressure sensor", Revision: "1.0.0"})
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(int16(math.Round(p.reading() * 10)))}, &Success
+		}
+		if register == 1 {
+			return []uint16{uint16(p.zeroings)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	// The holding register sets the simulated pressure in 0.1 Pa.
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 100 {
+			return []uint16{uint16(int16(math.Round(p.pressure * 10)))}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 100 {
+			p.pressure = float64(int16(value)) / 10.0
+			log.Printf(">>> CHANGE: pressure=%.1f, reading=%.1f\n", p.pressure, p.reading())
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnReadCoils(serv, func(address uint16, numCoils int) ([]bool, *Exception) {
+		if address == pressureSensorCoilZero && numCoils == 1 {
+			return []bool{false}, &Success
+		}
+		return []bool{}, &IllegalDataAddress
+	})
+	OnWriteCoil(serv, func(address uint16, value bool) *Exception {
+		if address == pressureSensorCoilZero {
+			if value {
+				p.offset = -p.pressure
+				p.zeroings++
+				log.Printf(">>> CHANGE: zeroed, offset=%.1f\n", p.offset)
+			}
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+}
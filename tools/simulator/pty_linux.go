@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openPTY creates a pseudo terminal and returns its master side together with the path of the slave
+// device that clients open like a serial port.
+func openPTY() (*os.File, string, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	unlock := 0
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+		master.Close()
+		return nil, "", fmt.Errorf("failed to unlock pty: %w", errno)
+	}
+	var number uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&number))); errno != 0 {
+		master.Close()
+		return nil, "", fmt.Errorf("failed to get pty number: %w", errno)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", number), nil
+}
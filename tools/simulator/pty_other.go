@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func openPTY() (*os.File, string, error) {
+	return nil, "", errors.New("pty mode is only supported on Linux")
+}
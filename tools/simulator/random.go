@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// random is the source of all randomized behavior, sensor noise and fault probabilities, so a run
+// can be reproduced with --seed. It is safe for concurrent use.
+var random = rand.New(&lockedSource{source: rand.NewSource(time.Now().UnixNano()).(rand.Source64)})
+
+type lockedSource struct {
+	lock   sync.Mutex
+	source rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.source.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.source.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.source.Seed(seed)
+}
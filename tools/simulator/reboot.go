@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// defaultRebootDowntime is how long a rebooted device is down unless told otherwise, about the boot
+// time of the Modbus TCP interfaces of the units.
+const defaultRebootDowntime = 10 * time.Second
+
+// Reboot power cycles the device of the unit ID: the connections of its bus are closed, new ones are
+// refused and the device doesn't answer for the downtime, then it comes back built anew from its
+// definition, with the default register values. Other devices on the bus keep their state, but are
+// unreachable over TCP meanwhile, like behind a power cycled gateway. It returns right away.
+func (a *API) Reboot(unitID uint8, downtime time.Duration) error {
+	if downtime < 0 {
+		return fmt.Errorf("invalid downtime %s", downtime)
+	}
+	device, ok := a.lookup(unitID)
+	if !ok {
+		return fmt.Errorf("no device with unit ID %d", unitID)
+	}
+	simulation.Lock()
+	if a.bus.rebooting[unitID] {
+		simulation.Unlock()
+		return fmt.Errorf("unit %d is rebooting already", unitID)
+	}
+	logic, serv, err := newDeviceServer(device.config, a)
+	if err != nil {
+		simulation.Unlock()
+		return err
+	}
+	a.bus.rebooting[unitID] = true
+	simulation.Unlock()
+	log.Printf(">>> REBOOT: unit %d down for %s\n", unitID, downtime)
+	a.bus.refuseConnections(downtime)
+
+	time.AfterFunc(downtime, func() {
+		simulation.Lock()
+		a.bus.Remove(unitID)
+		err := a.bus.Add(unitID, serv)
+		delete(a.bus.rebooting, unitID)
+		if err != nil {
+			releaseServer(serv)
+			simulation.Unlock()
+			log.Printf("Rebooting unit %d failed: %v\n", unitID, err)
+			return
+		}
+		// the state from before the reboot stays the last published one, so subscribers get the reset
+		a.devicesLock.Lock()
+		a.devices[unitID] = apiDevice{unitID: unitID, deviceType: device.deviceType, config: device.config, logic: logic}
+		a.devicesLock.Unlock()
+		a.publishChanges(unitID)
+		simulation.Unlock()
+		log.Printf(">>> REBOOT: unit %d is back\n", unitID)
+	})
+	return nil
+}
+
+func (a *API) rebootDevice(w http.ResponseWriter, r *http.Request) {
+	device, ok := a.device(w, r)
+	if !ok {
+		return
+	}
+	options := struct {
+		Downtime Duration `json:"downtime"`
+	}{Downtime: Duration(defaultRebootDowntime)}
+	if err := json.NewDecoder(r.Body).Decode(&options); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid reboot: %w", err))
+		return
+	}
+	if options.Downtime < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid downtime %s", time.Duration(options.Downtime)))
+		return
+	}
+	if err := a.Reboot(device.unitID, time.Duration(options.Downtime)); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, options)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// Access is what a client may do with a register. Reads of write-only and writes to read-only
+// registers are answered with IllegalDataAddress, like real devices do.
+type Access int
+
+const (
+	ReadWrite Access = iota
+	ReadOnly
+	WriteOnly
+)
+
+// Register describes one register of a RegisterMap. Values are passed to Get and Set in
+// engineering units, the register holds them multiplied by Scale.
+type Register struct {
+	// Get returns the current value, nil when the register cannot be read.
+	Get func() float64
+	// Set applies a written value, nil when the register cannot be written.
+	Set func(value float64) *Exception
+	// Scale converts the value to the register, e.g. 10 for a resolution of 0.1. Zero means 1.
+	Scale float64
+	// Signed stores the value as int16 instead of uint16.
+	Signed bool
+	// Min and Max bound the written values, both zero leave them unbounded.
+	Min, Max float64
+	// Access restricts the register further than leaving Get or Set nil.
+	Access Access
+}
+
+// ReadOnly returns a read-only copy of the register, e.g. to expose a value at a second address.
+func (r *Register) ReadOnly() *Register {
+	register := *r
+	register.Access = ReadOnly
+	return &register
+}
+
+func (r *Register) readable() bool {
+	return r.Get != nil && r.Access != WriteOnly
+}
+
+func (r *Register) writable() bool {
+	return r.Set != nil && r.Access != ReadOnly
+}
+
+func (r *Register) scale() float64 {
+	if r.Scale == 0 {
+		return 1
+	}
+	return r.Scale
+}
+
+func (r *Register) encode() uint16 {
+	value := math.Round(r.Get() * r.scale())
+	if r.Signed {
+		return uint16(int16(value))
+	}
+	return uint16(value)
+}
+
+func (r *Register) decode(register uint16) float64 {
+	if r.Signed {
+		return float64(int16(register)) / r.scale()
+	}
+	return float64(register) / r.scale()
+}
+
+// RegisterMap declares the registers of a device by address, its methods match the OnRead* and
+// OnWrite* helpers:
+//
+//	OnReadInputRegisters(serv, inputRegisters.Read)
+//	OnWriteHoldingRegister(serv, holdingRegisters.WriteSingle)
+type RegisterMap map[uint16]*Register
+
+// Read returns numRegs consecutive registers, all of them have to be defined and readable.
+func (m RegisterMap) Read(address uint16, numRegs int) ([]uint16, *Exception) {
+	values := make([]uint16, numRegs)
+	for i := range values {
+		register, ok := m[address+uint16(i)]
+		if !ok || !register.readable() {
+			return []uint16{}, &IllegalDataAddress
+		}
+		values[i] = register.encode()
+	}
+	return values, &Success
+}
+
+// Write writes consecutive registers, all of them have to be writable and in range.
+func (m RegisterMap) Write(address uint16, values []uint16) *Exception {
+	registers := make([]*Register, len(values))
+	for i, value := range values {
+		register, ok := m[address+uint16(i)]
+		if !ok || !register.writable() {
+			return &IllegalDataAddress
+		}
+		if decoded := register.decode(value); (register.Min != 0 || register.Max != 0) && (decoded < register.Min || decoded > register.Max) {
+			return &IllegalDataValue
+		}
+		registers[i] = register
+	}
+	for i, register := range registers {
+		if err := register.Set(register.decode(values[i])); err != &Success {
+			return err
+		}
+	}
+	return &Success
+}
+
+// WriteSingle writes one register.
+func (m RegisterMap) WriteSingle(address uint16, value uint16) *Exception {
+	return m.Write(address, []uint16{value})
+}
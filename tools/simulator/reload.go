@@ -0,0 +1,145 @@
+package main
+
+import (
+	"log"
+	"os"
+	"reflect"
+	"time"
+)
+
+// Reloader applies changes of the config, scenario and device files to the running simulator, for
+// iterating on device definitions without restarting it (--watch). Devices whose definition or file
+// changed are rebuilt and swapped on the bus, so client connections stay open, and start over from
+// the new definition. A changed scenario starts over as well. Listener changes need a restart.
+type Reloader struct {
+	bus          *Bus
+	api          *API
+	config       *Config
+	configPath   string
+	scenarioPath string
+	stopScenario func()
+	modified     map[string]time.Time
+}
+
+// Run polls the files for changes every interval, it does not return.
+func (r *Reloader) Run(interval time.Duration) {
+	r.modified = map[string]time.Time{}
+	r.changed()
+	for range time.Tick(interval) {
+		if changed := r.changed(); len(changed) > 0 {
+			r.reload(changed)
+		}
+	}
+}
+
+func (r *Reloader) files() []string {
+	var files []string
+	for _, path := range []string{r.configPath, r.scenarioPath} {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+	for _, device := range r.config.Devices {
+		if device.File != "" {
+			files = append(files, device.File)
+		}
+	}
+	return files
+}
+
+// changed returns the files modified since the last call, files seen for the first time are not.
+func (r *Reloader) changed() map[string]bool {
+	changed := map[string]bool{}
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if modified, ok := r.modified[path]; ok && !modified.Equal(info.ModTime()) {
+			changed[path] = true
+		}
+		r.modified[path] = info.ModTime()
+	}
+	return changed
+}
+
+func (r *Reloader) reload(changed map[string]bool) {
+	config := r.config
+	if changed[r.configPath] {
+		loaded, err := LoadConfig(r.configPath)
+		if err != nil {
+			log.Printf("Reloading %s failed, keeping the running config: %v\n", r.configPath, err)
+		} else {
+			if !reflect.DeepEqual(loaded.Listeners, config.Listeners) || loaded.Broadcast != config.Broadcast {
+				log.Printf("The listeners and broadcast mode of %s are applied on restart only\n", r.configPath)
+			}
+			config = loaded
+		}
+	}
+	r.reloadDevices(config.Devices, changed)
+
+	if changed[r.scenarioPath] {
+		scenario, err := LoadScenario(r.scenarioPath)
+		if err != nil {
+			log.Printf("Reloading %s failed, keeping the running scenario: %v\n", r.scenarioPath, err)
+			return
+		}
+		stop, err := StartScenario(r.api, scenario)
+		if err != nil {
+			log.Printf("Reloading %s failed, keeping the running scenario: %v\n", r.scenarioPath, err)
+			return
+		}
+		if r.stopScenario != nil {
+			r.stopScenario()
+		}
+		r.stopScenario = stop
+		log.Printf(">>> RELOAD: scenario %s started over\n", r.scenarioPath)
+	}
+}
+
+// reloadDevices rebuilds the devices whose definition or file changed, adds new ones and removes
+// the ones that are gone. A device that fails to build keeps running as it was.
+func (r *Reloader) reloadDevices(devices []DeviceConfig, changed map[string]bool) {
+	running := map[uint8]DeviceConfig{}
+	for _, device := range r.config.Devices {
+		running[device.Unit] = device
+	}
+	applied := make([]DeviceConfig, 0, len(devices))
+	for _, device := range devices {
+		previous, ok := running[device.Unit]
+		delete(running, device.Unit)
+		if ok && reflect.DeepEqual(previous, device) && !changed[device.File] {
+			applied = append(applied, device)
+			continue
+		}
+		// building the server registers it in the tables the bus reads while serving the others
+		simulation.Lock()
+		logic, serv, err := newDeviceServer(device, r.api)
+		if err != nil {
+			simulation.Unlock()
+			log.Printf("Reloading unit %d failed: %v\n", device.Unit, err)
+			if ok {
+				applied = append(applied, previous)
+			}
+			continue
+		}
+		r.bus.Remove(device.Unit)
+		err = r.bus.Add(device.Unit, serv)
+		simulation.Unlock()
+		if err != nil {
+			log.Printf("Reloading unit %d failed: %v\n", device.Unit, err)
+			continue
+		}
+		r.api.Add(device, logic)
+		applied = append(applied, device)
+		log.Printf(">>> RELOAD: unit %d is %s\n", device.Unit, device.Type)
+	}
+	for unit := range running {
+		simulation.Lock()
+		r.bus.Remove(unit)
+		simulation.Unlock()
+		r.api.Remove(unit)
+		log.Printf(">>> RELOAD: unit %d removed\n", unit)
+	}
+	r.config = &Config{Broadcast: r.config.Broadcast, Listeners: r.config.Listeners, Devices: applied}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"log"
+	"math"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// RensonEndura simulates an Endura Delta. In automatic mode (level 0) the unit picks its level
+// from the CO2 and humidity sensors, breeze uses the bypass to cool the house at full speed when
+// it is warm inside and cooler outside.
+type RensonEndura struct {
+	level              int
+	breezeEnabled      bool
+	breezeTemperature  float64
+	co2Threshold       int
+	humidityThreshold  int
+	co2                int
+	humidity           int
+	indoorTemperature  float64
+	outdoorTemperature float64
+}
+
+func NewRensonEndura() *RensonEndura {
+	return &RensonEndura{
+		level:              0,
+		breezeEnabled:      true,
+		breezeTemperature:  24,
+		co2Threshold:       950,
+		humidityThreshold:  70,
+		co2:                720,
+		humidity:           48,
+		indoorTemperature:  23.2,
+		outdoorTemperature: 17.5,
+	}
+}
+
+func (r *RensonEndura) breezeActive() bool {
+	return r.breezeEnabled && r.indoorTemperature >= r.breezeTemperature && r.outdoorTemperature < r.indoorTemperature
+}
+
+// currentLevel returns the ventilation level the unit is actually running at (1-4).
+func (r *RensonEndura) currentLevel() int {
+	if r.breezeActive() {
+		return 4
+	}
+	if r.level != 0 {
+		return r.level
+	}
+	if r.co2 >= r.co2Threshold || r.humidity >= r.humidityThreshold {
+		return 3
+	}
+	return 2
+}
+
+// State implements HRULogic.
+func (r *RensonEndura) State() DeviceState {
+	return DeviceState{
+		"level":              &r.level,
+		"breezeEnabled":      &r.breezeEnabled,
+		"breezeTemperature":  &r.breezeTemperature,
+		"co2Threshold":       &r.co2Threshold,
+		"humidityThreshold":  &r.humidityThreshold,
+		"co2":                &r.co2,
+		"humidity":           &r.humidity,
+		"indoorTemperature":  &r.indoorTemperature,
+		"outdoorTemperature": &r.outdoorTemperature,
+	}
+}
+
+func (r *RensonEndura) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Renson", ProductCode: "Endura Delta", Revision: "1.8.4"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 100 {
+			return []uint16{uint16(r.level)}, &Success
+		}
+		if register == 101 {
+			if r.breezeEnabled {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		if register == 102 {
+			return []uint16{uint16(math.Round(r.breezeTemperature * 10))}, &Success
+		}
+		if register == 103 {
+			return []uint16{uint16(r.co2Threshold)}, &Success
+		}
+		if register == 104 {
+			return []uint16{uint16(r.humidityThreshold)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 200 {
+			return []uint16{uint16(r.currentLevel())}, &Success
+		}
+		if register == 201 {
+			if r.breezeActive() {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		if register == 202 {
+			return []uint16{uint16(r.co2)}, &Success
+		}
+		if register == 203 {
+			return []uint16{uint16(r.humidity)}, &Success
+		}
+		if register == 204 {
+			return []uint16{uint16(int16(math.Round(r.indoorTemperature * 10)))}, &Success
+		}
+		if register == 205 {
+			return []uint16{uint16(int16(math.Round(r.outdoorTemperature * 10)))}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 100 {
+			if value > 4 {
+				return &IllegalDataValue
+			}
+			r.level = int(value)
+			log.Printf(">>> CHANGE: level=%d, currentLevel=%d\n", r.level, r.currentLevel())
+			return &Success
+		}
+		if register == 101 {
+			r.breezeEnabled = value != 0
+			log.Printf(">>> CHANGE: breezeEnabled=%v\n", r.breezeEnabled)
+			return &Success
+		}
+		if register == 102 {
+			r.breezeTemperature = float64(value) / 10.0
+			log.Printf(">>> CHANGE: breezeTemperature=%.1f\n", r.breezeTemperature)
+			return &Success
+		}
+		if register == 103 {
+			if value < 400 || value > 2000 {
+				return &IllegalDataValue
+			}
+			r.co2Threshold = int(value)
+			log.Printf(">>> CHANGE: co2Threshold=%d\n", r.co2Threshold)
+			return &Success
+		}
+		if register == 104 {
+			if value > 100 {
+				return &IllegalDataValue
+			}
+			r.humidityThreshold = int(value)
+			log.Printf(">>> CHANGE: humidityThreshold=%d\n", r.humidityThreshold)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+}
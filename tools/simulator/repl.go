@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const replHelp = `commands, the unit ID defaults to the device with the lowest one:
+  devices                           list the devices
+  get [unit] [field]                show the state or one field of it
+  set [unit] <field> <value>        change a state field
+  trigger [unit] <field> [value]    raise an alarm: set a boolean field or a code to the value
+  trigger [unit] alarm <code>       raise a vendor alarm code, e.g. E3
+  clear [unit] <field>              clear an alarm: reset the field to false or 0
+  clear [unit] alarm <code>         clear a vendor alarm code
+  reboot [unit] [downtime]          power cycle the device, down for 10s by default
+  pause                             freeze timers, physics and scenarios
+  resume                            let the simulated time run again
+  help                              show this help`
+
+// RunREPL reads commands from in and answers them on out, so the device state can be driven from a
+// terminal or a piped script without the HTTP API. It returns at the end of the input.
+func RunREPL(api *API, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if err := replCommand(api, fields[0], fields[1:], out); err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+		}
+	}
+	return scanner.Err()
+}
+
+func replCommand(api *API, command string, args []string, out io.Writer) error {
+	switch command {
+	case "help":
+		fmt.Fprintln(out, replHelp)
+		return nil
+	case "devices":
+		for _, unit := range api.units() {
+			if device, ok := api.lookup(unit); ok {
+				fmt.Fprintf(out, "%d %s\n", unit, device.deviceType)
+			}
+		}
+		return nil
+	case "pause", "resume":
+		clock.SetPaused(command == "pause")
+		fmt.Fprintf(out, "paused %v\n", clock.Paused())
+		return nil
+	}
+
+	device, args, err := replDevice(api, args)
+	if err != nil {
+		return err
+	}
+	simulation.Lock()
+	state := deviceState(device.logic)
+	simulation.Unlock()
+
+	switch command {
+	case "get":
+		if len(args) == 0 {
+			names := make([]string, 0, len(state))
+			for name := range state {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(out, "%s %v\n", name, state[name])
+			}
+			return nil
+		}
+		value, ok := state[args[0]]
+		if !ok {
+			return fmt.Errorf("unknown state field %q", args[0])
+		}
+		fmt.Fprintln(out, value)
+		return nil
+	case "set":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: set [unit] <field> <value>")
+		}
+		return replSet(api, device, args[0], stateValue(state[args[0]], strings.Join(args[1:], " ")), out)
+	case "trigger":
+		if replAlarmCode(state, args) {
+			return replAlarm(api, device, args[1], true, out)
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("usage: trigger [unit] <field> [value]")
+		}
+		if _, ok := state[args[0]].(bool); ok && len(args) == 1 {
+			return replSet(api, device, args[0], json.RawMessage("true"), out)
+		}
+		if len(args) != 2 {
+			return fmt.Errorf("trigger %s needs a value", args[0])
+		}
+		return replSet(api, device, args[0], stateValue(state[args[0]], args[1]), out)
+	case "clear":
+		if replAlarmCode(state, args) {
+			return replAlarm(api, device, args[1], false, out)
+		}
+		if len(args) != 1 {
+			return fmt.Errorf("usage: clear [unit] <field>")
+		}
+		if _, ok := state[args[0]].(bool); ok {
+			return replSet(api, device, args[0], json.RawMessage("false"), out)
+		}
+		return replSet(api, device, args[0], stateValue(state[args[0]], "0"), out)
+	case "reboot":
+		if len(args) > 1 {
+			return fmt.Errorf("usage: reboot [unit] [downtime]")
+		}
+		downtime := defaultRebootDowntime
+		if len(args) == 1 {
+			if downtime, err = time.ParseDuration(args[0]); err != nil {
+				return fmt.Errorf("invalid downtime %q, expected e.g. 30s", args[0])
+			}
+		}
+		if err := api.Reboot(device.unitID, downtime); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "unit %d down for %s\n", device.unitID, downtime)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q, try help", command)
+}
+
+// replDevice takes the device from the arguments if they start with a unit ID.
+func replDevice(api *API, args []string) (apiDevice, []string, error) {
+	units := api.units()
+	if len(units) == 0 {
+		return apiDevice{}, nil, fmt.Errorf("no devices")
+	}
+	if len(args) > 0 {
+		if unit, err := strconv.ParseUint(args[0], 10, 8); err == nil {
+			device, ok := api.lookup(uint8(unit))
+			if !ok {
+				return apiDevice{}, nil, fmt.Errorf("no device with unit ID %d", unit)
+			}
+			return device, args[1:], nil
+		}
+	}
+	device, _ := api.lookup(units[0])
+	return device, args, nil
+}
+
+// replAlarmCode tells if the arguments are alarm and a vendor code, unless the device has a state
+// field named alarm.
+func replAlarmCode(state map[string]any, args []string) bool {
+	_, field := state["alarm"]
+	return len(args) == 2 && args[0] == "alarm" && !field
+}
+
+// replAlarm raises or clears the vendor alarm code and shows the active codes.
+func replAlarm(api *API, device apiDevice, code string, raise bool, out io.Writer) error {
+	if _, err := alarmed(device); err != nil {
+		return err
+	}
+	active, err := api.SetAlarm(device.unitID, code, raise)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "alarms %s\n", strings.Join(active, " "))
+	return nil
+}
+
+func replSet(api *API, device apiDevice, field string, value json.RawMessage, out io.Writer) error {
+	state, err := api.setState(device, map[string]json.RawMessage{field: value})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s %v\n", field, state[field])
+	return nil
+}
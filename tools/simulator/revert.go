@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// RegisterRevert makes a device silently restore the holding registers in the range some time after
+// they were written, like firmwares reverting setpoints after an internal cycle, so the write
+// verification of clients can be tested. It is written as first[-last]:after, e.g. 12001:30s.
+type RegisterRevert struct {
+	Registers [2]uint16
+	After     time.Duration
+}
+
+func ParseRegisterRevert(value string) (RegisterRevert, error) {
+	var revert RegisterRevert
+	registers, after, ok := strings.Cut(value, ":")
+	if !ok {
+		return revert, fmt.Errorf("invalid revert '%s', expected first[-last]:after, e.g. 12001:30s", value)
+	}
+	var err error
+	if revert.Registers, err = parseRegisterRange(registers); err != nil {
+		return revert, err
+	}
+	if revert.After, err = time.ParseDuration(after); err != nil || revert.After <= 0 {
+		return revert, fmt.Errorf("invalid revert time '%s', expected a duration like 30s", after)
+	}
+	return revert, nil
+}
+
+// parseRegisterRange parses an address range written as first-last, or a single address.
+func parseRegisterRange(value string) ([2]uint16, error) {
+	first, last, isRange := strings.Cut(value, "-")
+	if !isRange {
+		last = first
+	}
+	from, err := strconv.ParseUint(first, 10, 16)
+	if err != nil {
+		return [2]uint16{}, fmt.Errorf("invalid address range '%s', expected first-last", value)
+	}
+	to, err := strconv.ParseUint(last, 10, 16)
+	if err != nil || to < from {
+		return [2]uint16{}, fmt.Errorf("invalid address range '%s', expected first-last", value)
+	}
+	return [2]uint16{uint16(from), uint16(to)}, nil
+}
+
+func (r RegisterRevert) String() string {
+	if r.Registers[0] == r.Registers[1] {
+		return fmt.Sprintf("%d:%s", r.Registers[0], r.After)
+	}
+	return fmt.Sprintf("%d-%d:%s", r.Registers[0], r.Registers[1], r.After)
+}
+
+func (r *RegisterRevert) UnmarshalText(text []byte) error {
+	parsed, err := ParseRegisterRevert(string(text))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+func (r RegisterRevert) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// pendingRevert is a register waiting to be restored to value at the simulated time due.
+type pendingRevert struct {
+	value uint16
+	due   time.Time
+}
+
+// RevertWrites restores the holding registers of the reverts to the value they had before a write,
+// after their time has passed on the simulated clock. Writing a register again before postpones its
+// revert, but keeps the value it reverts to. The registers are read and restored through the FC3
+// and FC6 (or FC16) handlers of the device, bypassing the middleware.
+func RevertWrites(reverts []RegisterRevert) Middleware {
+	pending := map[uint16]*pendingRevert{}
+	return func(next functionHandler) functionHandler {
+		return func(s *Server, request Framer) ([]byte, *Exception) {
+			function := request.GetFunction()
+			if function != FnWriteHoldingRegister && function != FnWriteHoldingRegisters {
+				return next(s, request)
+			}
+			span := requestAddresses(request)[0]
+			previous := map[uint16]uint16{}
+			for i := 0; i <= int(span[1]-span[0]); i++ {
+				register := span[0] + uint16(i)
+				if _, ok := revertAfter(reverts, register); !ok || pending[register] != nil {
+					continue
+				}
+				if value, ok := readHoldingRegister(s, register); ok {
+					previous[register] = value
+				}
+			}
+
+			data, exception := next(s, request)
+			if exception != &Success {
+				return data, exception
+			}
+			now := clock.Now()
+			for i := 0; i <= int(span[1]-span[0]); i++ {
+				register := span[0] + uint16(i)
+				after, ok := revertAfter(reverts, register)
+				if !ok {
+					continue
+				}
+				if revert := pending[register]; revert != nil {
+					revert.due = now.Add(after)
+					continue
+				}
+				value, ok := previous[register]
+				if !ok {
+					continue
+				}
+				revert := &pendingRevert{value: value, due: now.Add(after)}
+				pending[register] = revert
+				go func() {
+					simulation.Lock()
+					defer simulation.Unlock()
+					// the revert is postponed while the register is written again
+					for clock.Now().Before(revert.due) {
+						due := revert.due
+						simulation.Unlock()
+						clock.Wait(due, nil)
+						simulation.Lock()
+					}
+					delete(pending, register)
+					log.Printf("!!! FAULT: register %d reverted to %d\n", register, revert.value)
+					writeHoldingRegister(s, register, revert.value)
+				}()
+			}
+			return data, exception
+		}
+	}
+}
+
+// revertAfter returns the revert time of the register, false if it is not reverted.
+func revertAfter(reverts []RegisterRevert, register uint16) (time.Duration, bool) {
+	for _, revert := range reverts {
+		if register >= revert.Registers[0] && register <= revert.Registers[1] {
+			return revert.After, true
+		}
+	}
+	return 0, false
+}
+
+// readHoldingRegister reads the register through the FC3 handler of the server. The caller holds the
+// simulation lock.
+func readHoldingRegister(s *Server, register uint16) (uint16, bool) {
+	function := serverHandlers(s)[FnReadHoldingRegisters]
+	if function == nil {
+		return 0, false
+	}
+	request := &TCPFrame{Function: FnReadHoldingRegisters, Data: binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(nil, register), 1)}
+	data, exception := callHandler(function, s, request)
+	if exception != &Success || len(data) != 3 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(data[1:]), true
+}
+
+// writeHoldingRegister writes the register through the FC6 handler of the server, or the FC16
+// handler if the device has no working FC6. The caller holds the simulation lock.
+func writeHoldingRegister(s *Server, register uint16, value uint16) {
+	address := binary.BigEndian.AppendUint16(nil, register)
+	if function := serverHandlers(s)[FnWriteHoldingRegister]; function != nil {
+		request := &TCPFrame{Function: FnWriteHoldingRegister, Data: binary.BigEndian.AppendUint16(address, value)}
+		if _, exception := callHandler(function, s, request); exception == &Success {
+			return
+		}
+	}
+	if function := serverHandlers(s)[FnWriteHoldingRegisters]; function != nil {
+		request := &TCPFrame{Function: FnWriteHoldingRegisters, Data: binary.BigEndian.AppendUint16(append(address, 0, 1, 2), value)}
+		if _, exception := callHandler(function, s, request); exception == &Success {
+			return
+		}
+	}
+	log.Printf("Reverting register %d failed, the device rejected the write\n", register)
+}
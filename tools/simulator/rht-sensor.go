@@ -0,0 +1,116 @@
+package main
+
+import (
+	"log"
+	"math"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// RHTSensor simulates a humidity/temperature transmitter. Every read adds gaussian noise with the
+// configured standard deviation on top of the base values, as real capacitive sensors jitter.
+// While the simulation is paused, reads repeat the noise of the last one.
+type RHTSensor struct {
+	humidity         float64
+	temperature      float64
+	humidityNoise    float64
+	temperatureNoise float64
+	noise            rhtNoise
+}
+
+// rhtNoise is the noise of the last read, a struct to keep it out of the API state.
+type rhtNoise struct {
+	humidity    float64
+	temperature float64
+}
+
+func NewRHTSensor(humidityNoise float64, temperatureNoise float64) *RHTSensor {
+	return &RHTSensor{
+		humidity:         55,
+		temperature:      22.5,
+		humidityNoise:    humidityNoise,
+		temperatureNoise: temperatureNoise,
+	}
+}
+
+func (r *RHTSensor) measuredHumidity() float64 {
+	if !clock.Paused() {
+		r.noise.humidity = random.NormFloat64() * r.humidityNoise
+	}
+	return math.Min(math.Max(r.humidity+r.noise.humidity, 0), 100)
+}
+
+func (r *RHTSensor) measuredTemperature() float64 {
+	if !clock.Paused() {
+		r.noise.temperature = random.NormFloat64() * r.temperatureNoise
+	}
+	return r.temperature + r.noise.temperature
+}
+
+// State implements HRULogic.
+func (r *RHTSensor) State() DeviceState {
+	return DeviceState{
+		"humidity":         &r.humidity,
+		"temperature":      &r.temperature,
+		"humidityNoise":    &r.humidityNoise,
+		"temperatureNoise": &r.temperatureNoise,
+	}
+}
+
+func (r *RHTSensor) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Luftuj", ProductCode: "RH/T sensor", Revision: "1.0.0"})
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(math.Round(r.measuredHumidity() * 10))}, &Success
+		}
+		if register == 1 {
+			return []uint16{uint16(int16(math.Round(r.measuredTemperature() * 10)))}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	// The holding registers set the simulated base values and noise, so tests can raise the humidity.
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 100 {
+			return []uint16{uint16(math.Round(r.humidity * 10))}, &Success
+		}
+		if register == 101 {
+			return []uint16{uint16(int16(math.Round(r.temperature * 10)))}, &Success
+		}
+		if register == 102 {
+			return []uint16{uint16(math.Round(r.humidityNoise * 10))}, &Success
+		}
+		if register == 103 {
+			return []uint16{uint16(math.Round(r.temperatureNoise * 10))}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 100 {
+			if value > 1000 {
+				return &IllegalDataValue
+			}
+			r.humidity = float64(value) / 10.0
+			log.Printf(">>> CHANGE: humidity=%.1f\n", r.humidity)
+			return &Success
+		}
+		if register == 101 {
+			r.temperature = float64(int16(value)) / 10.0
+			log.Printf(">>> CHANGE: temperature=%.1f\n", r.temperature)
+			return &Success
+		}
+		if register == 102 {
+			r.humidityNoise = float64(value) / 10.0
+			log.Printf(">>> CHANGE: humidityNoise=%.1f\n", r.humidityNoise)
+			return &Success
+		}
+		if register == 103 {
+			r.temperatureNoise = float64(value) / 10.0
+			log.Printf(">>> CHANGE: temperatureNoise=%.1f\n", r.temperatureNoise)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/goburrow/serial"
+	. "github.com/tbrandon/mbserver"
+)
+
+// rtuMaxFrameLength is the maximum size of a Modbus RTU frame (address + PDU + CRC).
+const rtuMaxFrameLength = 256
+
+// rtuRequestLength returns the length of the RTU request starting at the beginning of packet. known
+// is false for function codes whose length cannot be derived from the header, length is 0 when more
+// bytes are needed to tell.
+func rtuRequestLength(packet []byte) (length int, known bool) {
+	if len(packet) < 2 {
+		return 0, true
+	}
+	switch packet[1] {
+	case FnReadExceptionStatus:
+		return 4, true
+	case FnReadCoils, FnReadDiscreteInputs, FnReadHoldingRegisters, FnReadInputRegisters,
+		FnWriteSingleCoil, FnWriteHoldingRegister:
+		return 8, true
+	case FnDiagnostics:
+		if len(packet) < 4 {
+			return 0, true
+		}
+		if binary.BigEndian.Uint16(packet[2:4]) != diagnosticReturnQueryData {
+			return 8, true
+		}
+		// Return Query Data carries any number of data words, the frame ends at the first of them
+		// followed by a matching CRC
+		for length := 8; length <= len(packet) && length <= rtuMaxFrameLength; length += 2 {
+			if _, err := newRTUFrame(packet[:length]); err == nil {
+				return length, true
+			}
+		}
+		return 0, true
+	case FnWriteMultipleCoils, FnWriteHoldingRegisters:
+		if len(packet) < 7 {
+			return 0, true
+		}
+		return 9 + int(packet[6]), true
+	case FnReadFileRecord, FnWriteFileRecord:
+		if len(packet) < 3 {
+			return 0, true
+		}
+		return 5 + int(packet[2]), true
+	case FnMaskWriteRegister:
+		return 10, true
+	case FnReadFIFOQueue:
+		return 6, true
+	case FnEncapsulatedInterface:
+		return 7, true
+	case FnReadWriteMultipleRegisters:
+		if len(packet) < 11 {
+			return 0, true
+		}
+		return 13 + int(packet[10]), true
+	}
+	return 0, false
+}
+
+// newRTUFrame parses an RTU frame like NewRTUFrame, which rejects frames without any data such as
+// FC7 requests.
+func newRTUFrame(packet []byte) (*RTUFrame, error) {
+	if len(packet) != 4 {
+		return NewRTUFrame(packet)
+	}
+	frame := &RTUFrame{Address: packet[0], Function: packet[1], Data: []byte{}}
+	if expected := frame.Bytes(); !bytes.Equal(expected, packet) {
+		return nil, fmt.Errorf("RTU Frame error: CRC (expected 0x%x, got 0x%x)", binary.LittleEndian.Uint16(packet[2:]), binary.LittleEndian.Uint16(expected[2:]))
+	}
+	return frame, nil
+}
+
+// serveRTU reads RTU frames from port and answers them until the port is closed. Frames are
+// assembled from their expected length because serial reads may split or join them arbitrarily;
+// a read timeout counts as the inter-frame silence and discards incomplete data.
+func serveRTU(bus *Bus, port io.ReadWriter) error {
+	buffer := make([]byte, 0, 2*rtuMaxFrameLength)
+	chunk := make([]byte, rtuMaxFrameLength)
+	for {
+		n, err := port.Read(chunk)
+		if errors.Is(err, serial.ErrTimeout) {
+			if len(buffer) > 0 {
+				log.Printf("discarding incomplete serial frame %v\n", buffer)
+				buffer = buffer[:0]
+			}
+			continue
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		buffer = append(buffer, chunk[:n]...)
+
+		for len(buffer) > 0 {
+			length, known := rtuRequestLength(buffer)
+			if !known {
+				// without a length rule the buffered data is taken as a frame once its CRC matches
+				if _, err := newRTUFrame(buffer); err != nil {
+					break
+				}
+				length = len(buffer)
+			}
+			if length == 0 || len(buffer) < length {
+				break
+			}
+
+			packet := make([]byte, length)
+			copy(packet, buffer)
+			buffer = buffer[:copy(buffer, buffer[length:])]
+
+			frame, err := newRTUFrame(packet)
+			if err != nil {
+				log.Printf("bad serial frame error %v\n", err)
+				bus.countCommunicationError()
+				buffer = buffer[:0]
+				break
+			}
+			if response := bus.handle(frame); response != nil {
+				if bus.faults.dropConnection(port, frame) {
+					return nil
+				}
+				if err := bus.faults.write(port, bus.faults.encode(response)); err != nil {
+					return err
+				}
+			}
+		}
+		if len(buffer) > rtuMaxFrameLength {
+			log.Printf("discarding %d bytes of unframed serial data\n", len(buffer))
+			buffer = buffer[:0]
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a script of timed changes to the device state, loaded with --scenario, so tests can
+// reproduce dynamic device behavior deterministically.
+type Scenario struct {
+	Steps []ScenarioStep `json:"steps" yaml:"steps"`
+}
+
+// ScenarioStep changes the state of a device At the given time after the start. Unit defaults to
+// the device with the lowest unit ID. Set sets state fields, Trigger sets boolean fields like alarms
+// and Clear resets fields to false or 0, like the REPL commands of the same name. RaiseAlarms and
+// ClearAlarms raise and clear vendor error codes like E3. Reboot power cycles the device afterwards,
+// down for the given time.
+type ScenarioStep struct {
+	At      Duration       `json:"at" yaml:"at"`
+	Unit    *uint8         `json:"unit" yaml:"unit"`
+	Set     map[string]any `json:"set" yaml:"set"`
+	Trigger []string       `json:"trigger" yaml:"trigger"`
+	Clear   []string       `json:"clear" yaml:"clear"`
+	Reboot  *Duration      `json:"reboot" yaml:"reboot"`
+
+	RaiseAlarms []string `json:"raiseAlarms" yaml:"raiseAlarms"`
+	ClearAlarms []string `json:"clearAlarms" yaml:"clearAlarms"`
+}
+
+// LoadScenario reads a JSON or YAML scenario.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var scenario Scenario
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &scenario)
+	default:
+		err = json.Unmarshal(data, &scenario)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	sort.SliceStable(scenario.Steps, func(i, j int) bool {
+		return scenario.Steps[i].At < scenario.Steps[j].At
+	})
+	return &scenario, nil
+}
+
+// scenarioAction is a step resolved against the devices.
+type scenarioAction struct {
+	at      time.Duration
+	unit    uint8
+	changes map[string]json.RawMessage
+	// alarms are the alarm codes to raise or clear, in order
+	alarms []scenarioAlarm
+	// reboot is the downtime of a reboot, nil without one
+	reboot *time.Duration
+}
+
+type scenarioAlarm struct {
+	code  string
+	raise bool
+}
+
+// StartScenario checks the steps against the devices and runs them in the background, the time of
+// the steps counts from now in simulated time, so pausing the simulation pauses the scenario. stop
+// ends the scenario before its next step.
+func StartScenario(api *API, scenario *Scenario) (stop func(), err error) {
+	actions := make([]scenarioAction, 0, len(scenario.Steps))
+	for i, step := range scenario.Steps {
+		action, err := scenarioStepAction(api, step)
+		if err != nil {
+			return nil, fmt.Errorf("step %d (at %s): %w", i+1, time.Duration(step.At), err)
+		}
+		actions = append(actions, action)
+	}
+
+	start := clock.Now()
+	stopped := make(chan struct{})
+	go func() {
+		for _, action := range actions {
+			if !clock.Wait(start.Add(action.at), stopped) {
+				return
+			}
+			log.Printf(">>> SCENARIO: t=%s, unit=%d\n", action.at, action.unit)
+			// look the device up again, it may have been reloaded
+			device, ok := api.lookup(action.unit)
+			if !ok {
+				log.Printf("Scenario step at %s failed: no device with unit ID %d\n", action.at, action.unit)
+				continue
+			}
+			if len(action.changes) > 0 {
+				if _, err := api.setState(device, action.changes); err != nil {
+					log.Printf("Scenario step at %s failed: %v\n", action.at, err)
+				}
+			}
+			for _, alarm := range action.alarms {
+				if _, err := api.SetAlarm(action.unit, alarm.code, alarm.raise); err != nil {
+					log.Printf("Scenario step at %s failed: %v\n", action.at, err)
+				}
+			}
+			if action.reboot != nil {
+				if err := api.Reboot(action.unit, *action.reboot); err != nil {
+					log.Printf("Scenario step at %s failed: %v\n", action.at, err)
+				}
+			}
+		}
+		log.Printf(">>> SCENARIO: done\n")
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(stopped) }) }, nil
+}
+
+func scenarioStepAction(api *API, step ScenarioStep) (scenarioAction, error) {
+	action := scenarioAction{at: time.Duration(step.At), changes: map[string]json.RawMessage{}}
+	units := api.units()
+	if len(units) == 0 {
+		return action, fmt.Errorf("no devices")
+	}
+	action.unit = units[0]
+	if step.Unit != nil {
+		action.unit = *step.Unit
+	}
+	device, ok := api.lookup(action.unit)
+	if !ok {
+		return action, fmt.Errorf("no device with unit ID %d", action.unit)
+	}
+
+	simulation.Lock()
+	state := deviceState(device.logic)
+	simulation.Unlock()
+	for name, value := range step.Set {
+		if _, ok := state[name]; !ok {
+			return action, fmt.Errorf("unknown state field %q", name)
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return action, fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+		action.changes[name] = raw
+	}
+	for _, name := range step.Trigger {
+		if _, ok := state[name].(bool); !ok {
+			return action, fmt.Errorf("cannot trigger %q, it is not a boolean field, set it instead", name)
+		}
+		action.changes[name] = json.RawMessage("true")
+	}
+	for _, name := range step.Clear {
+		current, ok := state[name]
+		if !ok {
+			return action, fmt.Errorf("unknown state field %q", name)
+		}
+		if _, ok := current.(bool); ok {
+			action.changes[name] = json.RawMessage("false")
+		} else {
+			action.changes[name] = stateValue(current, "0")
+		}
+	}
+	if len(step.RaiseAlarms) > 0 || len(step.ClearAlarms) > 0 {
+		alarms, err := alarmed(device)
+		if err != nil {
+			return action, err
+		}
+		for _, code := range step.RaiseAlarms {
+			if _, _, err := parseAlarm(alarms, code); err != nil {
+				return action, err
+			}
+			action.alarms = append(action.alarms, scenarioAlarm{code: code, raise: true})
+		}
+		for _, code := range step.ClearAlarms {
+			if _, _, err := parseAlarm(alarms, code); err != nil {
+				return action, err
+			}
+			action.alarms = append(action.alarms, scenarioAlarm{code: code})
+		}
+	}
+	if step.Reboot != nil {
+		downtime := time.Duration(*step.Reboot)
+		if downtime < 0 {
+			return action, fmt.Errorf("invalid reboot downtime %s", downtime)
+		}
+		action.reboot = &downtime
+	}
+	if len(action.changes) == 0 && len(action.alarms) == 0 && action.reboot == nil {
+		return action, fmt.Errorf("nothing to change")
+	}
+	return action, nil
+}
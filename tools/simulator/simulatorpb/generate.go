@@ -0,0 +1,4 @@
+// Package simulatorpb holds the generated code of the gRPC control API.
+package simulatorpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative simulator.proto
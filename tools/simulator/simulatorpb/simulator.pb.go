@@ -0,0 +1,1431 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: simulator.proto
+
+package simulatorpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Device struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// unit is the unit ID, 0 is the device answering any unit ID.
+	Unit          uint32 `protobuf:"varint,1,opt,name=unit,proto3" json:"unit,omitempty"`
+	Type          string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Device) Reset() {
+	*x = Device{}
+	mi := &file_simulator_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Device) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Device) ProtoMessage() {}
+
+func (x *Device) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Device.ProtoReflect.Descriptor instead.
+func (*Device) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Device) GetUnit() uint32 {
+	if x != nil {
+		return x.Unit
+	}
+	return 0
+}
+
+func (x *Device) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+// Value is the value of a state field, integer fields are numbers as well.
+type Value struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*Value_BoolValue
+	//	*Value_NumberValue
+	//	*Value_StringValue
+	Kind          isValue_Kind `protobuf_oneof:"kind"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Value) Reset() {
+	*x = Value{}
+	mi := &file_simulator_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Value) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Value) ProtoMessage() {}
+
+func (x *Value) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Value.ProtoReflect.Descriptor instead.
+func (*Value) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Value) GetKind() isValue_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return nil
+}
+
+func (x *Value) GetBoolValue() bool {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_BoolValue); ok {
+			return x.BoolValue
+		}
+	}
+	return false
+}
+
+func (x *Value) GetNumberValue() float64 {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_NumberValue); ok {
+			return x.NumberValue
+		}
+	}
+	return 0
+}
+
+func (x *Value) GetStringValue() string {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_StringValue); ok {
+			return x.StringValue
+		}
+	}
+	return ""
+}
+
+type isValue_Kind interface {
+	isValue_Kind()
+}
+
+type Value_BoolValue struct {
+	BoolValue bool `protobuf:"varint,1,opt,name=bool_value,json=boolValue,proto3,oneof"`
+}
+
+type Value_NumberValue struct {
+	NumberValue float64 `protobuf:"fixed64,2,opt,name=number_value,json=numberValue,proto3,oneof"`
+}
+
+type Value_StringValue struct {
+	StringValue string `protobuf:"bytes,3,opt,name=string_value,json=stringValue,proto3,oneof"`
+}
+
+func (*Value_BoolValue) isValue_Kind() {}
+
+func (*Value_NumberValue) isValue_Kind() {}
+
+func (*Value_StringValue) isValue_Kind() {}
+
+type ListDevicesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDevicesRequest) Reset() {
+	*x = ListDevicesRequest{}
+	mi := &file_simulator_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDevicesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDevicesRequest) ProtoMessage() {}
+
+func (x *ListDevicesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDevicesRequest.ProtoReflect.Descriptor instead.
+func (*ListDevicesRequest) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{2}
+}
+
+type ListDevicesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Devices       []*Device              `protobuf:"bytes,1,rep,name=devices,proto3" json:"devices,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDevicesResponse) Reset() {
+	*x = ListDevicesResponse{}
+	mi := &file_simulator_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDevicesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDevicesResponse) ProtoMessage() {}
+
+func (x *ListDevicesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDevicesResponse.ProtoReflect.Descriptor instead.
+func (*ListDevicesResponse) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListDevicesResponse) GetDevices() []*Device {
+	if x != nil {
+		return x.Devices
+	}
+	return nil
+}
+
+type GetStateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Unit          uint32                 `protobuf:"varint,1,opt,name=unit,proto3" json:"unit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStateRequest) Reset() {
+	*x = GetStateRequest{}
+	mi := &file_simulator_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStateRequest) ProtoMessage() {}
+
+func (x *GetStateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStateRequest.ProtoReflect.Descriptor instead.
+func (*GetStateRequest) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetStateRequest) GetUnit() uint32 {
+	if x != nil {
+		return x.Unit
+	}
+	return 0
+}
+
+type DeviceState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Device        *Device                `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	Fields        map[string]*Value      `protobuf:"bytes,2,rep,name=fields,proto3" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeviceState) Reset() {
+	*x = DeviceState{}
+	mi := &file_simulator_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeviceState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceState) ProtoMessage() {}
+
+func (x *DeviceState) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceState.ProtoReflect.Descriptor instead.
+func (*DeviceState) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeviceState) GetDevice() *Device {
+	if x != nil {
+		return x.Device
+	}
+	return nil
+}
+
+func (x *DeviceState) GetFields() map[string]*Value {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+type SetStateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Unit          uint32                 `protobuf:"varint,1,opt,name=unit,proto3" json:"unit,omitempty"`
+	Fields        map[string]*Value      `protobuf:"bytes,2,rep,name=fields,proto3" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetStateRequest) Reset() {
+	*x = SetStateRequest{}
+	mi := &file_simulator_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetStateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetStateRequest) ProtoMessage() {}
+
+func (x *SetStateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetStateRequest.ProtoReflect.Descriptor instead.
+func (*SetStateRequest) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SetStateRequest) GetUnit() uint32 {
+	if x != nil {
+		return x.Unit
+	}
+	return 0
+}
+
+func (x *SetStateRequest) GetFields() map[string]*Value {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+type WatchStateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchStateRequest) Reset() {
+	*x = WatchStateRequest{}
+	mi := &file_simulator_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchStateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchStateRequest) ProtoMessage() {}
+
+func (x *WatchStateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchStateRequest.ProtoReflect.Descriptor instead.
+func (*WatchStateRequest) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{7}
+}
+
+type StateEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Device        *Device                `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	Field         string                 `protobuf:"bytes,2,opt,name=field,proto3" json:"field,omitempty"`
+	Value         *Value                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StateEvent) Reset() {
+	*x = StateEvent{}
+	mi := &file_simulator_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StateEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StateEvent) ProtoMessage() {}
+
+func (x *StateEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StateEvent.ProtoReflect.Descriptor instead.
+func (*StateEvent) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *StateEvent) GetDevice() *Device {
+	if x != nil {
+		return x.Device
+	}
+	return nil
+}
+
+func (x *StateEvent) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *StateEvent) GetValue() *Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *StateEvent) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+type ListTransactionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTransactionsRequest) Reset() {
+	*x = ListTransactionsRequest{}
+	mi := &file_simulator_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTransactionsRequest) ProtoMessage() {}
+
+func (x *ListTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*ListTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{9}
+}
+
+type Transaction struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Unit     uint32                 `protobuf:"varint,1,opt,name=unit,proto3" json:"unit,omitempty"`
+	Function uint32                 `protobuf:"varint,2,opt,name=function,proto3" json:"function,omitempty"`
+	// request and response are the PDU data as hex bytes, the response is empty for an exception.
+	Request       string                 `protobuf:"bytes,3,opt,name=request,proto3" json:"request,omitempty"`
+	Response      string                 `protobuf:"bytes,4,opt,name=response,proto3" json:"response,omitempty"`
+	Exception     string                 `protobuf:"bytes,5,opt,name=exception,proto3" json:"exception,omitempty"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=time,proto3" json:"time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_simulator_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Transaction) GetUnit() uint32 {
+	if x != nil {
+		return x.Unit
+	}
+	return 0
+}
+
+func (x *Transaction) GetFunction() uint32 {
+	if x != nil {
+		return x.Function
+	}
+	return 0
+}
+
+func (x *Transaction) GetRequest() string {
+	if x != nil {
+		return x.Request
+	}
+	return ""
+}
+
+func (x *Transaction) GetResponse() string {
+	if x != nil {
+		return x.Response
+	}
+	return ""
+}
+
+func (x *Transaction) GetException() string {
+	if x != nil {
+		return x.Exception
+	}
+	return ""
+}
+
+func (x *Transaction) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+type ListTransactionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transactions  []*Transaction         `protobuf:"bytes,1,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTransactionsResponse) Reset() {
+	*x = ListTransactionsResponse{}
+	mi := &file_simulator_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTransactionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTransactionsResponse) ProtoMessage() {}
+
+func (x *ListTransactionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTransactionsResponse.ProtoReflect.Descriptor instead.
+func (*ListTransactionsResponse) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListTransactionsResponse) GetTransactions() []*Transaction {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+type GetSnapshotRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSnapshotRequest) Reset() {
+	*x = GetSnapshotRequest{}
+	mi := &file_simulator_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSnapshotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSnapshotRequest) ProtoMessage() {}
+
+func (x *GetSnapshotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSnapshotRequest.ProtoReflect.Descriptor instead.
+func (*GetSnapshotRequest) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{12}
+}
+
+// Snapshot is the state of all devices, like the JSON of GET /snapshot.
+type Snapshot struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Devices       []*DeviceSnapshot      `protobuf:"bytes,1,rep,name=devices,proto3" json:"devices,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Snapshot) Reset() {
+	*x = Snapshot{}
+	mi := &file_simulator_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Snapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Snapshot) ProtoMessage() {}
+
+func (x *Snapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Snapshot.ProtoReflect.Descriptor instead.
+func (*Snapshot) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Snapshot) GetDevices() []*DeviceSnapshot {
+	if x != nil {
+		return x.Devices
+	}
+	return nil
+}
+
+type DeviceSnapshot struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Device *Device                `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	// state holds the state fields and the variables only snapshots hold, like week programs.
+	State         map[string]*structpb.Value `protobuf:"bytes,2,rep,name=state,proto3" json:"state,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeviceSnapshot) Reset() {
+	*x = DeviceSnapshot{}
+	mi := &file_simulator_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeviceSnapshot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeviceSnapshot) ProtoMessage() {}
+
+func (x *DeviceSnapshot) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeviceSnapshot.ProtoReflect.Descriptor instead.
+func (*DeviceSnapshot) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *DeviceSnapshot) GetDevice() *Device {
+	if x != nil {
+		return x.Device
+	}
+	return nil
+}
+
+func (x *DeviceSnapshot) GetState() map[string]*structpb.Value {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+type GetClockRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetClockRequest) Reset() {
+	*x = GetClockRequest{}
+	mi := &file_simulator_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetClockRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetClockRequest) ProtoMessage() {}
+
+func (x *GetClockRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetClockRequest.ProtoReflect.Descriptor instead.
+func (*GetClockRequest) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{15}
+}
+
+type ClockState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Paused        bool                   `protobuf:"varint,2,opt,name=paused,proto3" json:"paused,omitempty"`
+	TimeScale     float64                `protobuf:"fixed64,3,opt,name=time_scale,json=timeScale,proto3" json:"time_scale,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClockState) Reset() {
+	*x = ClockState{}
+	mi := &file_simulator_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClockState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClockState) ProtoMessage() {}
+
+func (x *ClockState) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClockState.ProtoReflect.Descriptor instead.
+func (*ClockState) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ClockState) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *ClockState) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+func (x *ClockState) GetTimeScale() float64 {
+	if x != nil {
+		return x.TimeScale
+	}
+	return 0
+}
+
+type SetClockRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Paused        *bool                  `protobuf:"varint,1,opt,name=paused,proto3,oneof" json:"paused,omitempty"`
+	TimeScale     *float64               `protobuf:"fixed64,2,opt,name=time_scale,json=timeScale,proto3,oneof" json:"time_scale,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetClockRequest) Reset() {
+	*x = SetClockRequest{}
+	mi := &file_simulator_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetClockRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetClockRequest) ProtoMessage() {}
+
+func (x *SetClockRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetClockRequest.ProtoReflect.Descriptor instead.
+func (*SetClockRequest) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *SetClockRequest) GetPaused() bool {
+	if x != nil && x.Paused != nil {
+		return *x.Paused
+	}
+	return false
+}
+
+func (x *SetClockRequest) GetTimeScale() float64 {
+	if x != nil && x.TimeScale != nil {
+		return *x.TimeScale
+	}
+	return 0
+}
+
+type RebootDeviceRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Unit  uint32                 `protobuf:"varint,1,opt,name=unit,proto3" json:"unit,omitempty"`
+	// downtime defaults to 10 seconds.
+	Downtime      *durationpb.Duration `protobuf:"bytes,2,opt,name=downtime,proto3" json:"downtime,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RebootDeviceRequest) Reset() {
+	*x = RebootDeviceRequest{}
+	mi := &file_simulator_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RebootDeviceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RebootDeviceRequest) ProtoMessage() {}
+
+func (x *RebootDeviceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RebootDeviceRequest.ProtoReflect.Descriptor instead.
+func (*RebootDeviceRequest) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *RebootDeviceRequest) GetUnit() uint32 {
+	if x != nil {
+		return x.Unit
+	}
+	return 0
+}
+
+func (x *RebootDeviceRequest) GetDowntime() *durationpb.Duration {
+	if x != nil {
+		return x.Downtime
+	}
+	return nil
+}
+
+type RebootDeviceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Downtime      *durationpb.Duration   `protobuf:"bytes,1,opt,name=downtime,proto3" json:"downtime,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RebootDeviceResponse) Reset() {
+	*x = RebootDeviceResponse{}
+	mi := &file_simulator_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RebootDeviceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RebootDeviceResponse) ProtoMessage() {}
+
+func (x *RebootDeviceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RebootDeviceResponse.ProtoReflect.Descriptor instead.
+func (*RebootDeviceResponse) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *RebootDeviceResponse) GetDowntime() *durationpb.Duration {
+	if x != nil {
+		return x.Downtime
+	}
+	return nil
+}
+
+type ListAlarmsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Unit          uint32                 `protobuf:"varint,1,opt,name=unit,proto3" json:"unit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAlarmsRequest) Reset() {
+	*x = ListAlarmsRequest{}
+	mi := &file_simulator_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAlarmsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAlarmsRequest) ProtoMessage() {}
+
+func (x *ListAlarmsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAlarmsRequest.ProtoReflect.Descriptor instead.
+func (*ListAlarmsRequest) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ListAlarmsRequest) GetUnit() uint32 {
+	if x != nil {
+		return x.Unit
+	}
+	return 0
+}
+
+type AlarmRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Unit          uint32                 `protobuf:"varint,1,opt,name=unit,proto3" json:"unit,omitempty"`
+	Code          string                 `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AlarmRequest) Reset() {
+	*x = AlarmRequest{}
+	mi := &file_simulator_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AlarmRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AlarmRequest) ProtoMessage() {}
+
+func (x *AlarmRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AlarmRequest.ProtoReflect.Descriptor instead.
+func (*AlarmRequest) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *AlarmRequest) GetUnit() uint32 {
+	if x != nil {
+		return x.Unit
+	}
+	return 0
+}
+
+func (x *AlarmRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+type Alarms struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Device        *Device                `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	Active        []string               `protobuf:"bytes,2,rep,name=active,proto3" json:"active,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Alarms) Reset() {
+	*x = Alarms{}
+	mi := &file_simulator_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Alarms) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Alarms) ProtoMessage() {}
+
+func (x *Alarms) ProtoReflect() protoreflect.Message {
+	mi := &file_simulator_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Alarms.ProtoReflect.Descriptor instead.
+func (*Alarms) Descriptor() ([]byte, []int) {
+	return file_simulator_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *Alarms) GetDevice() *Device {
+	if x != nil {
+		return x.Device
+	}
+	return nil
+}
+
+func (x *Alarms) GetActive() []string {
+	if x != nil {
+		return x.Active
+	}
+	return nil
+}
+
+var File_simulator_proto protoreflect.FileDescriptor
+
+const file_simulator_proto_rawDesc = "" +
+	"\n" +
+	"\x0fsimulator.proto\x12\x0fhrusimulator.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"0\n" +
+	"\x06Device\x12\x12\n" +
+	"\x04unit\x18\x01 \x01(\rR\x04unit\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\"z\n" +
+	"\x05Value\x12\x1f\n" +
+	"\n" +
+	"bool_value\x18\x01 \x01(\bH\x00R\tboolValue\x12#\n" +
+	"\fnumber_value\x18\x02 \x01(\x01H\x00R\vnumberValue\x12#\n" +
+	"\fstring_value\x18\x03 \x01(\tH\x00R\vstringValueB\x06\n" +
+	"\x04kind\"\x14\n" +
+	"\x12ListDevicesRequest\"H\n" +
+	"\x13ListDevicesResponse\x121\n" +
+	"\adevices\x18\x01 \x03(\v2\x17.hrusimulator.v1.DeviceR\adevices\"%\n" +
+	"\x0fGetStateRequest\x12\x12\n" +
+	"\x04unit\x18\x01 \x01(\rR\x04unit\"\xd3\x01\n" +
+	"\vDeviceState\x12/\n" +
+	"\x06device\x18\x01 \x01(\v2\x17.hrusimulator.v1.DeviceR\x06device\x12@\n" +
+	"\x06fields\x18\x02 \x03(\v2(.hrusimulator.v1.DeviceState.FieldsEntryR\x06fields\x1aQ\n" +
+	"\vFieldsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12,\n" +
+	"\x05value\x18\x02 \x01(\v2\x16.hrusimulator.v1.ValueR\x05value:\x028\x01\"\xbe\x01\n" +
+	"\x0fSetStateRequest\x12\x12\n" +
+	"\x04unit\x18\x01 \x01(\rR\x04unit\x12D\n" +
+	"\x06fields\x18\x02 \x03(\v2,.hrusimulator.v1.SetStateRequest.FieldsEntryR\x06fields\x1aQ\n" +
+	"\vFieldsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12,\n" +
+	"\x05value\x18\x02 \x01(\v2\x16.hrusimulator.v1.ValueR\x05value:\x028\x01\"\x13\n" +
+	"\x11WatchStateRequest\"\xb1\x01\n" +
+	"\n" +
+	"StateEvent\x12/\n" +
+	"\x06device\x18\x01 \x01(\v2\x17.hrusimulator.v1.DeviceR\x06device\x12\x14\n" +
+	"\x05field\x18\x02 \x01(\tR\x05field\x12,\n" +
+	"\x05value\x18\x03 \x01(\v2\x16.hrusimulator.v1.ValueR\x05value\x12.\n" +
+	"\x04time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\"\x19\n" +
+	"\x17ListTransactionsRequest\"\xc1\x01\n" +
+	"\vTransaction\x12\x12\n" +
+	"\x04unit\x18\x01 \x01(\rR\x04unit\x12\x1a\n" +
+	"\bfunction\x18\x02 \x01(\rR\bfunction\x12\x18\n" +
+	"\arequest\x18\x03 \x01(\tR\arequest\x12\x1a\n" +
+	"\bresponse\x18\x04 \x01(\tR\bresponse\x12\x1c\n" +
+	"\texception\x18\x05 \x01(\tR\texception\x12.\n" +
+	"\x04time\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\"\\\n" +
+	"\x18ListTransactionsResponse\x12@\n" +
+	"\ftransactions\x18\x01 \x03(\v2\x1c.hrusimulator.v1.TransactionR\ftransactions\"\x14\n" +
+	"\x12GetSnapshotRequest\"E\n" +
+	"\bSnapshot\x129\n" +
+	"\adevices\x18\x01 \x03(\v2\x1f.hrusimulator.v1.DeviceSnapshotR\adevices\"\xd5\x01\n" +
+	"\x0eDeviceSnapshot\x12/\n" +
+	"\x06device\x18\x01 \x01(\v2\x17.hrusimulator.v1.DeviceR\x06device\x12@\n" +
+	"\x05state\x18\x02 \x03(\v2*.hrusimulator.v1.DeviceSnapshot.StateEntryR\x05state\x1aP\n" +
+	"\n" +
+	"StateEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12,\n" +
+	"\x05value\x18\x02 \x01(\v2\x16.google.protobuf.ValueR\x05value:\x028\x01\"\x11\n" +
+	"\x0fGetClockRequest\"s\n" +
+	"\n" +
+	"ClockState\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x16\n" +
+	"\x06paused\x18\x02 \x01(\bR\x06paused\x12\x1d\n" +
+	"\n" +
+	"time_scale\x18\x03 \x01(\x01R\ttimeScale\"l\n" +
+	"\x0fSetClockRequest\x12\x1b\n" +
+	"\x06paused\x18\x01 \x01(\bH\x00R\x06paused\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"time_scale\x18\x02 \x01(\x01H\x01R\ttimeScale\x88\x01\x01B\t\n" +
+	"\a_pausedB\r\n" +
+	"\v_time_scale\"`\n" +
+	"\x13RebootDeviceRequest\x12\x12\n" +
+	"\x04unit\x18\x01 \x01(\rR\x04unit\x125\n" +
+	"\bdowntime\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\bdowntime\"M\n" +
+	"\x14RebootDeviceResponse\x125\n" +
+	"\bdowntime\x18\x01 \x01(\v2\x19.google.protobuf.DurationR\bdowntime\"'\n" +
+	"\x11ListAlarmsRequest\x12\x12\n" +
+	"\x04unit\x18\x01 \x01(\rR\x04unit\"6\n" +
+	"\fAlarmRequest\x12\x12\n" +
+	"\x04unit\x18\x01 \x01(\rR\x04unit\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\"Q\n" +
+	"\x06Alarms\x12/\n" +
+	"\x06device\x18\x01 \x01(\v2\x17.hrusimulator.v1.DeviceR\x06device\x12\x16\n" +
+	"\x06active\x18\x02 \x03(\tR\x06active2\x99\b\n" +
+	"\tSimulator\x12X\n" +
+	"\vListDevices\x12#.hrusimulator.v1.ListDevicesRequest\x1a$.hrusimulator.v1.ListDevicesResponse\x12J\n" +
+	"\bGetState\x12 .hrusimulator.v1.GetStateRequest\x1a\x1c.hrusimulator.v1.DeviceState\x12J\n" +
+	"\bSetState\x12 .hrusimulator.v1.SetStateRequest\x1a\x1c.hrusimulator.v1.DeviceState\x12O\n" +
+	"\n" +
+	"WatchState\x12\".hrusimulator.v1.WatchStateRequest\x1a\x1b.hrusimulator.v1.StateEvent0\x01\x12g\n" +
+	"\x10ListTransactions\x12(.hrusimulator.v1.ListTransactionsRequest\x1a).hrusimulator.v1.ListTransactionsResponse\x12M\n" +
+	"\vGetSnapshot\x12#.hrusimulator.v1.GetSnapshotRequest\x1a\x19.hrusimulator.v1.Snapshot\x12G\n" +
+	"\x0fRestoreSnapshot\x12\x19.hrusimulator.v1.Snapshot\x1a\x19.hrusimulator.v1.Snapshot\x12I\n" +
+	"\bGetClock\x12 .hrusimulator.v1.GetClockRequest\x1a\x1b.hrusimulator.v1.ClockState\x12I\n" +
+	"\bSetClock\x12 .hrusimulator.v1.SetClockRequest\x1a\x1b.hrusimulator.v1.ClockState\x12[\n" +
+	"\fRebootDevice\x12$.hrusimulator.v1.RebootDeviceRequest\x1a%.hrusimulator.v1.RebootDeviceResponse\x12I\n" +
+	"\n" +
+	"ListAlarms\x12\".hrusimulator.v1.ListAlarmsRequest\x1a\x17.hrusimulator.v1.Alarms\x12D\n" +
+	"\n" +
+	"RaiseAlarm\x12\x1d.hrusimulator.v1.AlarmRequest\x1a\x17.hrusimulator.v1.Alarms\x12D\n" +
+	"\n" +
+	"ClearAlarm\x12\x1d.hrusimulator.v1.AlarmRequest\x1a\x17.hrusimulator.v1.AlarmsB%Z#luftuj-cz/hru-simulator/simulatorpbb\x06proto3"
+
+var (
+	file_simulator_proto_rawDescOnce sync.Once
+	file_simulator_proto_rawDescData []byte
+)
+
+func file_simulator_proto_rawDescGZIP() []byte {
+	file_simulator_proto_rawDescOnce.Do(func() {
+		file_simulator_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_simulator_proto_rawDesc), len(file_simulator_proto_rawDesc)))
+	})
+	return file_simulator_proto_rawDescData
+}
+
+var file_simulator_proto_msgTypes = make([]protoimpl.MessageInfo, 26)
+var file_simulator_proto_goTypes = []any{
+	(*Device)(nil),                   // 0: hrusimulator.v1.Device
+	(*Value)(nil),                    // 1: hrusimulator.v1.Value
+	(*ListDevicesRequest)(nil),       // 2: hrusimulator.v1.ListDevicesRequest
+	(*ListDevicesResponse)(nil),      // 3: hrusimulator.v1.ListDevicesResponse
+	(*GetStateRequest)(nil),          // 4: hrusimulator.v1.GetStateRequest
+	(*DeviceState)(nil),              // 5: hrusimulator.v1.DeviceState
+	(*SetStateRequest)(nil),          // 6: hrusimulator.v1.SetStateRequest
+	(*WatchStateRequest)(nil),        // 7: hrusimulator.v1.WatchStateRequest
+	(*StateEvent)(nil),               // 8: hrusimulator.v1.StateEvent
+	(*ListTransactionsRequest)(nil),  // 9: hrusimulator.v1.ListTransactionsRequest
+	(*Transaction)(nil),              // 10: hrusimulator.v1.Transaction
+	(*ListTransactionsResponse)(nil), // 11: hrusimulator.v1.ListTransactionsResponse
+	(*GetSnapshotRequest)(nil),       // 12: hrusimulator.v1.GetSnapshotRequest
+	(*Snapshot)(nil),                 // 13: hrusimulator.v1.Snapshot
+	(*DeviceSnapshot)(nil),           // 14: hrusimulator.v1.DeviceSnapshot
+	(*GetClockRequest)(nil),          // 15: hrusimulator.v1.GetClockRequest
+	(*ClockState)(nil),               // 16: hrusimulator.v1.ClockState
+	(*SetClockRequest)(nil),          // 17: hrusimulator.v1.SetClockRequest
+	(*RebootDeviceRequest)(nil),      // 18: hrusimulator.v1.RebootDeviceRequest
+	(*RebootDeviceResponse)(nil),     // 19: hrusimulator.v1.RebootDeviceResponse
+	(*ListAlarmsRequest)(nil),        // 20: hrusimulator.v1.ListAlarmsRequest
+	(*AlarmRequest)(nil),             // 21: hrusimulator.v1.AlarmRequest
+	(*Alarms)(nil),                   // 22: hrusimulator.v1.Alarms
+	nil,                              // 23: hrusimulator.v1.DeviceState.FieldsEntry
+	nil,                              // 24: hrusimulator.v1.SetStateRequest.FieldsEntry
+	nil,                              // 25: hrusimulator.v1.DeviceSnapshot.StateEntry
+	(*timestamppb.Timestamp)(nil),    // 26: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),      // 27: google.protobuf.Duration
+	(*structpb.Value)(nil),           // 28: google.protobuf.Value
+}
+var file_simulator_proto_depIdxs = []int32{
+	0,  // 0: hrusimulator.v1.ListDevicesResponse.devices:type_name -> hrusimulator.v1.Device
+	0,  // 1: hrusimulator.v1.DeviceState.device:type_name -> hrusimulator.v1.Device
+	23, // 2: hrusimulator.v1.DeviceState.fields:type_name -> hrusimulator.v1.DeviceState.FieldsEntry
+	24, // 3: hrusimulator.v1.SetStateRequest.fields:type_name -> hrusimulator.v1.SetStateRequest.FieldsEntry
+	0,  // 4: hrusimulator.v1.StateEvent.device:type_name -> hrusimulator.v1.Device
+	1,  // 5: hrusimulator.v1.StateEvent.value:type_name -> hrusimulator.v1.Value
+	26, // 6: hrusimulator.v1.StateEvent.time:type_name -> google.protobuf.Timestamp
+	26, // 7: hrusimulator.v1.Transaction.time:type_name -> google.protobuf.Timestamp
+	10, // 8: hrusimulator.v1.ListTransactionsResponse.transactions:type_name -> hrusimulator.v1.Transaction
+	14, // 9: hrusimulator.v1.Snapshot.devices:type_name -> hrusimulator.v1.DeviceSnapshot
+	0,  // 10: hrusimulator.v1.DeviceSnapshot.device:type_name -> hrusimulator.v1.Device
+	25, // 11: hrusimulator.v1.DeviceSnapshot.state:type_name -> hrusimulator.v1.DeviceSnapshot.StateEntry
+	26, // 12: hrusimulator.v1.ClockState.time:type_name -> google.protobuf.Timestamp
+	27, // 13: hrusimulator.v1.RebootDeviceRequest.downtime:type_name -> google.protobuf.Duration
+	27, // 14: hrusimulator.v1.RebootDeviceResponse.downtime:type_name -> google.protobuf.Duration
+	0,  // 15: hrusimulator.v1.Alarms.device:type_name -> hrusimulator.v1.Device
+	1,  // 16: hrusimulator.v1.DeviceState.FieldsEntry.value:type_name -> hrusimulator.v1.Value
+	1,  // 17: hrusimulator.v1.SetStateRequest.FieldsEntry.value:type_name -> hrusimulator.v1.Value
+	28, // 18: hrusimulator.v1.DeviceSnapshot.StateEntry.value:type_name -> google.protobuf.Value
+	2,  // 19: hrusimulator.v1.Simulator.ListDevices:input_type -> hrusimulator.v1.ListDevicesRequest
+	4,  // 20: hrusimulator.v1.Simulator.GetState:input_type -> hrusimulator.v1.GetStateRequest
+	6,  // 21: hrusimulator.v1.Simulator.SetState:input_type -> hrusimulator.v1.SetStateRequest
+	7,  // 22: hrusimulator.v1.Simulator.WatchState:input_type -> hrusimulator.v1.WatchStateRequest
+	9,  // 23: hrusimulator.v1.Simulator.ListTransactions:input_type -> hrusimulator.v1.ListTransactionsRequest
+	12, // 24: hrusimulator.v1.Simulator.GetSnapshot:input_type -> hrusimulator.v1.GetSnapshotRequest
+	13, // 25: hrusimulator.v1.Simulator.RestoreSnapshot:input_type -> hrusimulator.v1.Snapshot
+	15, // 26: hrusimulator.v1.Simulator.GetClock:input_type -> hrusimulator.v1.GetClockRequest
+	17, // 27: hrusimulator.v1.Simulator.SetClock:input_type -> hrusimulator.v1.SetClockRequest
+	18, // 28: hrusimulator.v1.Simulator.RebootDevice:input_type -> hrusimulator.v1.RebootDeviceRequest
+	20, // 29: hrusimulator.v1.Simulator.ListAlarms:input_type -> hrusimulator.v1.ListAlarmsRequest
+	21, // 30: hrusimulator.v1.Simulator.RaiseAlarm:input_type -> hrusimulator.v1.AlarmRequest
+	21, // 31: hrusimulator.v1.Simulator.ClearAlarm:input_type -> hrusimulator.v1.AlarmRequest
+	3,  // 32: hrusimulator.v1.Simulator.ListDevices:output_type -> hrusimulator.v1.ListDevicesResponse
+	5,  // 33: hrusimulator.v1.Simulator.GetState:output_type -> hrusimulator.v1.DeviceState
+	5,  // 34: hrusimulator.v1.Simulator.SetState:output_type -> hrusimulator.v1.DeviceState
+	8,  // 35: hrusimulator.v1.Simulator.WatchState:output_type -> hrusimulator.v1.StateEvent
+	11, // 36: hrusimulator.v1.Simulator.ListTransactions:output_type -> hrusimulator.v1.ListTransactionsResponse
+	13, // 37: hrusimulator.v1.Simulator.GetSnapshot:output_type -> hrusimulator.v1.Snapshot
+	13, // 38: hrusimulator.v1.Simulator.RestoreSnapshot:output_type -> hrusimulator.v1.Snapshot
+	16, // 39: hrusimulator.v1.Simulator.GetClock:output_type -> hrusimulator.v1.ClockState
+	16, // 40: hrusimulator.v1.Simulator.SetClock:output_type -> hrusimulator.v1.ClockState
+	19, // 41: hrusimulator.v1.Simulator.RebootDevice:output_type -> hrusimulator.v1.RebootDeviceResponse
+	22, // 42: hrusimulator.v1.Simulator.ListAlarms:output_type -> hrusimulator.v1.Alarms
+	22, // 43: hrusimulator.v1.Simulator.RaiseAlarm:output_type -> hrusimulator.v1.Alarms
+	22, // 44: hrusimulator.v1.Simulator.ClearAlarm:output_type -> hrusimulator.v1.Alarms
+	32, // [32:45] is the sub-list for method output_type
+	19, // [19:32] is the sub-list for method input_type
+	19, // [19:19] is the sub-list for extension type_name
+	19, // [19:19] is the sub-list for extension extendee
+	0,  // [0:19] is the sub-list for field type_name
+}
+
+func init() { file_simulator_proto_init() }
+func file_simulator_proto_init() {
+	if File_simulator_proto != nil {
+		return
+	}
+	file_simulator_proto_msgTypes[1].OneofWrappers = []any{
+		(*Value_BoolValue)(nil),
+		(*Value_NumberValue)(nil),
+		(*Value_StringValue)(nil),
+	}
+	file_simulator_proto_msgTypes[17].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_simulator_proto_rawDesc), len(file_simulator_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   26,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_simulator_proto_goTypes,
+		DependencyIndexes: file_simulator_proto_depIdxs,
+		MessageInfos:      file_simulator_proto_msgTypes,
+	}.Build()
+	File_simulator_proto = out.File
+	file_simulator_proto_goTypes = nil
+	file_simulator_proto_depIdxs = nil
+}
@@ -0,0 +1,619 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: simulator.proto
+
+package simulatorpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Simulator_ListDevices_FullMethodName      = "/hrusimulator.v1.Simulator/ListDevices"
+	Simulator_GetState_FullMethodName         = "/hrusimulator.v1.Simulator/GetState"
+	Simulator_SetState_FullMethodName         = "/hrusimulator.v1.Simulator/SetState"
+	Simulator_WatchState_FullMethodName       = "/hrusimulator.v1.Simulator/WatchState"
+	Simulator_ListTransactions_FullMethodName = "/hrusimulator.v1.Simulator/ListTransactions"
+	Simulator_GetSnapshot_FullMethodName      = "/hrusimulator.v1.Simulator/GetSnapshot"
+	Simulator_RestoreSnapshot_FullMethodName  = "/hrusimulator.v1.Simulator/RestoreSnapshot"
+	Simulator_GetClock_FullMethodName         = "/hrusimulator.v1.Simulator/GetClock"
+	Simulator_SetClock_FullMethodName         = "/hrusimulator.v1.Simulator/SetClock"
+	Simulator_RebootDevice_FullMethodName     = "/hrusimulator.v1.Simulator/RebootDevice"
+	Simulator_ListAlarms_FullMethodName       = "/hrusimulator.v1.Simulator/ListAlarms"
+	Simulator_RaiseAlarm_FullMethodName       = "/hrusimulator.v1.Simulator/RaiseAlarm"
+	Simulator_ClearAlarm_FullMethodName       = "/hrusimulator.v1.Simulator/ClearAlarm"
+)
+
+// SimulatorClient is the client API for Simulator service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Simulator is the gRPC control API of the HRU simulator. It mirrors the HTTP API: the state of a
+// device are the scalar fields of its Go struct, e.g. powerRelative or filterAlarm.
+type SimulatorClient interface {
+	// ListDevices lists the devices by unit ID.
+	ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesResponse, error)
+	// GetState returns the state of a device.
+	GetState(ctx context.Context, in *GetStateRequest, opts ...grpc.CallOption) (*DeviceState, error)
+	// SetState sets the given state fields of a device, either all of them or none.
+	SetState(ctx context.Context, in *SetStateRequest, opts ...grpc.CallOption) (*DeviceState, error)
+	// WatchState streams an event for every state field changed by a Modbus request or the API, changes
+	// caused by time alone follow within a second of simulated time.
+	WatchState(ctx context.Context, in *WatchStateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StateEvent], error)
+	// ListTransactions returns the most recent requests with their responses.
+	ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*ListTransactionsResponse, error)
+	// GetSnapshot returns the state of all devices.
+	GetSnapshot(ctx context.Context, in *GetSnapshotRequest, opts ...grpc.CallOption) (*Snapshot, error)
+	// RestoreSnapshot sets the state of the devices in the snapshot, either of all of them or none, and
+	// returns the new state of all devices.
+	RestoreSnapshot(ctx context.Context, in *Snapshot, opts ...grpc.CallOption) (*Snapshot, error)
+	// GetClock returns the simulated time.
+	GetClock(ctx context.Context, in *GetClockRequest, opts ...grpc.CallOption) (*ClockState, error)
+	// SetClock pauses or resumes the simulated time or changes its scale, unset fields stay.
+	SetClock(ctx context.Context, in *SetClockRequest, opts ...grpc.CallOption) (*ClockState, error)
+	// RebootDevice power cycles a device: it is down for the downtime and comes back with its default
+	// state. It returns right away.
+	RebootDevice(ctx context.Context, in *RebootDeviceRequest, opts ...grpc.CallOption) (*RebootDeviceResponse, error)
+	// ListAlarms returns the active alarm codes of a device.
+	ListAlarms(ctx context.Context, in *ListAlarmsRequest, opts ...grpc.CallOption) (*Alarms, error)
+	// RaiseAlarm raises a vendor alarm code of a device, e.g. E3, and returns the active codes.
+	RaiseAlarm(ctx context.Context, in *AlarmRequest, opts ...grpc.CallOption) (*Alarms, error)
+	// ClearAlarm clears an active alarm code of a device and returns the active codes.
+	ClearAlarm(ctx context.Context, in *AlarmRequest, opts ...grpc.CallOption) (*Alarms, error)
+}
+
+type simulatorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSimulatorClient(cc grpc.ClientConnInterface) SimulatorClient {
+	return &simulatorClient{cc}
+}
+
+func (c *simulatorClient) ListDevices(ctx context.Context, in *ListDevicesRequest, opts ...grpc.CallOption) (*ListDevicesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDevicesResponse)
+	err := c.cc.Invoke(ctx, Simulator_ListDevices_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) GetState(ctx context.Context, in *GetStateRequest, opts ...grpc.CallOption) (*DeviceState, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeviceState)
+	err := c.cc.Invoke(ctx, Simulator_GetState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) SetState(ctx context.Context, in *SetStateRequest, opts ...grpc.CallOption) (*DeviceState, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeviceState)
+	err := c.cc.Invoke(ctx, Simulator_SetState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) WatchState(ctx context.Context, in *WatchStateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StateEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Simulator_ServiceDesc.Streams[0], Simulator_WatchState_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchStateRequest, StateEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Simulator_WatchStateClient = grpc.ServerStreamingClient[StateEvent]
+
+func (c *simulatorClient) ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*ListTransactionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTransactionsResponse)
+	err := c.cc.Invoke(ctx, Simulator_ListTransactions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) GetSnapshot(ctx context.Context, in *GetSnapshotRequest, opts ...grpc.CallOption) (*Snapshot, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Snapshot)
+	err := c.cc.Invoke(ctx, Simulator_GetSnapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) RestoreSnapshot(ctx context.Context, in *Snapshot, opts ...grpc.CallOption) (*Snapshot, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Snapshot)
+	err := c.cc.Invoke(ctx, Simulator_RestoreSnapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) GetClock(ctx context.Context, in *GetClockRequest, opts ...grpc.CallOption) (*ClockState, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClockState)
+	err := c.cc.Invoke(ctx, Simulator_GetClock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) SetClock(ctx context.Context, in *SetClockRequest, opts ...grpc.CallOption) (*ClockState, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClockState)
+	err := c.cc.Invoke(ctx, Simulator_SetClock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) RebootDevice(ctx context.Context, in *RebootDeviceRequest, opts ...grpc.CallOption) (*RebootDeviceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RebootDeviceResponse)
+	err := c.cc.Invoke(ctx, Simulator_RebootDevice_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) ListAlarms(ctx context.Context, in *ListAlarmsRequest, opts ...grpc.CallOption) (*Alarms, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Alarms)
+	err := c.cc.Invoke(ctx, Simulator_ListAlarms_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) RaiseAlarm(ctx context.Context, in *AlarmRequest, opts ...grpc.CallOption) (*Alarms, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Alarms)
+	err := c.cc.Invoke(ctx, Simulator_RaiseAlarm_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulatorClient) ClearAlarm(ctx context.Context, in *AlarmRequest, opts ...grpc.CallOption) (*Alarms, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Alarms)
+	err := c.cc.Invoke(ctx, Simulator_ClearAlarm_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SimulatorServer is the server API for Simulator service.
+// All implementations must embed UnimplementedSimulatorServer
+// for forward compatibility.
+//
+// Simulator is the gRPC control API of the HRU simulator. It mirrors the HTTP API: the state of a
+// device are the scalar fields of its Go struct, e.g. powerRelative or filterAlarm.
+type SimulatorServer interface {
+	// ListDevices lists the devices by unit ID.
+	ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error)
+	// GetState returns the state of a device.
+	GetState(context.Context, *GetStateRequest) (*DeviceState, error)
+	// SetState sets the given state fields of a device, either all of them or none.
+	SetState(context.Context, *SetStateRequest) (*DeviceState, error)
+	// WatchState streams an event for every state field changed by a Modbus request or the API, changes
+	// caused by time alone follow within a second of simulated time.
+	WatchState(*WatchStateRequest, grpc.ServerStreamingServer[StateEvent]) error
+	// ListTransactions returns the most recent requests with their responses.
+	ListTransactions(context.Context, *ListTransactionsRequest) (*ListTransactionsResponse, error)
+	// GetSnapshot returns the state of all devices.
+	GetSnapshot(context.Context, *GetSnapshotRequest) (*Snapshot, error)
+	// RestoreSnapshot sets the state of the devices in the snapshot, either of all of them or none, and
+	// returns the new state of all devices.
+	RestoreSnapshot(context.Context, *Snapshot) (*Snapshot, error)
+	// GetClock returns the simulated time.
+	GetClock(context.Context, *GetClockRequest) (*ClockState, error)
+	// SetClock pauses or resumes the simulated time or changes its scale, unset fields stay.
+	SetClock(context.Context, *SetClockRequest) (*ClockState, error)
+	// RebootDevice power cycles a device: it is down for the downtime and comes back with its default
+	// state. It returns right away.
+	RebootDevice(context.Context, *RebootDeviceRequest) (*RebootDeviceResponse, error)
+	// ListAlarms returns the active alarm codes of a device.
+	ListAlarms(context.Context, *ListAlarmsRequest) (*Alarms, error)
+	// RaiseAlarm raises a vendor alarm code of a device, e.g. E3, and returns the active codes.
+	RaiseAlarm(context.Context, *AlarmRequest) (*Alarms, error)
+	// ClearAlarm clears an active alarm code of a device and returns the active codes.
+	ClearAlarm(context.Context, *AlarmRequest) (*Alarms, error)
+	mustEmbedUnimplementedSimulatorServer()
+}
+
+// UnimplementedSimulatorServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSimulatorServer struct{}
+
+func (UnimplementedSimulatorServer) ListDevices(context.Context, *ListDevicesRequest) (*ListDevicesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListDevices not implemented")
+}
+func (UnimplementedSimulatorServer) GetState(context.Context, *GetStateRequest) (*DeviceState, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetState not implemented")
+}
+func (UnimplementedSimulatorServer) SetState(context.Context, *SetStateRequest) (*DeviceState, error) {
+	return nil, status.Error(codes.Unimplemented, "method SetState not implemented")
+}
+func (UnimplementedSimulatorServer) WatchState(*WatchStateRequest, grpc.ServerStreamingServer[StateEvent]) error {
+	return status.Error(codes.Unimplemented, "method WatchState not implemented")
+}
+func (UnimplementedSimulatorServer) ListTransactions(context.Context, *ListTransactionsRequest) (*ListTransactionsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListTransactions not implemented")
+}
+func (UnimplementedSimulatorServer) GetSnapshot(context.Context, *GetSnapshotRequest) (*Snapshot, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetSnapshot not implemented")
+}
+func (UnimplementedSimulatorServer) RestoreSnapshot(context.Context, *Snapshot) (*Snapshot, error) {
+	return nil, status.Error(codes.Unimplemented, "method RestoreSnapshot not implemented")
+}
+func (UnimplementedSimulatorServer) GetClock(context.Context, *GetClockRequest) (*ClockState, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetClock not implemented")
+}
+func (UnimplementedSimulatorServer) SetClock(context.Context, *SetClockRequest) (*ClockState, error) {
+	return nil, status.Error(codes.Unimplemented, "method SetClock not implemented")
+}
+func (UnimplementedSimulatorServer) RebootDevice(context.Context, *RebootDeviceRequest) (*RebootDeviceResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method RebootDevice not implemented")
+}
+func (UnimplementedSimulatorServer) ListAlarms(context.Context, *ListAlarmsRequest) (*Alarms, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListAlarms not implemented")
+}
+func (UnimplementedSimulatorServer) RaiseAlarm(context.Context, *AlarmRequest) (*Alarms, error) {
+	return nil, status.Error(codes.Unimplemented, "method RaiseAlarm not implemented")
+}
+func (UnimplementedSimulatorServer) ClearAlarm(context.Context, *AlarmRequest) (*Alarms, error) {
+	return nil, status.Error(codes.Unimplemented, "method ClearAlarm not implemented")
+}
+func (UnimplementedSimulatorServer) mustEmbedUnimplementedSimulatorServer() {}
+func (UnimplementedSimulatorServer) testEmbeddedByValue()                   {}
+
+// UnsafeSimulatorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SimulatorServer will
+// result in compilation errors.
+type UnsafeSimulatorServer interface {
+	mustEmbedUnimplementedSimulatorServer()
+}
+
+func RegisterSimulatorServer(s grpc.ServiceRegistrar, srv SimulatorServer) {
+	// If the following call panics, it indicates UnimplementedSimulatorServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Simulator_ServiceDesc, srv)
+}
+
+func _Simulator_ListDevices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).ListDevices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_ListDevices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).ListDevices(ctx, req.(*ListDevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_GetState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).GetState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_GetState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).GetState(ctx, req.(*GetStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_SetState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).SetState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_SetState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).SetState(ctx, req.(*SetStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_WatchState_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchStateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SimulatorServer).WatchState(m, &grpc.GenericServerStream[WatchStateRequest, StateEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Simulator_WatchStateServer = grpc.ServerStreamingServer[StateEvent]
+
+func _Simulator_ListTransactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTransactionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).ListTransactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_ListTransactions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).ListTransactions(ctx, req.(*ListTransactionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_GetSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).GetSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_GetSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).GetSnapshot(ctx, req.(*GetSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_RestoreSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Snapshot)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).RestoreSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_RestoreSnapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).RestoreSnapshot(ctx, req.(*Snapshot))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_GetClock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetClockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).GetClock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_GetClock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).GetClock(ctx, req.(*GetClockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_SetClock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetClockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).SetClock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_SetClock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).SetClock(ctx, req.(*SetClockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_RebootDevice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RebootDeviceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).RebootDevice(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_RebootDevice_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).RebootDevice(ctx, req.(*RebootDeviceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_ListAlarms_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAlarmsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).ListAlarms(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_ListAlarms_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).ListAlarms(ctx, req.(*ListAlarmsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_RaiseAlarm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AlarmRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).RaiseAlarm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_RaiseAlarm_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).RaiseAlarm(ctx, req.(*AlarmRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulator_ClearAlarm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AlarmRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulatorServer).ClearAlarm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Simulator_ClearAlarm_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulatorServer).ClearAlarm(ctx, req.(*AlarmRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Simulator_ServiceDesc is the grpc.ServiceDesc for Simulator service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Simulator_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "hrusimulator.v1.Simulator",
+	HandlerType: (*SimulatorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListDevices",
+			Handler:    _Simulator_ListDevices_Handler,
+		},
+		{
+			MethodName: "GetState",
+			Handler:    _Simulator_GetState_Handler,
+		},
+		{
+			MethodName: "SetState",
+			Handler:    _Simulator_SetState_Handler,
+		},
+		{
+			MethodName: "ListTransactions",
+			Handler:    _Simulator_ListTransactions_Handler,
+		},
+		{
+			MethodName: "GetSnapshot",
+			Handler:    _Simulator_GetSnapshot_Handler,
+		},
+		{
+			MethodName: "RestoreSnapshot",
+			Handler:    _Simulator_RestoreSnapshot_Handler,
+		},
+		{
+			MethodName: "GetClock",
+			Handler:    _Simulator_GetClock_Handler,
+		},
+		{
+			MethodName: "SetClock",
+			Handler:    _Simulator_SetClock_Handler,
+		},
+		{
+			MethodName: "RebootDevice",
+			Handler:    _Simulator_RebootDevice_Handler,
+		},
+		{
+			MethodName: "ListAlarms",
+			Handler:    _Simulator_ListAlarms_Handler,
+		},
+		{
+			MethodName: "RaiseAlarm",
+			Handler:    _Simulator_RaiseAlarm_Handler,
+		},
+		{
+			MethodName: "ClearAlarm",
+			Handler:    _Simulator_ClearAlarm_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchState",
+			Handler:       _Simulator_WatchState_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "simulator.proto",
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Snapshot is the state of all devices, to capture the exact state of a failing test and replay it
+// later.
+type Snapshot struct {
+	Devices []DeviceSnapshot `json:"devices"`
+}
+
+// DeviceSnapshot is the state of one device: the state fields of the API and the variables only
+// snapshots hold, like week programs, see HRULogic.
+type DeviceSnapshot struct {
+	Unit  uint8                      `json:"unit"`
+	Type  string                     `json:"type"`
+	State map[string]json.RawMessage `json:"state"`
+}
+
+// Snapshot returns the state of all devices by unit ID.
+func (a *API) Snapshot() Snapshot {
+	snapshot := Snapshot{Devices: []DeviceSnapshot{}}
+	for _, unit := range a.units() {
+		device, ok := a.lookup(unit)
+		if !ok {
+			continue
+		}
+		raw := map[string]json.RawMessage{}
+		simulation.Lock()
+		for name, value := range stateVars(device.logic) {
+			raw[name], _ = json.Marshal(value.Interface())
+		}
+		simulation.Unlock()
+		snapshot.Devices = append(snapshot.Devices, DeviceSnapshot{Unit: unit, Type: device.deviceType, State: raw})
+	}
+	return snapshot
+}
+
+// Restore sets the state of the devices in the snapshot, either of all of them or none. The devices
+// have to exist with the same type, devices missing in the snapshot keep their state.
+func (a *API) Restore(snapshot Snapshot) error {
+	simulation.Lock()
+	defer simulation.Unlock()
+	applies := make([]func(), 0, len(snapshot.Devices))
+	for _, saved := range snapshot.Devices {
+		device, ok := a.lookup(saved.Unit)
+		if !ok {
+			return fmt.Errorf("no device with unit ID %d", saved.Unit)
+		}
+		if device.deviceType != saved.Type {
+			return fmt.Errorf("unit %d is %s, the snapshot is of %s", saved.Unit, device.deviceType, saved.Type)
+		}
+		apply, err := parseDeviceState(stateVars(device.logic), saved.State)
+		if err != nil {
+			return fmt.Errorf("unit %d: %w", saved.Unit, err)
+		}
+		applies = append(applies, apply)
+	}
+	for i, apply := range applies {
+		apply()
+		a.publishChanges(snapshot.Devices[i].Unit)
+	}
+	a.stateChanged()
+	return nil
+}
+
+// ReadSnapshot reads a snapshot written by WriteSnapshot or GET /snapshot.
+func ReadSnapshot(path string) (Snapshot, error) {
+	var snapshot Snapshot
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// WriteSnapshot writes the snapshot as indented JSON. The file is replaced at once, so a crash
+// cannot leave half of it behind.
+func WriteSnapshot(path string, snapshot Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// PersistState keeps the device state in the file, like a real unit retains its settings: the state
+// saved by a previous run is restored, skipping devices whose unit ID now has another type, and
+// changes are written back within a second.
+func (a *API) PersistState(path string) error {
+	snapshot, err := ReadSnapshot(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		matching := Snapshot{}
+		for _, saved := range snapshot.Devices {
+			if device, ok := a.lookup(saved.Unit); ok && device.deviceType == saved.Type {
+				matching.Devices = append(matching.Devices, saved)
+			} else {
+				log.Printf("Not restoring unit %d from %s, it is no %s anymore\n", saved.Unit, path, saved.Type)
+			}
+		}
+		if err := a.Restore(matching); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	a.changed = make(chan struct{}, 1)
+	go func() {
+		for range a.changed {
+			// collect the changes of a second in one write
+			time.Sleep(time.Second)
+			if err := WriteSnapshot(path, a.Snapshot()); err != nil {
+				log.Printf("Persisting the state failed: %v\n", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (a *API) getSnapshot(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.Snapshot())
+}
+
+func (a *API) putSnapshot(w http.ResponseWriter, r *http.Request) {
+	var snapshot Snapshot
+	if err := json.NewDecoder(r.Body).Decode(&snapshot); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid snapshot: %w", err))
+		return
+	}
+	if err := a.Restore(snapshot); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, a.Snapshot())
+}
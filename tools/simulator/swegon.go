@@ -0,0 +1,198 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const (
+	swegonModeStopped    = 0
+	swegonModeAway       = 1
+	swegonModeHome       = 2
+	swegonModeBoost      = 3
+	swegonModeTravelling = 4
+)
+
+// swegonFanCurve holds the supply and extract fan speed in percent for one operating mode.
+type swegonFanCurve struct {
+	supply  int
+	extract int
+}
+
+type SwegonCasa struct {
+	mode             int
+	fanCurves        [3]swegonFanCurve
+	fireplace        bool
+	fireplaceMinutes int
+	boostMinutes     int
+	// boostStarted and fireplaceStarted are the simulated times the modes were entered, zero while
+	// they are off.
+	boostStarted     time.Time
+	fireplaceStarted time.Time
+}
+
+func NewSwegonCasa() *SwegonCasa {
+	return &SwegonCasa{
+		mode: swegonModeHome,
+		fanCurves: [3]swegonFanCurve{
+			{supply: 25, extract: 25},
+			{supply: 50, extract: 50},
+			{supply: 85, extract: 85},
+		},
+		fireplaceMinutes: 15,
+		boostMinutes:     30,
+	}
+}
+
+// Advance ends boost mode, returning to home mode, and fireplace mode once their minutes elapsed.
+// Modes entered over the API run from the first time they are seen.
+func (s *SwegonCasa) Advance(now time.Time) {
+	if s.mode != swegonModeBoost {
+		s.boostStarted = time.Time{}
+	} else if s.boostStarted.IsZero() {
+		s.boostStarted = now
+	} else if now.Sub(s.boostStarted) >= time.Duration(s.boostMinutes)*time.Minute {
+		s.mode = swegonModeHome
+		s.boostStarted = time.Time{}
+		log.Printf(">>> CHANGE: mode=%d, boost ended\n", s.mode)
+	}
+	if !s.fireplace {
+		s.fireplaceStarted = time.Time{}
+	} else if s.fireplaceStarted.IsZero() {
+		s.fireplaceStarted = now
+	} else if now.Sub(s.fireplaceStarted) >= time.Duration(s.fireplaceMinutes)*time.Minute {
+		s.fireplace = false
+		s.fireplaceStarted = time.Time{}
+		log.Printf(">>> CHANGE: fireplace=%v, ended\n", s.fireplace)
+	}
+}
+
+// currentCurve returns the fan speeds for the active mode. Fireplace mode keeps the supply fan
+// and lowers the extract fan to create overpressure.
+func (s *SwegonCasa) currentCurve() swegonFanCurve {
+	var curve swegonFanCurve
+	switch s.mode {
+	case swegonModeAway, swegonModeTravelling:
+		curve = s.fanCurves[0]
+	case swegonModeHome:
+		curve = s.fanCurves[1]
+	case swegonModeBoost:
+		curve = s.fanCurves[2]
+	}
+	if s.fireplace && s.mode != swegonModeStopped {
+		curve.extract = curve.extract / 2
+	}
+	return curve
+}
+
+// State implements HRULogic, the fan curves are named after their modes.
+func (s *SwegonCasa) State() DeviceState {
+	state := DeviceState{
+		"mode":             &s.mode,
+		"fireplace":        &s.fireplace,
+		"fireplaceMinutes": &s.fireplaceMinutes,
+		"boostMinutes":     &s.boostMinutes,
+		"boostStarted":     &s.boostStarted,
+		"fireplaceStarted": &s.fireplaceStarted,
+	}
+	for i, name := range []string{"away", "home", "boost"} {
+		state[name+"Curve.supply"] = &s.fanCurves[i].supply
+		state[name+"Curve.extract"] = &s.fanCurves[i].extract
+	}
+	return state
+}
+
+func (s *SwegonCasa) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Swegon", ProductCode: "CASA R5", Revision: "2.4"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 5000 {
+			return []uint16{uint16(s.mode)}, &Success
+		}
+		if register == 5001 {
+			if s.fireplace {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		if register == 5002 {
+			return []uint16{uint16(s.fireplaceMinutes)}, &Success
+		}
+		if register == 5003 {
+			return []uint16{uint16(s.boostMinutes)}, &Success
+		}
+		if register >= 5100 && register <= 5105 {
+			curve := s.fanCurves[(register-5100)/2]
+			if (register-5100)%2 == 0 {
+				return []uint16{uint16(curve.supply)}, &Success
+			}
+			return []uint16{uint16(curve.extract)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 6000 {
+			return []uint16{uint16(s.currentCurve().supply)}, &Success
+		}
+		if register == 6001 {
+			return []uint16{uint16(s.currentCurve().extract)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 5000 {
+			if value > swegonModeTravelling {
+				return &IllegalDataValue
+			}
+			s.mode = int(value)
+			if s.mode == swegonModeBoost {
+				// boosting again starts over
+				s.boostStarted = clock.Now()
+			}
+			log.Printf(">>> CHANGE: mode=%d\n", s.mode)
+			return &Success
+		}
+		if register == 5001 {
+			s.fireplace = value != 0
+			if s.fireplace {
+				s.fireplaceStarted = clock.Now()
+			}
+			log.Printf(">>> CHANGE: fireplace=%v\n", s.fireplace)
+			return &Success
+		}
+		if register == 5002 {
+			if value < 1 || value > 60 {
+				return &IllegalDataValue
+			}
+			s.fireplaceMinutes = int(value)
+			log.Printf(">>> CHANGE: fireplaceMinutes=%d\n", s.fireplaceMinutes)
+			return &Success
+		}
+		if register == 5003 {
+			if value < 1 || value > 120 {
+				return &IllegalDataValue
+			}
+			s.boostMinutes = int(value)
+			log.Printf(">>> CHANGE: boostMinutes=%d\n", s.boostMinutes)
+			return &Success
+		}
+		if register >= 5100 && register <= 5105 {
+			if value < 16 || value > 100 {
+				return &IllegalDataValue
+			}
+			curve := &s.fanCurves[(register-5100)/2]
+			if (register-5100)%2 == 0 {
+				curve.supply = int(value)
+			} else {
+				curve.extract = int(value)
+			}
+			log.Printf(">>> CHANGE: fanCurves=%v\n", s.fanCurves)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// tcpMaxFrameLength is the maximum size of a Modbus TCP frame: MBAP header + 253 bytes of PDU.
+const tcpMaxFrameLength = 260
+
+// newTCPFrame parses a Modbus TCP frame like NewTCPFrame, which rejects frames without any data
+// such as FC7 requests.
+func newTCPFrame(packet []byte) (*TCPFrame, error) {
+	if len(packet) != 8 {
+		return NewTCPFrame(packet)
+	}
+	frame := &TCPFrame{
+		TransactionIdentifier: binary.BigEndian.Uint16(packet[0:2]),
+		ProtocolIdentifier:    binary.BigEndian.Uint16(packet[2:4]),
+		Length:                binary.BigEndian.Uint16(packet[4:6]),
+		Device:                packet[6],
+		Function:              packet[7],
+		Data:                  []byte{},
+	}
+	if frame.Length != 2 {
+		return nil, fmt.Errorf("specified packet length does not match actual packet length")
+	}
+	return frame, nil
+}
+
+// serveTCP reads Modbus TCP frames from a connection and answers them until it is closed. The MBAP
+// length field delimits the frames.
+func serveTCP(bus *Bus, conn io.ReadWriter) error {
+	header := make([]byte, 7)
+	var previous uint16
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		length := int(binary.BigEndian.Uint16(header[4:6]))
+		if length < 2 || 6+length > tcpMaxFrameLength {
+			return fmt.Errorf("invalid MBAP length %d", length)
+		}
+		packet := make([]byte, 6+length)
+		copy(packet, header)
+		if _, err := io.ReadFull(conn, packet[len(header):]); err != nil {
+			return err
+		}
+
+		frame, err := newTCPFrame(packet)
+		if err != nil {
+			log.Printf("bad packet error %v\n", err)
+			continue
+		}
+		if frame.ProtocolIdentifier != 0 {
+			log.Printf("ignoring frame with protocol identifier %d\n", frame.ProtocolIdentifier)
+			continue
+		}
+		if response := bus.handleGateway(frame); response != nil {
+			if bus.faults.dropConnection(conn, frame) {
+				return nil
+			}
+			bus.faults.corruptTransactionID(response, previous)
+			if err := bus.faults.write(conn, bus.faults.encode(response)); err != nil {
+				return err
+			}
+		}
+		previous = frame.TransactionIdentifier
+	}
+}
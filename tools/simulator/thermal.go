@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// ThermalModel lets the supply and exhaust temperatures of a heat recovery unit drift toward the
+// steady state of its exchanger instead of staying static: the supply air gains the exchanger
+// efficiency times the difference between extract and intake air, the exhaust air loses as much.
+// The efficiency drops with the fan power, as the air spends less time in the exchanger, and an open
+// bypass skips the exchanger. The temperatures approach the steady state exponentially with the time
+// constant, so changes of the fan power, the bypass or the outdoor temperature show up as plausible
+// transitions.
+type ThermalModel struct {
+	// Efficiency is the temperature efficiency of the exchanger at minimum airflow, 0-1.
+	Efficiency   float64
+	TimeConstant time.Duration
+	// updated is the simulated time the temperatures have been computed up to.
+	updated time.Time
+}
+
+func NewThermalModel(efficiency float64) ThermalModel {
+	return ThermalModel{Efficiency: efficiency, TimeConstant: 3 * time.Minute, updated: clock.Now()}
+}
+
+// efficiency returns the efficiency at the fan power in percent, 20 % below the nominal one at full
+// power.
+func (m *ThermalModel) efficiency(power float64) float64 {
+	return m.Efficiency * (1 - 0.2*math.Min(power, 100)/100)
+}
+
+// Advance moves the supply and exhaust temperatures up to now, at the fan power in percent, with the
+// intake air (outdoor or preconditioned) and the extract air from the rooms. With the fans stopped the
+// air in the unit settles halfway between them.
+func (m *ThermalModel) Advance(now time.Time, power float64, bypass bool, intake, extract float64, supply, exhaust *float64) {
+	elapsed := now.Sub(m.updated)
+	if elapsed <= 0 {
+		return
+	}
+	m.updated = now
+	steadySupply, steadyExhaust := (intake+extract)/2, (intake+extract)/2
+	if power > 0 {
+		efficiency := m.efficiency(power)
+		if bypass {
+			efficiency = 0
+		}
+		steadySupply = intake + efficiency*(extract-intake)
+		steadyExhaust = extract - efficiency*(extract-intake)
+	}
+	approach := 1 - math.Exp(-float64(elapsed)/float64(m.TimeConstant))
+	*supply += (steadySupply - *supply) * approach
+	*exhaust += (steadyExhaust - *exhaust) * approach
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const (
+	thesslaCoilBypass = 9
+	thesslaCoilGWC    = 10
+	thesslaCoilHeater = 11
+
+	thesslaInputFrostProtection = 0
+)
+
+// ThesslaAirPack simulates the AirPack Home register map. Airflow is reported in m3/h, GWC
+// (ground heat exchanger), bypass and duct heater are coils, frost protection is a discrete input
+// and errors are published as two bit fields (E = errors stopping the unit, S = service alarms).
+type ThesslaAirPack struct {
+	mode               int
+	airflowPercent     int
+	nominalAirflow     int
+	bypass             bool
+	gwc                bool
+	heater             bool
+	outdoorTemperature float64
+	supplyTemperature  float64
+	exhaustTemperature float64
+	gwcTemperature     float64
+	errorBits          int
+	alarmBits          int
+	frostProtection    bool
+	fans               FanRamp
+	frost              FrostProtection
+}
+
+func NewThesslaAirPack() *ThesslaAirPack {
+	return &ThesslaAirPack{
+		mode:               1,
+		airflowPercent:     50,
+		nominalAirflow:     400,
+		outdoorTemperature: 3.5,
+		supplyTemperature:  18.4,
+		exhaustTemperature: 22.6,
+		gwcTemperature:     7.9,
+		fans:               NewFanRamp(50),
+		frost:              NewFrostProtection(-5),
+	}
+}
+
+func (t *ThesslaAirPack) airflow() int {
+	return t.nominalAirflow * t.airflowPercent / 100
+}
+
+// currentAirflow returns the airflow the fans deliver while ramping to the setpoint in m3/h.
+func (t *ThesslaAirPack) currentAirflow() int {
+	return int(math.Round(float64(t.nominalAirflow) * t.fans.Power() / 100))
+}
+
+// Advance ramps the fans to the airflow setpoint and runs frost protection.
+func (t *ThesslaAirPack) Advance(now time.Time) {
+	t.fans.Advance(now, float64(t.airflowPercent))
+	if frost := t.frost.active(t.frostProtection, t.outdoorTemperature); frost != t.frostProtection {
+		t.frostProtection = frost
+		log.Printf(">>> CHANGE: frostProtection=%v, heater=%v\n", t.frostProtection, t.heaterOn())
+	}
+}
+
+// heaterOn reports whether the duct heater runs, frost protection switches it on to preheat the
+// outdoor air regardless of the heater coil.
+func (t *ThesslaAirPack) heaterOn() bool {
+	return t.heater || t.frostProtection
+}
+
+// alarmRegisters reports the errors E1-E16 and the service alarms S1-S16 as bits of holding
+// registers 0x2000 and 0x2001.
+func (t *ThesslaAirPack) alarmRegisters() []alarmRegister {
+	return []alarmRegister{{prefix: "E", bits: &t.errorBits}, {prefix: "S", bits: &t.alarmBits}}
+}
+
+// State implements HRULogic.
+func (t *ThesslaAirPack) State() DeviceState {
+	return DeviceState{
+		"mode":               &t.mode,
+		"airflowPercent":     &t.airflowPercent,
+		"nominalAirflow":     &t.nominalAirflow,
+		"bypass":             &t.bypass,
+		"gwc":                &t.gwc,
+		"heater":             &t.heater,
+		"outdoorTemperature": &t.outdoorTemperature,
+		"supplyTemperature":  &t.supplyTemperature,
+		"exhaustTemperature": &t.exhaustTemperature,
+		"gwcTemperature":     &t.gwcTemperature,
+		"errorBits":          &t.errorBits,
+		"alarmBits":          &t.alarmBits,
+		"frostProtection":    &t.frostProtection,
+		"fans":               &t.fans,
+	}
+}
+
+func (t *ThesslaAirPack) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Thessla Green", ProductCode: "AirPack Home", Revision: "3.11"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 4208 {
+			return []uint16{uint16(t.mode)}, &Success
+		}
+		if register == 4210 {
+			return []uint16{uint16(t.airflowPercent)}, &Success
+		}
+		if register == 0x2000 {
+			return []uint16{uint16(t.errorBits)}, &Success
+		}
+		if register == 0x2001 {
+			return []uint16{uint16(t.alarmBits)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 16 {
+			return []uint16{uint16(int16(math.Round(t.outdoorTemperature * 10)))}, &Success
+		}
+		if register == 17 {
+			return []uint16{uint16(int16(math.Round(t.supplyTemperature * 10)))}, &Success
+		}
+		if register == 18 {
+			return []uint16{uint16(int16(math.Round(t.exhaustTemperature * 10)))}, &Success
+		}
+		if register == 20 {
+			return []uint16{uint16(int16(math.Round(t.gwcTemperature * 10)))}, &Success
+		}
+		if register == 256 || register == 257 {
+			return []uint16{uint16(t.currentAirflow())}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 4208 {
+			// 0 = automatic, 1 = manual, 2 = temporary
+			if value > 2 {
+				return &IllegalDataValue
+			}
+			t.mode = int(value)
+			log.Printf(">>> CHANGE: mode=%d\n", t.mode)
+			return &Success
+		}
+		if register == 4210 {
+			if value < 10 || value > 150 {
+				return &IllegalDataValue
+			}
+			t.airflowPercent = int(value)
+			log.Printf(">>> CHANGE: airflowPercent=%d, airflow=%d\n", t.airflowPercent, t.airflow())
+			return &Success
+		}
+		if register == 0x2000 || register == 0x2001 {
+			// writing zero acknowledges the alarms
+			if value != 0 {
+				return &IllegalDataValue
+			}
+			if register == 0x2000 {
+				t.errorBits = 0
+			} else {
+				t.alarmBits = 0
+			}
+			log.Printf(">>> CHANGE: errorBits=%d, alarmBits=%d\n", t.errorBits, t.alarmBits)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnReadCoils(serv, func(address uint16, numCoils int) ([]bool, *Exception) {
+		if address == thesslaCoilBypass && numCoils == 1 {
+			return []bool{t.bypass}, &Success
+		}
+		if address == thesslaCoilGWC && numCoils == 1 {
+			return []bool{t.gwc}, &Success
+		}
+		if address == thesslaCoilHeater && numCoils == 1 {
+			return []bool{t.heaterOn()}, &Success
+		}
+		return []bool{}, &IllegalDataAddress
+	})
+	OnWriteCoil(serv, func(address uint16, value bool) *Exception {
+		if address == thesslaCoilBypass {
+			t.bypass = value
+			log.Printf(">>> CHANGE: bypass=%v\n", t.bypass)
+			return &Success
+		}
+		if address == thesslaCoilGWC {
+			t.gwc = value
+			log.Printf(">>> CHANGE: gwc=%v\n", t.gwc)
+			return &Success
+		}
+		if address == thesslaCoilHeater {
+			t.heater = value
+			log.Printf(">>> CHANGE: heater=%v\n", t.heater)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnReadDiscreteInputs(serv, func(address uint16, numInputs int) ([]bool, *Exception) {
+		if address == thesslaInputFrostProtection && numInputs == 1 {
+			return []bool{t.frostProtection}, &Success
+		}
+		return []bool{}, &IllegalDataAddress
+	})
+	OnReadExceptionStatus(serv, func() uint8 {
+		var status uint8
+		if t.errorBits != 0 {
+			status |= exceptionStatusFault
+		}
+		if t.alarmBits != 0 {
+			status |= exceptionStatusService
+		}
+		return status
+	})
+}
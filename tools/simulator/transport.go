@@ -0,0 +1,306 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/goburrow/serial"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+type functionHandler func(*Server, Framer) ([]byte, *Exception)
+
+var (
+	// handlers holds the function handlers registered on each server. mbserver keeps its table
+	// private, but the transports implemented here decode frames themselves and need to dispatch them.
+	handlers = map[*Server]*[256]functionHandler{}
+
+	// unsupportedFunctionPolicies holds how each server answers functions it does not implement.
+	unsupportedFunctionPolicies = map[*Server]UnsupportedFunctionPolicy{}
+
+	// responseLatencies holds how long each server takes to answer.
+	responseLatencies = map[*Server]Latency{}
+
+	// simulation serializes all access to the device state, the same way mbserver handles its
+	// requests one by one.
+	simulation sync.Mutex
+)
+
+// ConnectionPolicy is how a TCP listener treats concurrent connections. Many embedded Modbus TCP
+// stacks accept only one client, another one is reset right away or accepted and closed.
+type ConnectionPolicy int
+
+const (
+	MultipleConnections ConnectionPolicy = iota
+	SingleConnectionReject
+	SingleConnectionDrop
+)
+
+// UnsupportedFunctionPolicy is the answer of a device to a function it does not implement, real
+// units differ in it.
+type UnsupportedFunctionPolicy int
+
+const (
+	UnsupportedIllegalFunction UnsupportedFunctionPolicy = iota
+	UnsupportedIllegalDataAddress
+	UnsupportedSilence
+)
+
+// SetUnsupportedFunctionPolicy sets the policy for unregistered function codes and handlers
+// returning IllegalFunction. The default is the IllegalFunction exception.
+func SetUnsupportedFunctionPolicy(s *Server, policy UnsupportedFunctionPolicy) {
+	unsupportedFunctionPolicies[s] = policy
+}
+
+// SetResponseLatency delays the responses of the server, so client timeouts can be validated
+// against slow units.
+func SetResponseLatency(s *Server, latency Latency) {
+	responseLatencies[s] = latency
+}
+
+// releaseServer drops the state kept for a server removed from its bus, so reloads and reboots
+// don't accumulate it. The caller holds the simulation lock.
+func releaseServer(s *Server) {
+	delete(handlers, s)
+	delete(unsupportedFunctionPolicies, s)
+	delete(responseLatencies, s)
+	delete(middlewares, s)
+	delete(diagnostics, s)
+}
+
+// registerFunctionHandler registers the handler on the server.
+func registerFunctionHandler(s *Server, funcCode uint8, function functionHandler) {
+	serverHandlers(s)[funcCode] = function
+}
+
+func serverHandlers(s *Server) *[256]functionHandler {
+	table, ok := handlers[s]
+	if !ok {
+		table = &[256]functionHandler{}
+		handlers[s] = table
+	}
+	return table
+}
+
+// dispatch passes a decoded request to the server's function handlers, updates the diagnostic
+// counters and builds the response frame, mirroring mbserver's internal request handling. The
+// response is nil when the server stays silent. The caller holds the simulation lock.
+func dispatch(s *Server, request Framer) Framer {
+	counters := serverDiagnostics(s)
+	counters.serverMessages++
+
+	response := request.Copy()
+	exception := &IllegalFunction
+	if function := serverHandlers(s)[request.GetFunction()]; function != nil {
+		exception = validateRequest(request.GetFunction(), request.GetData())
+		if exception == &Success {
+			var data []byte
+			data, exception = callHandler(withMiddleware(s, function), s, request)
+			response.SetData(data)
+		}
+	}
+	if exception == &IllegalFunction {
+		switch unsupportedFunctionPolicies[s] {
+		case UnsupportedIllegalDataAddress:
+			exception = &IllegalDataAddress
+		case UnsupportedSilence:
+			counters.serverNoResponses++
+			return nil
+		}
+	}
+	if exception != &Success {
+		counters.busExceptionErrors++
+		response.SetException(exception)
+	}
+	return response
+}
+
+// serveFunc reads requests in one framing from a byte stream and answers them.
+type serveFunc func(bus *Bus, port io.ReadWriter) error
+
+// ListenSerial serves the bus on a serial port.
+func ListenSerial(bus *Bus, config *serial.Config, serve serveFunc) error {
+	port, err := serial.Open(config)
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer port.Close()
+		if err := serve(bus, port); err != nil {
+			log.Printf("serial port %s closed: %v\n", config.Address, err)
+		}
+	}()
+	return nil
+}
+
+// ListenPTY serves the bus on a newly created pseudo terminal, so clients can be tested without
+// any serial hardware. The slave side is configured like a serial port and optionally
+// linked to a stable path. It returns the path of the slave device.
+func ListenPTY(bus *Bus, link string, config *serial.Config, serve serveFunc) (string, error) {
+	master, path, err := openPTY()
+	if err != nil {
+		return "", err
+	}
+
+	// Keeping the slave open puts it into raw mode and stops reads on the master from failing
+	// whenever no client has the device open.
+	slaveConfig := *config
+	slaveConfig.Address = path
+	slave, err := serial.Open(&slaveConfig)
+	if err != nil {
+		master.Close()
+		return "", err
+	}
+
+	if link != "" {
+		if info, err := os.Lstat(link); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			os.Remove(link)
+		}
+		if err := os.Symlink(path, link); err != nil {
+			master.Close()
+			slave.Close()
+			return "", err
+		}
+		path = link
+	}
+
+	go func() {
+		defer master.Close()
+		defer slave.Close()
+		if err := serve(bus, master); err != nil {
+			log.Printf("pty %s closed: %v\n", path, err)
+		}
+	}()
+	return path, nil
+}
+
+// ListenStreamTCP accepts TCP connections and serves the framing on them: Modbus TCP, or serial
+// framing the way RS485 to Ethernet gateways tunnel the bus without converting to Modbus TCP.
+func ListenStreamTCP(bus *Bus, addressPort string, policy ConnectionPolicy, serve serveFunc) error {
+	listen, err := listenTCP(addressPort, policy)
+	if err != nil {
+		return err
+	}
+	go acceptConnections(bus, listen, serve)
+	return nil
+}
+
+// listenTCP listens on the address with the connection policy.
+func listenTCP(addressPort string, policy ConnectionPolicy) (net.Listener, error) {
+	listen, err := net.Listen("tcp", addressPort)
+	if err != nil || policy == MultipleConnections {
+		return listen, err
+	}
+	return &singleConnectionListener{Listener: listen, drop: policy == SingleConnectionDrop}, nil
+}
+
+// singleConnectionListener accepts one connection at a time, others are reset or, with drop,
+// accepted and closed while it is open.
+type singleConnectionListener struct {
+	net.Listener
+	drop bool
+	open atomic.Bool
+}
+
+func (l *singleConnectionListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.open.CompareAndSwap(false, true) {
+			return &exclusiveConn{Conn: conn, listener: l}, nil
+		}
+		if l.drop {
+			log.Printf("!!! FAULT: dropped connection from %s, another client is connected\n", conn.RemoteAddr())
+			conn.Close()
+		} else {
+			log.Printf("!!! FAULT: rejected connection from %s, another client is connected\n", conn.RemoteAddr())
+			resetConnection(conn)
+		}
+	}
+}
+
+// exclusiveConn frees its listener for the next connection when it is closed.
+type exclusiveConn struct {
+	net.Conn
+	listener *singleConnectionListener
+	closed   sync.Once
+}
+
+func (c *exclusiveConn) Close() error {
+	err := c.Conn.Close()
+	c.closed.Do(func() { c.listener.open.Store(false) })
+	return err
+}
+
+func (c *exclusiveConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+func acceptConnections(bus *Bus, listen net.Listener, serve serveFunc) {
+	for {
+		conn, err := listen.Accept()
+		if err != nil {
+			log.Printf("Unable to accept connections: %v\n", err)
+			return
+		}
+		if !bus.connect(conn) {
+			resetConnection(conn)
+			continue
+		}
+		go func() {
+			defer bus.disconnect(conn)
+			defer conn.Close()
+			// a reboot closes the connection while it is read
+			if err := serve(bus, conn); err != nil && !errors.Is(err, net.ErrClosed) {
+				log.Printf("read error %v\n", err)
+			}
+		}()
+	}
+}
+
+// ListenUDP serves Modbus TCP frames (MBAP header + PDU) carried in UDP datagrams, one request
+// per datagram, as used by some PLC bridges.
+func ListenUDP(bus *Bus, addressPort string) error {
+	conn, err := net.ListenPacket("udp", addressPort)
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer conn.Close()
+		packet := make([]byte, 512)
+		var previous uint16
+		for {
+			n, addr, err := conn.ReadFrom(packet)
+			if err != nil {
+				log.Printf("UDP read error %v\n", err)
+				return
+			}
+			frame, err := newTCPFrame(append([]byte(nil), packet[:n]...))
+			if err != nil {
+				log.Printf("bad UDP packet error %v\n", err)
+				continue
+			}
+			response := bus.handleGateway(frame)
+			if response != nil {
+				bus.faults.corruptTransactionID(response, previous)
+				packet := bus.faults.encode(response)
+				if _, err := conn.WriteTo(packet, addr); err != nil {
+					log.Printf("UDP write error %v\n", err)
+				}
+				if bus.faults.duplicate() {
+					conn.WriteTo(packet, addr)
+				}
+			}
+			previous = frame.TransactionIdentifier
+		}
+	}()
+	return nil
+}
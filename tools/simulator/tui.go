@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// tuiLogLines is the number of log lines shown below the state.
+const tuiLogLines = 8
+
+// RunTUI shows the state of the devices in the terminal and lets the operator change it while
+// clients poll the simulator. The log is redirected into the view. It returns when the operator quits.
+func RunTUI(api *API) error {
+	logs := &logBuffer{}
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+
+	model := &tuiModel{api: api, logs: logs}
+	for _, unit := range api.units() {
+		device, _ := api.lookup(unit)
+		simulation.Lock()
+		state := deviceState(device.logic)
+		simulation.Unlock()
+		names := make([]string, 0, len(state))
+		for name := range state {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			model.rows = append(model.rows, tuiRow{device: device, field: name})
+		}
+	}
+	model.refresh()
+
+	_, err := tea.NewProgram(model, tea.WithAltScreen()).Run()
+	return err
+}
+
+// logBuffer keeps the last lines written to the log.
+type logBuffer struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.lines = append(b.lines, strings.Split(strings.TrimRight(string(p), "\n"), "\n")...)
+	if len(b.lines) > tuiLogLines {
+		b.lines = b.lines[len(b.lines)-tuiLogLines:]
+	}
+	return len(p), nil
+}
+
+func (b *logBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return strings.Join(b.lines, "\n")
+}
+
+// tuiRow is one state field of a device.
+type tuiRow struct {
+	device apiDevice
+	field  string
+}
+
+type tuiTick struct{}
+
+type tuiModel struct {
+	api     *API
+	logs    *logBuffer
+	rows    []tuiRow
+	states  map[uint8]map[string]any
+	cursor  int
+	height  int
+	editing bool
+	input   string
+	message string
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(500*time.Millisecond, func(time.Time) tea.Msg {
+		return tuiTick{}
+	})
+}
+
+// refresh reads the current state of the devices.
+func (m *tuiModel) refresh() {
+	devices := map[uint8]apiDevice{}
+	for _, unit := range m.api.units() {
+		devices[unit], _ = m.api.lookup(unit)
+	}
+	simulation.Lock()
+	defer simulation.Unlock()
+	m.states = map[uint8]map[string]any{}
+	for unit, device := range devices {
+		m.states[unit] = deviceState(device.logic)
+	}
+}
+
+func (m *tuiModel) value(row tuiRow) any {
+	return m.states[row.device.unitID][row.field]
+}
+
+// set changes the state field of the row to the value typed by the operator.
+func (m *tuiModel) set(row tuiRow, input string) {
+	changes := map[string]json.RawMessage{row.field: stateValue(m.value(row), input)}
+	// the device may have been reloaded since the rows were built
+	device, ok := m.api.lookup(row.device.unitID)
+	if !ok {
+		m.message = fmt.Sprintf("no device with unit ID %d", row.device.unitID)
+		return
+	}
+	if _, err := m.api.setState(device, changes); err != nil {
+		m.message = err.Error()
+		return
+	}
+	m.message = ""
+	m.refresh()
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return tick()
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tuiTick:
+		m.refresh()
+		return m, tick()
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case tea.KeyMsg:
+		if len(m.rows) == 0 {
+			if msg.String() == "q" || msg.String() == "ctrl+c" {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		row := m.rows[m.cursor]
+		if m.editing {
+			switch msg.Type {
+			case tea.KeyEnter:
+				m.editing = false
+				m.set(row, m.input)
+			case tea.KeyEsc:
+				m.editing = false
+			case tea.KeyBackspace:
+				if len(m.input) > 0 {
+					m.input = m.input[:len(m.input)-1]
+				}
+			case tea.KeyCtrlC:
+				return m, tea.Quit
+			case tea.KeyRunes, tea.KeySpace:
+				m.input += string(msg.Runes)
+			}
+			return m, nil
+		}
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "p":
+			clock.SetPaused(!clock.Paused())
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.rows)-1 {
+				m.cursor++
+			}
+		case "enter", " ":
+			if value, ok := m.value(row).(bool); ok {
+				m.set(row, fmt.Sprint(!value))
+			} else {
+				m.editing = true
+				m.input = fmt.Sprint(m.value(row))
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *tuiModel) View() string {
+	// device headers and fields, scrolled to keep the cursor on screen
+	var lines []string
+	cursorLine := 0
+	for i, row := range m.rows {
+		if i == 0 || m.rows[i-1].device.unitID != row.device.unitID {
+			lines = append(lines, fmt.Sprintf("%s (unit %d)", row.device.deviceType, row.device.unitID))
+		}
+		cursor := "  "
+		value := fmt.Sprint(m.value(row))
+		if i == m.cursor {
+			cursor = "> "
+			cursorLine = len(lines)
+			if m.editing {
+				value = m.input + "_"
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s%-24s %s", cursor, row.field, value))
+	}
+	if visible := m.height - tuiLogLines - 4; m.height > 0 && len(lines) > visible && visible > 0 {
+		start := min(max(cursorLine-visible/2, 0), len(lines)-visible)
+		lines = lines[start : start+visible]
+	}
+
+	var view strings.Builder
+	view.WriteString(strings.Join(lines, "\n"))
+	view.WriteString("\n\n↑/↓ select, enter edit or toggle, esc cancel, p pause or resume, q quit")
+	if clock.Paused() {
+		view.WriteString(" - PAUSED")
+	}
+	if m.message != "" {
+		fmt.Fprintf(&view, " - error: %s", m.message)
+	}
+	fmt.Fprintf(&view, "\n\n%s", m.logs)
+	return view.String()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"runtime/debug"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// validateRequest checks the request data of the function against the length, byte count and
+// quantity limits of the Modbus specification, so the function handlers can index it safely. The
+// checks follow the order of the specification: Illegal Data Value for malformed requests and
+// quantities out of range, Illegal Data Address for ranges beyond the 16-bit address space.
+func validateRequest(function uint8, data []byte) *Exception {
+	switch function {
+	case FnReadCoils, FnReadDiscreteInputs:
+		return validateRange(data, 2000)
+	case FnReadHoldingRegisters, FnReadInputRegisters:
+		return validateRange(data, 125)
+	case FnWriteSingleCoil:
+		if len(data) != 4 {
+			return &IllegalDataValue
+		}
+		if value := binary.BigEndian.Uint16(data[2:4]); value != 0x0000 && value != 0xFF00 {
+			return &IllegalDataValue
+		}
+	case FnWriteHoldingRegister:
+		if len(data) != 4 {
+			return &IllegalDataValue
+		}
+	case FnReadExceptionStatus:
+		if len(data) != 0 {
+			return &IllegalDataValue
+		}
+	case FnDiagnostics:
+		if len(data) < 4 || len(data)%2 != 0 {
+			return &IllegalDataValue
+		}
+	case FnWriteMultipleCoils:
+		if len(data) < 5 || len(data) != 5+int(data[4]) {
+			return &IllegalDataValue
+		}
+		quantity := int(binary.BigEndian.Uint16(data[2:4]))
+		if int(data[4]) != (quantity+7)/8 {
+			return &IllegalDataValue
+		}
+		return validateRange(data[:4], 1968)
+	case FnWriteHoldingRegisters:
+		if len(data) < 5 || len(data) != 5+int(data[4]) {
+			return &IllegalDataValue
+		}
+		quantity := int(binary.BigEndian.Uint16(data[2:4]))
+		if int(data[4]) != 2*quantity {
+			return &IllegalDataValue
+		}
+		return validateRange(data[:4], 123)
+	case FnReadFileRecord:
+		if len(data) < 1 || len(data) != 1+int(data[0]) || data[0] < 0x07 || data[0] > 0xF5 || data[0]%7 != 0 {
+			return &IllegalDataValue
+		}
+	case FnWriteFileRecord:
+		if len(data) < 1 || len(data) != 1+int(data[0]) || data[0] < 0x09 || data[0] > 0xFB {
+			return &IllegalDataValue
+		}
+	case FnMaskWriteRegister:
+		if len(data) != 6 {
+			return &IllegalDataValue
+		}
+	case FnReadWriteMultipleRegisters:
+		if len(data) < 9 || len(data) != 9+int(data[8]) {
+			return &IllegalDataValue
+		}
+		writeQuantity := int(binary.BigEndian.Uint16(data[6:8]))
+		if int(data[8]) != 2*writeQuantity {
+			return &IllegalDataValue
+		}
+		if err := validateRange(data[0:4], 125); err != &Success {
+			return err
+		}
+		return validateRange(data[4:8], 121)
+	case FnReadFIFOQueue:
+		if len(data) != 2 {
+			return &IllegalDataValue
+		}
+	case FnEncapsulatedInterface:
+		if len(data) < 1 || (data[0] == meiReadDeviceIdentification && len(data) != 3) {
+			return &IllegalDataValue
+		}
+	}
+	return &Success
+}
+
+// validateRange checks a starting address and quantity pair.
+func validateRange(data []byte, maxQuantity int) *Exception {
+	if len(data) != 4 {
+		return &IllegalDataValue
+	}
+	address := int(binary.BigEndian.Uint16(data[0:2]))
+	quantity := int(binary.BigEndian.Uint16(data[2:4]))
+	if quantity < 1 || quantity > maxQuantity {
+		return &IllegalDataValue
+	}
+	if address+quantity > 0x10000 {
+		return &IllegalDataAddress
+	}
+	return &Success
+}
+
+// callHandler runs the function handler, a panic in it is answered with Slave Device Failure
+// instead of taking down the whole simulator.
+func callHandler(function functionHandler, s *Server, request Framer) (data []byte, exception *Exception) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("function %d handler failed: %v\n%s", request.GetFunction(), err, debug.Stack())
+			data, exception = []byte{}, &SlaveDeviceFailure
+		}
+	}()
+	return function(s, request)
+}
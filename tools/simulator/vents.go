@@ -0,0 +1,136 @@
+package main
+
+import (
+	"log"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const (
+	ventsPairingPaired   = 0x1
+	ventsPairingMaster   = 0x2
+	ventsPairingLinkOK   = 0x4
+	ventsPairingSearched = 0x8
+)
+
+type VentsTwinFresh struct {
+	powerOn      bool
+	speed        int
+	boost        bool
+	direction    int
+	paired       bool
+	master       bool
+	linkOK       bool
+	pairingSlave int
+}
+
+func NewVentsTwinFresh() *VentsTwinFresh {
+	return &VentsTwinFresh{
+		powerOn:      true,
+		speed:        1,
+		boost:        false,
+		direction:    1,
+		paired:       true,
+		master:       true,
+		linkOK:       true,
+		pairingSlave: 2,
+	}
+}
+
+func (v *VentsTwinFresh) pairingStatus() uint16 {
+	var res uint16
+	if v.paired {
+		res |= ventsPairingPaired
+	}
+	if v.master {
+		res |= ventsPairingMaster
+	}
+	if v.paired && v.linkOK {
+		res |= ventsPairingLinkOK
+	}
+	if !v.paired {
+		res |= ventsPairingSearched
+	}
+	return res
+}
+
+// State implements HRULogic.
+func (v *VentsTwinFresh) State() DeviceState {
+	return DeviceState{
+		"powerOn":      &v.powerOn,
+		"speed":        &v.speed,
+		"boost":        &v.boost,
+		"direction":    &v.direction,
+		"paired":       &v.paired,
+		"master":       &v.master,
+		"linkOK":       &v.linkOK,
+		"pairingSlave": &v.pairingSlave,
+	}
+}
+
+func (v *VentsTwinFresh) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "VENTS", ProductCode: "TwinFresh Expert", Revision: "1.2"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0x01 {
+			if v.powerOn {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		if register == 0x02 {
+			return []uint16{uint16(v.speed)}, &Success
+		}
+		if register == 0x06 {
+			if v.boost {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		if register == 0xB7 {
+			return []uint16{uint16(v.direction)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0x20 {
+			return []uint16{v.pairingStatus()}, &Success
+		}
+		if register == 0x21 {
+			return []uint16{uint16(v.pairingSlave)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 0x01 {
+			v.powerOn = value != 0
+			log.Printf(">>> CHANGE: powerOn=%v\n", v.powerOn)
+			return &Success
+		}
+		if register == 0x02 {
+			if value < 1 || value > 3 {
+				return &IllegalDataValue
+			}
+			v.speed = int(value)
+			log.Printf(">>> CHANGE: speed=%d\n", v.speed)
+			return &Success
+		}
+		if register == 0x06 {
+			v.boost = value != 0
+			log.Printf(">>> CHANGE: boost=%v\n", v.boost)
+			return &Success
+		}
+		if register == 0xB7 {
+			// 0 = ventilation, 1 = heat recovery, 2 = supply
+			if value > 2 {
+				return &IllegalDataValue
+			}
+			v.direction = int(value)
+			log.Printf(">>> CHANGE: direction=%d\n", v.direction)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// VOCSensor simulates a VOC index sensor (1-500, 100 = typical indoor air). A pollution event ramps
+// the index linearly from the baseline to the peak and then decays it back with the configured
+// half-life, which is how cooking or cleaning shows up on real sensors.
+type VOCSensor struct {
+	baseline        int
+	peak            int
+	rampSeconds     int
+	halfLifeSeconds int
+	eventStart      time.Time
+	eventActive     bool
+}
+
+func NewVOCSensor() *VOCSensor {
+	return &VOCSensor{
+		baseline:        100,
+		peak:            400,
+		rampSeconds:     120,
+		halfLifeSeconds: 300,
+	}
+}
+
+func (v *VOCSensor) startEvent() {
+	v.eventStart = clock.Now()
+	v.eventActive = true
+}
+
+func (v *VOCSensor) index() int {
+	if !v.eventActive {
+		return v.baseline
+	}
+	elapsed := clock.Since(v.eventStart).Seconds()
+	ramp := float64(v.rampSeconds)
+	if elapsed < ramp {
+		return v.baseline + int(float64(v.peak-v.baseline)*elapsed/ramp)
+	}
+	decay := math.Pow(0.5, (elapsed-ramp)/float64(v.halfLifeSeconds))
+	index := v.baseline + int(float64(v.peak-v.baseline)*decay)
+	if index <= v.baseline {
+		v.eventActive = false
+	}
+	return index
+}
+
+// tvoc converts the index to an approximate TVOC concentration in ppb.
+func (v *VOCSensor) tvoc() int {
+	return v.index() * 5 / 2
+}
+
+// level maps the index to the 1 (excellent) - 5 (unhealthy) air quality scale.
+func (v *VOCSensor) level() int {
+	index := v.index()
+	switch {
+	case index <= 100:
+		return 1
+	case index <= 150:
+		return 2
+	case index <= 250:
+		return 3
+	case index <= 400:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// State implements HRULogic.
+func (v *VOCSensor) State() DeviceState {
+	return DeviceState{
+		"baseline":        &v.baseline,
+		"peak":            &v.peak,
+		"rampSeconds":     &v.rampSeconds,
+		"halfLifeSeconds": &v.halfLifeSeconds,
+		"eventStart":      &v.eventStart,
+		"eventActive":     &v.eventActive,
+	}
+}
+
+func (v *VOCSensor) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Luftuj", ProductCode: "VOC sensor", Revision: "1.0.0"})
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0 {
+			return []uint16{uint16(v.index())}, &Success
+		}
+		if register == 1 {
+			return []uint16{uint16(v.tvoc())}, &Success
+		}
+		if register == 2 {
+			return []uint16{uint16(v.level())}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	// The holding registers control the simulated pollution events.
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 100 {
+			return []uint16{uint16(v.baseline)}, &Success
+		}
+		if register == 101 {
+			return []uint16{uint16(v.peak)}, &Success
+		}
+		if register == 102 {
+			return []uint16{uint16(v.rampSeconds)}, &Success
+		}
+		if register == 103 {
+			return []uint16{uint16(v.halfLifeSeconds)}, &Success
+		}
+		if register == 104 {
+			v.index()
+			if v.eventActive {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 100 {
+			if value < 1 || value > 500 {
+				return &IllegalDataValue
+			}
+			v.baseline = int(value)
+			log.Printf(">>> CHANGE: baseline=%d\n", v.baseline)
+			return &Success
+		}
+		if register == 101 {
+			if value < 1 || value > 500 {
+				return &IllegalDataValue
+			}
+			v.peak = int(value)
+			log.Printf(">>> CHANGE: peak=%d\n", v.peak)
+			return &Success
+		}
+		if register == 102 {
+			v.rampSeconds = max(int(value), 1)
+			log.Printf(">>> CHANGE: rampSeconds=%d\n", v.rampSeconds)
+			return &Success
+		}
+		if register == 103 {
+			v.halfLifeSeconds = max(int(value), 1)
+			log.Printf(">>> CHANGE: halfLifeSeconds=%d\n", v.halfLifeSeconds)
+			return &Success
+		}
+		if register == 104 {
+			if value != 0 {
+				v.startEvent()
+			} else {
+				v.eventActive = false
+			}
+			log.Printf(">>> CHANGE: eventActive=%v\n", v.eventActive)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"log"
+	"math"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+// Wanas units publish everything, including the temperature readouts, as holding registers.
+type Wanas struct {
+	gear               int
+	gheDamperMode      int
+	gheDamperOpen      bool
+	outdoorTemperature float64
+	gheTemperature     float64
+	supplyTemperature  float64
+	extractTemperature float64
+	exhaustTemperature float64
+	thermal            ThermalModel
+}
+
+func NewWanas() *Wanas {
+	w := &Wanas{
+		gear:               2,
+		gheDamperMode:      0,
+		outdoorTemperature: -2.5,
+		gheTemperature:     4.0,
+		supplyTemperature:  17.2,
+		extractTemperature: 21.6,
+		exhaustTemperature: 5.1,
+		thermal:            NewThermalModel(0.85),
+	}
+	w.updateGHEDamper()
+	return w
+}
+
+// updateGHEDamper evaluates the ground heat exchanger damper: 0 = automatic, 1 = closed, 2 = open.
+// In automatic mode the air is taken through the exchanger whenever it is warmer than the outdoor air
+// in winter or cooler in summer.
+func (w *Wanas) updateGHEDamper() {
+	switch w.gheDamperMode {
+	case 1:
+		w.gheDamperOpen = false
+	case 2:
+		w.gheDamperOpen = true
+	default:
+		if w.outdoorTemperature < 8 {
+			w.gheDamperOpen = w.gheTemperature > w.outdoorTemperature
+		} else {
+			w.gheDamperOpen = w.outdoorTemperature > 22 && w.gheTemperature < w.outdoorTemperature
+		}
+	}
+}
+
+func wanasTemperature(value float64) uint16 {
+	return uint16(int16(math.Round(value * 10)))
+}
+
+// Advance follows the automatic ground heat exchanger damper and moves the temperatures, the intake
+// air comes through the exchanger while its damper is open. The gears 1-4 run at a quarter of the
+// power each.
+func (w *Wanas) Advance(now time.Time) {
+	w.updateGHEDamper()
+	intake := w.outdoorTemperature
+	if w.gheDamperOpen {
+		intake = w.gheTemperature
+	}
+	w.thermal.Advance(now, float64(w.gear*25), false, intake, w.extractTemperature, &w.supplyTemperature, &w.exhaustTemperature)
+}
+
+// State implements HRULogic.
+func (w *Wanas) State() DeviceState {
+	return DeviceState{
+		"gear":               &w.gear,
+		"gheDamperMode":      &w.gheDamperMode,
+		"gheDamperOpen":      &w.gheDamperOpen,
+		"outdoorTemperature": &w.outdoorTemperature,
+		"gheTemperature":     &w.gheTemperature,
+		"supplyTemperature":  &w.supplyTemperature,
+		"extractTemperature": &w.extractTemperature,
+		"exhaustTemperature": &w.exhaustTemperature,
+	}
+}
+
+func (w *Wanas) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Wanas", ProductCode: "Wanas", Revision: "4.0.2"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 1 {
+			return []uint16{uint16(w.gear)}, &Success
+		}
+		if register == 2 {
+			return []uint16{uint16(w.gheDamperMode)}, &Success
+		}
+		if register == 3 {
+			if w.gheDamperOpen {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		if register == 10 {
+			return []uint16{wanasTemperature(w.outdoorTemperature)}, &Success
+		}
+		if register == 11 {
+			return []uint16{wanasTemperature(w.gheTemperature)}, &Success
+		}
+		if register == 12 {
+			return []uint16{wanasTemperature(w.supplyTemperature)}, &Success
+		}
+		if register == 13 {
+			return []uint16{wanasTemperature(w.extractTemperature)}, &Success
+		}
+		if register == 14 {
+			return []uint16{wanasTemperature(w.exhaustTemperature)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		if register == 1 {
+			if value > 4 {
+				return &IllegalDataValue
+			}
+			w.gear = int(value)
+			log.Printf(">>> CHANGE: gear=%d\n", w.gear)
+			return &Success
+		}
+		if register == 2 {
+			if value > 2 {
+				return &IllegalDataValue
+			}
+			w.gheDamperMode = int(value)
+			w.updateGHEDamper()
+			log.Printf(">>> CHANGE: gheDamperMode=%d, gheDamperOpen=%v\n", w.gheDamperMode, w.gheDamperOpen)
+			return &Success
+		}
+		return &IllegalDataAddress
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnReadInputRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
+		return []uint16{}, &IllegalFunction
+	})
+}
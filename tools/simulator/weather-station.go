@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+type weatherSample struct {
+	offset      float64
+	temperature float64
+	humidity    float64
+	windSpeed   float64
+}
+
+// WeatherStation simulates an outdoor weather station. Without samples the values follow a diurnal
+// sinusoid of the local time of day (coldest at 3:00, warmest at 15:00), with samples loaded from a
+// CSV file the values are interpolated between rows and the file is replayed in a loop.
+type WeatherStation struct {
+	meanTemperature      float64
+	temperatureAmplitude float64
+	meanHumidity         float64
+	humidityAmplitude    float64
+	meanWindSpeed        float64
+	samples              []weatherSample
+	start                time.Time
+}
+
+func NewWeatherStation() *WeatherStation {
+	return &WeatherStation{
+		meanTemperature:      12,
+		temperatureAmplitude: 6,
+		meanHumidity:         70,
+		humidityAmplitude:    15,
+		meanWindSpeed:        3,
+		start:                clock.Now(),
+	}
+}
+
+// NewWeatherStationFromCSV loads rows of "seconds,temperature,humidity,windSpeed", the seconds being
+// the offset of the row from the start of the replay. A header row is skipped.
+func NewWeatherStationFromCSV(path string) (*WeatherStation, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	w := NewWeatherStation()
+	for i, record := range records {
+		if len(record) != 4 {
+			return nil, fmt.Errorf("%s:%d: expected 4 columns, got %d", path, i+1, len(record))
+		}
+		var values [4]float64
+		for j, field := range record {
+			values[j], err = strconv.ParseFloat(field, 64)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		if len(w.samples) > 0 && values[0] <= w.samples[len(w.samples)-1].offset {
+			return nil, fmt.Errorf("%s:%d: offsets have to be increasing", path, i+1)
+		}
+		w.samples = append(w.samples, weatherSample{offset: values[0], temperature: values[1], humidity: values[2], windSpeed: values[3]})
+	}
+	if len(w.samples) < 2 {
+		return nil, fmt.Errorf("%s: at least two samples are required", path)
+	}
+	return w, nil
+}
+
+func (w *WeatherStation) current() weatherSample {
+	if len(w.samples) == 0 {
+		now := clock.Now()
+		hours := float64(now.Hour()) + float64(now.Minute())/60 + float64(now.Second())/3600
+		phase := math.Sin(2 * math.Pi * (hours - 9) / 24)
+		return weatherSample{
+			temperature: w.meanTemperature + w.temperatureAmplitude*phase,
+			humidity:    w.meanHumidity - w.humidityAmplitude*phase,
+			windSpeed:   w.meanWindSpeed * (1 + 0.5*phase),
+		}
+	}
+
+	first, last := w.samples[0], w.samples[len(w.samples)-1]
+	offset := first.offset + math.Mod(clock.Since(w.start).Seconds(), last.offset-first.offset)
+	for i := 1; i < len(w.samples); i++ {
+		next := w.samples[i]
+		if offset <= next.offset {
+			prev := w.samples[i-1]
+			ratio := (offset - prev.offset) / (next.offset - prev.offset)
+			return weatherSample{
+				offset:      offset,
+				temperature: prev.temperature + (next.temperature-prev.temperature)*ratio,
+				humidity:    prev.humidity + (next.humidity-prev.humidity)*ratio,
+				windSpeed:   prev.windSpeed + (next.windSpeed-prev.windSpeed)*ratio,
+			}
+		}
+	}
+	return last
+}
+
+// State implements HRULogic.
+func (w *WeatherStation) State() DeviceState {
+	return DeviceState{
+		"meanTemperature":      &w.meanTemperature,
+		"temperatureAmplitude": &w.temperatureAmplitude,
+		"meanHumidity":         &w.meanHumidity,
+		"humidityAmplitude":    &w.humidityAmplitude,
+		"meanWindSpeed":        &w.meanWindSpeed,
+		"start":                &w.start,
+	}
+}
+
+func (w *WeatherStation) Configure(serv *Server) {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Luftuj", ProductCode: "Weather station", Revision: "1.0.0"})
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		current := w.current()
+		if register == 0 {
+			return []uint16{uint16(int16(math.Round(current.temperature * 10)))}, &Success
+		}
+		if register == 1 {
+			return []uint16{uint16(math.Round(current.humidity * 10))}, &Success
+		}
+		if register == 2 {
+			return []uint16{uint16(math.Round(current.windSpeed * 10))}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnReadHoldingRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
+		return []uint16{}, &IllegalFunction
+	})
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
+		return &IllegalFunction
+	})
+}
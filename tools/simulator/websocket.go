@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// websocketGUID is appended to the client key to compute the accept key of the handshake.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// websocketConn is the server side of a WebSocket (RFC 6455) that only sends text messages and
+// answers pings, which is all the event stream needs and keeps the simulator free of another
+// dependency.
+type websocketConn struct {
+	conn net.Conn
+	// writer is guarded by lock, as pongs are sent while events are.
+	writer *bufio.Writer
+	lock   sync.Mutex
+	// closed is closed when the client closes the connection.
+	closed chan struct{}
+}
+
+// upgradeWebsocket completes the opening handshake of the request.
+func upgradeWebsocket(w http.ResponseWriter, r *http.Request) (*websocketConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket handshake", http.StatusBadRequest)
+		return nil, fmt.Errorf("not a WebSocket handshake")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be upgraded", http.StatusInternalServerError)
+		return nil, fmt.Errorf("connection cannot be hijacked")
+	}
+	conn, buffer, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(buffer, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(accept[:]))
+	if err := buffer.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ws := &websocketConn{conn: conn, writer: buffer.Writer, closed: make(chan struct{})}
+	// Messages of the client are ignored, pings are answered and reading detects the close.
+	go func() {
+		defer close(ws.closed)
+		for {
+			header := make([]byte, 2)
+			if _, err := io.ReadFull(buffer.Reader, header); err != nil {
+				return
+			}
+			opcode := header[0] & 0x0F
+			if opcode == 0x8 {
+				return
+			}
+			length := uint64(header[1] & 0x7F)
+			switch length {
+			case 126:
+				extended := make([]byte, 2)
+				if _, err := io.ReadFull(buffer.Reader, extended); err != nil {
+					return
+				}
+				length = uint64(binary.BigEndian.Uint16(extended))
+			case 127:
+				extended := make([]byte, 8)
+				if _, err := io.ReadFull(buffer.Reader, extended); err != nil {
+					return
+				}
+				length = binary.BigEndian.Uint64(extended)
+			}
+			mask := make([]byte, 4)
+			if header[1]&0x80 != 0 {
+				if _, err := io.ReadFull(buffer.Reader, mask); err != nil {
+					return
+				}
+			}
+			if opcode != 0x9 {
+				if _, err := io.CopyN(io.Discard, buffer.Reader, int64(length)); err != nil {
+					return
+				}
+				continue
+			}
+			// control frames carry at most 125 bytes
+			if length > 125 {
+				return
+			}
+			payload := make([]byte, length)
+			if _, err := io.ReadFull(buffer.Reader, payload); err != nil {
+				return
+			}
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+			if err := ws.writeFrame(0x8A, payload); err != nil {
+				return
+			}
+		}
+	}()
+	return ws, nil
+}
+
+// WriteText sends the message in a single unmasked text frame.
+func (ws *websocketConn) WriteText(message []byte) error {
+	return ws.writeFrame(0x81, message)
+}
+
+// writeFrame sends an unmasked final frame, first is the header byte with the opcode.
+func (ws *websocketConn) writeFrame(first byte, message []byte) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+	header := []byte{first}
+	switch {
+	case len(message) < 126:
+		header = append(header, byte(len(message)))
+	case len(message) <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(message)))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(len(message)))
+	}
+	ws.writer.Write(header)
+	ws.writer.Write(message)
+	return ws.writer.Flush()
+}
+
+// Close sends a close frame and closes the connection.
+func (ws *websocketConn) Close() error {
+	ws.writeFrame(0x88, nil)
+	return ws.conn.Close()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	. "github.com/tbrandon/mbserver"
+)
+
+const (
+	weekProgramSlots = 8
+	// weekProgramUnused is the start of a slot that is not used, 24:00.
+	weekProgramUnused = 24 * 60
+)
+
+// weekSlot switches the unit to the power and mode at the start, in minutes after midnight.
+type weekSlot struct {
+	start int
+	power int
+	mode  int
+}
+
+// WeekProgram is the week program of the Atrea units, so schedule uploads and downloads can be tested.
+// The register at Base enables it (0/1), the slots of each day follow at Base + 100 for Monday up to
+// Base + 700 for Sunday, 3 registers per slot: start in minutes after midnight (1440 leaves the slot
+// unused), power in percent and mode. A day is read or written as one block of 24 registers.
+//
+// While the program is enabled, the unit switches to the power and mode of a slot when it starts, like
+// a setpoint written at that time. The active slot is applied right away when the program is enabled
+// or changed, it may have started on an earlier day.
+type WeekProgram struct {
+	Base    uint16
+	MaxMode int
+	enabled bool
+	days    [7][weekProgramSlots]weekSlot
+	// active is the slot applied last as day * weekProgramSlots + slot, -1 to apply the active one.
+	active int
+}
+
+func NewWeekProgram(base uint16, maxMode int) WeekProgram {
+	p := WeekProgram{Base: base, MaxMode: maxMode, active: -1}
+	for day := range p.days {
+		for slot := range p.days[day] {
+			p.days[day][slot].start = weekProgramUnused
+		}
+	}
+	return p
+}
+
+// Apply adds the registers of the program to the map.
+func (p *WeekProgram) Apply(registers RegisterMap) {
+	registers[p.Base] = &Register{
+		Get: func() float64 { return boolValue(p.enabled) },
+		Set: func(value float64) *Exception {
+			p.enabled = value != 0
+			p.active = -1
+			log.Printf(">>> CHANGE: weekProgram=%v\n", p.enabled)
+			return &Success
+		},
+		Max: 1,
+	}
+	for day := range p.days {
+		for slot := range p.days[day] {
+			address := p.Base + uint16(100*(day+1)+3*slot)
+			registers[address] = p.slotRegister(&p.days[day][slot].start, weekProgramUnused)
+			registers[address+1] = p.slotRegister(&p.days[day][slot].power, 100)
+			registers[address+2] = p.slotRegister(&p.days[day][slot].mode, float64(p.MaxMode))
+		}
+	}
+}
+
+func (p *WeekProgram) slotRegister(value *int, max float64) *Register {
+	return &Register{
+		Get: func() float64 { return float64(*value) },
+		Set: func(written float64) *Exception {
+			*value = int(written)
+			p.active = -1
+			return &Success
+		},
+		Max: max,
+	}
+}
+
+// weekProgramState is a WeekProgram in snapshots, every slot as start, power and mode.
+type weekProgramState struct {
+	Enabled bool                        `json:"enabled"`
+	Days    [7][weekProgramSlots][3]int `json:"days"`
+	Active  int                         `json:"active"`
+}
+
+func (p WeekProgram) state() weekProgramState {
+	state := weekProgramState{Enabled: p.enabled, Active: p.active}
+	for day := range p.days {
+		for slot, s := range p.days[day] {
+			state.Days[day][slot] = [3]int{s.start, s.power, s.mode}
+		}
+	}
+	return state
+}
+
+func (p WeekProgram) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.state())
+}
+
+// UnmarshalJSON sets the slots within the limits of their registers, the base and maximum mode stay.
+func (p *WeekProgram) UnmarshalJSON(data []byte) error {
+	state := p.state()
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	var days [7][weekProgramSlots]weekSlot
+	for day := range state.Days {
+		for slot, s := range state.Days[day] {
+			if s[0] < 0 || s[0] > weekProgramUnused || s[1] < 0 || s[1] > 100 || s[2] < 0 || s[2] > p.MaxMode {
+				return fmt.Errorf("invalid slot %d of day %d: %v", slot+1, day+1, s)
+			}
+			days[day][slot] = weekSlot{start: s[0], power: s[1], mode: s[2]}
+		}
+	}
+	if state.Active < -1 || state.Active >= len(days)*weekProgramSlots {
+		return fmt.Errorf("invalid active slot %d", state.Active)
+	}
+	p.enabled = state.Enabled
+	p.days = days
+	p.active = state.Active
+	return nil
+}
+
+// contains reports whether the register belongs to the program.
+func (p *WeekProgram) contains(register uint16) bool {
+	return register >= p.Base && register < p.Base+800
+}
+
+// due returns the slot to switch to, false if the program is disabled or the active slot has been
+// applied already.
+func (p *WeekProgram) due(now time.Time) (weekSlot, bool) {
+	if !p.enabled {
+		return weekSlot{}, false
+	}
+	// the week starts on Monday
+	today := (int(now.Weekday()) + 6) % 7
+	minutes := now.Hour()*60 + now.Minute()
+	// the active slot is the last one started, going back through the week from now, today's slots
+	// starting later started a week ago
+	for back := 0; back <= 7; back++ {
+		day := (today - back + 7) % 7
+		latest := -1
+		for slot, candidate := range p.days[day] {
+			if candidate.start == weekProgramUnused || (back == 0 && candidate.start > minutes) {
+				continue
+			}
+			if latest < 0 || candidate.start > p.days[day][latest].start {
+				latest = slot
+			}
+		}
+		if latest < 0 {
+			continue
+		}
+		active := day*weekProgramSlots + latest
+		if active == p.active {
+			return weekSlot{}, false
+		}
+		p.active = active
+		return p.days[day][latest], true
+	}
+	return weekSlot{}, false
+}
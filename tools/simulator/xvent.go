@@ -2,10 +2,18 @@ package main
 
 import (
 	"log"
+	"time"
 
 	. "github.com/tbrandon/mbserver"
 )
 
+// Coils mirroring the bits of the control register 0x9C40.
+const (
+	xventCoilPower  = 0
+	xventCoilBoost  = 1
+	xventCoilBypass = 2
+)
+
 type Xvent struct {
 	bypass         bool
 	boost          bool
@@ -14,6 +22,11 @@ type Xvent struct {
 	filterElapsed  int
 	filterLifetime int
 	error          int
+	boostMinutes   int
+	// counted is the simulated time the filter hours are counted up to.
+	counted time.Time
+	// boostStarted is the simulated time the boost was switched on, zero while it is off.
+	boostStarted time.Time
 }
 
 func NewXvent() *Xvent {
@@ -23,12 +36,77 @@ func NewXvent() *Xvent {
 		filterLifetime: 180 * 24,
 		filterElapsed:  15 * 24,
 		error:          0,
+		boostMinutes:   20,
+		counted:        clock.Now(),
+	}
+}
+
+// Advance ends the boost after its minutes, the unit returns to its speed, and counts the filter
+// hours while the unit is powered on. A boost switched on over the API or the coil runs from the first
+// time it is seen.
+func (x *Xvent) Advance(now time.Time) {
+	if !x.boost {
+		x.boostStarted = time.Time{}
+	} else if x.boostStarted.IsZero() {
+		x.boostStarted = now
+	} else if now.Sub(x.boostStarted) >= time.Duration(x.boostMinutes)*time.Minute {
+		x.boost = false
+		x.boostStarted = time.Time{}
+		log.Printf(">>> CHANGE: boost=%v, ended\n", x.boost)
+	}
+	hours := int(now.Sub(x.counted) / time.Hour)
+	if hours <= 0 {
+		return
+	}
+	x.counted = x.counted.Add(time.Duration(hours) * time.Hour)
+	if !x.powerOn {
+		return
+	}
+	dirty := x.filterDirty()
+	x.filterElapsed += hours
+	if !dirty && x.filterDirty() {
+		log.Printf(">>> CHANGE: filterElapsed=%d, filterDirty=%v\n", x.filterElapsed, x.filterDirty())
+	}
+}
+
+// setBoost switches the boost on or off. The countdown starts when the boost bit is set, control words
+// written with the bit still set keep it running.
+func (x *Xvent) setBoost(boost bool) {
+	if boost && !x.boost {
+		x.boostStarted = clock.Now()
+	}
+	x.boost = boost
+}
+
+// filterDirty reports whether the filters ran for their lifetime and ask for a change.
+func (x *Xvent) filterDirty() bool {
+	return x.filterElapsed >= x.filterLifetime
+}
+
+// alarmRegisters reports the number of the active error in input register 0x7552.
+func (x *Xvent) alarmRegisters() []alarmRegister {
+	return []alarmRegister{{code: &x.error}}
+}
+
+// State implements HRULogic.
+func (x *Xvent) State() DeviceState {
+	return DeviceState{
+		"bypass":         &x.bypass,
+		"boost":          &x.boost,
+		"powerOn":        &x.powerOn,
+		"speed":          &x.speed,
+		"filterElapsed":  &x.filterElapsed,
+		"filterLifetime": &x.filterLifetime,
+		"error":          &x.error,
+		"boostMinutes":   &x.boostMinutes,
+		"boostStarted":   &x.boostStarted,
 	}
 }
 
 func (x *Xvent) Configure(serv *Server) {
-	OnReadHoldingRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
-		if register == 0x9C40 && numRegs == 1 {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Xvent", ProductCode: "Xvent HRU", Revision: "1.5"})
+	readHoldingRegisters := ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0x9C40 {
 			res := x.speed << 6
 			if x.powerOn {
 				res |= 0x1
@@ -41,32 +119,101 @@ func (x *Xvent) Configure(serv *Server) {
 			}
 			return []uint16{uint16(res)}, &Success
 		}
-		if register == 0x9C57 && numRegs == 1 {
+		if register == 0x9C57 {
 			return []uint16{uint16(x.filterLifetime)}, &Success
 		}
+		if register == 0x9C59 {
+			return []uint16{uint16(x.boostMinutes)}, &Success
+		}
 		return []uint16{}, &IllegalDataAddress
 	})
-	OnReadInputRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
-		if register == 0x754C && numRegs == 1 {
-			return []uint16{uint16(x.filterElapsed)}, &Success
+	writeHoldingRegisters := func(register uint16, values []uint16) *Exception {
+		if register == 0x9C58 && len(values) == 1 {
+			// the filter change is confirmed by writing 1 to the reset register
+			if values[0] != 1 {
+				return &IllegalDataValue
+			}
+			x.filterElapsed = 0
+			log.Printf(">>> CHANGE: filterElapsed=%d\n", x.filterElapsed)
+			return &Success
 		}
-		if register == 0x7552 && numRegs == 1 {
-			return []uint16{uint16(x.error)}, &Success
+		if register == 0x9C59 && len(values) == 1 {
+			if values[0] == 0 {
+				return &IllegalDataValue
+			}
+			x.boostMinutes = int(values[0])
+			log.Printf(">>> CHANGE: boostMinutes=%d\n", x.boostMinutes)
+			return &Success
 		}
-		return []uint16{}, &IllegalDataAddress
-	})
-	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
-		return &IllegalFunction
-	})
-	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
 		if register == 0x9C40 && len(values) == 1 {
+			x.setBoost((values[0] & 0x10) != 0)
 			x.speed = int((values[0] >> 6) & 0xF)
-			x.boost = (values[0] & 0x10) != 0
 			x.bypass = (values[0] & 0x4) != 0
 			x.powerOn = (values[0] & 0x1) != 0
 			log.Printf(">>> CHANGE: speed=%d, boost=%v, bypass=%v, powerOn=%v\n", x.speed, x.boost, x.bypass, x.powerOn)
 			return &Success
 		}
 		return &IllegalDataAddress
+	}
+
+	OnReadHoldingRegisters(serv, readHoldingRegisters)
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 0x754C {
+			return []uint16{uint16(x.filterElapsed)}, &Success
+		}
+		if register == 0x754D {
+			if x.filterDirty() {
+				return []uint16{1}, &Success
+			}
+			return []uint16{0}, &Success
+		}
+		if register == 0x7552 {
+			return []uint16{uint16(x.error)}, &Success
+		}
+		return []uint16{}, &IllegalDataAddress
+	}))
+	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
+		return &IllegalFunction
+	})
+	OnWriteHoldingRegisters(serv, writeHoldingRegisters)
+	OnReadWriteMultipleRegisters(serv, readHoldingRegisters, writeHoldingRegisters)
+	OnReadCoils(serv, func(address uint16, numCoils int) ([]bool, *Exception) {
+		coils := []bool{x.powerOn, x.boost, x.bypass}
+		if int(address)+numCoils > len(coils) {
+			return []bool{}, &IllegalDataAddress
+		}
+		return coils[address : int(address)+numCoils], &Success
+	})
+	writeCoils := func(address uint16, values []bool) *Exception {
+		coils := []*bool{xventCoilPower: &x.powerOn, xventCoilBoost: &x.boost, xventCoilBypass: &x.bypass}
+		if int(address)+len(values) > len(coils) {
+			return &IllegalDataAddress
+		}
+		for i, value := range values {
+			*coils[int(address)+i] = value
+		}
+		log.Printf(">>> CHANGE: speed=%d, boost=%v, bypass=%v, powerOn=%v\n", x.speed, x.boost, x.bypass, x.powerOn)
+		return &Success
+	}
+	OnWriteCoil(serv, func(address uint16, value bool) *Exception {
+		return writeCoils(address, []bool{value})
+	})
+	OnWriteMultipleCoils(serv, writeCoils)
+	OnMaskWriteRegister(serv, func(register uint16, andMask uint16, orMask uint16) *Exception {
+		if register != 0x9C40 {
+			return &IllegalDataAddress
+		}
+		current, _ := readHoldingRegisters(register, 1)
+		return writeHoldingRegisters(register, []uint16{maskRegister(current[0], andMask, orMask)})
+	})
+	OnReadExceptionStatus(serv, func() uint8 {
+		var status uint8
+		if x.error != 0 {
+			status |= exceptionStatusFault
+		}
+		if x.filterDirty() {
+			status |= exceptionStatusService
+		}
+		return status
 	})
 }
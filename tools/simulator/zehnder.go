@@ -44,52 +44,75 @@ func NewZehnder() *Zehnder {
 	}
 }
 
+// State implements HRULogic.
+func (m *Zehnder) State() DeviceState {
+	return DeviceState{
+		"error":                  &m.error,
+		"connectionState":        &m.connectionState,
+		"ventilationMode":        &m.ventilationMode,
+		"temperatureProfile":     &m.temperatureProfile,
+		"temperatureProfileMode": &m.temperatureProfileMode,
+		"requestedTemperature":   &m.requestedTemperature,
+		"comfoClime":             &m.comfoClime,
+		"roomTemperature":        &m.roomTemperature,
+		"insideTemperature":      &m.insideTemperature,
+		"outsideTemperature":     &m.outsideTemperature,
+		"supplyTemperature":      &m.supplyTemperature,
+		"exhaustTemperature":     &m.exhaustTemperature,
+		"roomHumidity":           &m.roomHumidity,
+		"insideHumidity":         &m.insideHumidity,
+		"replaceFilterDays":      &m.replaceFilterDays,
+		"changeFilter":           &m.changeFilter,
+	}
+}
+
 func (m *Zehnder) Configure(serv *Server) {
-	OnReadHoldingRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
-		if register == 1 && numRegs == 1 {
+	OnReadDeviceIdentification(serv, DeviceIdentification{VendorName: "Zehnder", ProductCode: "ComfoAir Q", Revision: "1.7.0"})
+	OnReadHoldingRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 1 {
 			return []uint16{uint16(m.ventilationMode)}, &Success
 		}
-		if register == 2 && numRegs == 1 {
+		if register == 2 {
 			return []uint16{uint16(m.temperatureProfile)}, &Success
 		}
-		if register == 3 && numRegs == 1 {
+		if register == 3 {
 			return []uint16{uint16(m.temperatureProfileMode)}, &Success
 		}
-		if register == 4 && numRegs == 1 {
+		if register == 4 {
 			return []uint16{uint16(m.requestedTemperature)}, &Success
 		}
 		return []uint16{}, &IllegalDataAddress
-	})
-	OnReadInputRegisters(serv, func(register uint16, numRegs int) ([]uint16, *Exception) {
-		if register == 1 && numRegs == 1 {
+	}))
+	OnReadInputRegisters(serv, ReadValues(func(register uint16) ([]uint16, *Exception) {
+		if register == 1 {
 			return []uint16{uint16(m.connectionState)}, &Success
 		}
-		if register == 0x1A && numRegs == 1 {
+		if register == 0x1A {
 			return []uint16{uint16(m.replaceFilterDays)}, &Success
 		}
-		if register == 0x8 && numRegs == 1 {
+		if register == 0x8 {
 			return []uint16{uint16(m.roomTemperature)}, &Success
 		}
-		if register == 0x9 && numRegs == 1 {
+		if register == 0x9 {
 			return []uint16{uint16(m.insideTemperature)}, &Success
 		}
-		if register == 0xA && numRegs == 1 {
+		if register == 0xA {
 			return []uint16{uint16(m.exhaustTemperature)}, &Success
 		}
-		if register == 0xB && numRegs == 1 {
+		if register == 0xB {
 			return []uint16{uint16(m.outsideTemperature)}, &Success
 		}
-		if register == 0xC && numRegs == 1 {
+		if register == 0xC {
 			return []uint16{uint16(m.supplyTemperature)}, &Success
 		}
-		if register == 0xD && numRegs == 1 {
+		if register == 0xD {
 			return []uint16{uint16(m.roomHumidity)}, &Success
 		}
-		if register == 0xE && numRegs == 1 {
+		if register == 0xE {
 			return []uint16{uint16(m.insideHumidity)}, &Success
 		}
 		return []uint16{}, &IllegalDataAddress
-	})
+	}))
 	OnWriteHoldingRegister(serv, func(register uint16, value uint16) *Exception {
 		if register == 1 {
 			m.ventilationMode = int(value)
@@ -135,4 +158,14 @@ func (m *Zehnder) Configure(serv *Server) {
 	OnWriteHoldingRegisters(serv, func(register uint16, values []uint16) *Exception {
 		return &IllegalFunction
 	})
+	OnReadExceptionStatus(serv, func() uint8 {
+		var status uint8
+		if m.error {
+			status |= exceptionStatusFault
+		}
+		if m.changeFilter {
+			status |= exceptionStatusService
+		}
+		return status
+	})
 }